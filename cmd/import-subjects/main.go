@@ -2,13 +2,18 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"io/fs"
 	"log"
-	"mime"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/P3chys/entoo2-api/internal/config"
 	"github.com/P3chys/entoo2-api/internal/database"
@@ -19,7 +24,69 @@ import (
 	"gorm.io/gorm"
 )
 
+// unassignedSemesterName is the semester imported subjects go to by default
+const unassignedSemesterName = "Nepřiřazeno"
+
+// File outcomes of an import
+const (
+	fileNew       = "new"
+	fileChanged   = "changed"
+	fileUnchanged = "unchanged"
+	fileSkipped   = "skipped"
+	fileFailed    = "failed"
+)
+
+// importer imports a legacy folder tree: one folder per subject, in which a
+// first-level folder names the document type (Přednášky, Semináře, ...) or,
+// failing that, a category of other documents, and a second-level folder
+// under a type folder names the category. Every imported file is recorded in
+// the import manifest, so a rerun only uploads new and changed files.
+type importer struct {
+	db         *gorm.DB
+	storage    *services.StorageService
+	extraction *services.ExtractionQueue
+	outbox     *services.SearchOutbox
+	source     string
+	semesterID uuid.UUID
+	uploaderID uuid.UUID
+	dryRun     bool
+
+	usedCodes   map[string]bool
+	documentIDs []uuid.UUID
+	subjectIDs  []uuid.UUID
+	report      importReport
+}
+
+type importReport struct {
+	SubjectsCreated   int
+	SubjectsExisting  int
+	SubjectsSkipped   int
+	CategoriesCreated int
+	Files             map[string]int
+	UploadedBytes     int64
+}
+
 func main() {
+	sourceDir := flag.String("source", "", "folder with one subfolder per subject (required)")
+	sourceName := flag.String("source-name", "", "name of the source in the import manifest (default: base name of -source)")
+	semester := flag.String("semester", unassignedSemesterName, "name or ID of the semester new subjects are created in")
+	uploader := flag.String("uploader", "", "email of the user recorded as uploader (default: first admin)")
+	skip := flag.String("skip", "test", "comma-separated subject folders to skip")
+	dryRun := flag.Bool("dry-run", false, "only report what would be imported")
+	wait := flag.Bool("wait", true, "extract text and index the imported documents before exiting; otherwise the API server's workers pick them up")
+	waitTimeout := flag.Duration("wait-timeout", 30*time.Minute, "how long to wait for extraction and indexing")
+	flag.Parse()
+
+	if *sourceDir == "" {
+		log.Fatal("-source is required")
+	}
+	if info, err := os.Stat(*sourceDir); err != nil || !info.IsDir() {
+		log.Fatalf("Source folder does not exist: %s", *sourceDir)
+	}
+	if *sourceName == "" {
+		*sourceName = filepath.Base(filepath.Clean(*sourceDir))
+	}
+
 	// Load .env file
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found")
@@ -39,211 +106,520 @@ func main() {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
-	// Get or create "Unassigned" semester
-	semesterID, err := getOrCreateUnassignedSemester(db)
+	uploaderID, err := findUploader(db, *uploader)
 	if err != nil {
-		log.Fatalf("Failed to get/create unassigned semester: %v", err)
+		log.Fatal(err)
 	}
 
-	log.Printf("Using semester ID: %s", semesterID)
-
-	// Get or create admin user for file uploads
-	adminID, err := getOrCreateAdminUser(db)
+	semesterID, err := findSemester(db, *semester, *dryRun)
 	if err != nil {
-		log.Fatalf("Failed to get/create admin user: %v", err)
+		log.Fatal(err)
 	}
 
-	log.Printf("Using admin user ID: %s", adminID)
-
 	// Initialize storage service
 	storageService, err := services.NewStorageService(cfg)
 	if err != nil {
 		log.Fatalf("Failed to initialize storage service: %v", err)
 	}
 
-	// Initialize search service
-	searchService := services.NewSearchService(cfg)
-	log.Println("Meilisearch service initialized")
+	searchService := services.NewSearchService(cfg, db)
+	searchOutbox := services.NewSearchOutbox(db, searchService)
+	var tikaService *services.TextExtractionService
+	if cfg.TikaURL != "" {
+		tikaService = services.NewTextExtractionService(cfg)
+	}
+	textExtractor := services.NewFallbackExtractor(tikaService, services.NewLocalTextExtractor())
+	extractionQueue := services.NewExtractionQueue(db, cfg, storageService, textExtractor, searchOutbox)
+
+	imp := &importer{
+		db:         db,
+		storage:    storageService,
+		extraction: extractionQueue,
+		outbox:     searchOutbox,
+		source:     *sourceName,
+		semesterID: semesterID,
+		uploaderID: uploaderID,
+		dryRun:     *dryRun,
+		usedCodes:  make(map[string]bool),
+		report:     importReport{Files: make(map[string]int)},
+	}
 
-	// Import subjects from directory
-	// Try Linux path first (for Docker), then Windows path
-	subjectsDir := `/old_entoo/entoo_subjects`
-	if _, err := os.Stat(subjectsDir); os.IsNotExist(err) {
-		subjectsDir = `E:\old_entoo\entoo_subjects`
-		if _, err := os.Stat(subjectsDir); os.IsNotExist(err) {
-			log.Fatalf("Subjects directory does not exist: %s", subjectsDir)
+	skipped := make(map[string]bool)
+	for _, name := range strings.Split(*skip, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			skipped[strings.ToLower(name)] = true
 		}
 	}
 
-	importedSubjects, err := importSubjects(db, storageService, subjectsDir, semesterID, adminID)
-	if err != nil {
-		log.Fatalf("Failed to import subjects: %v", err)
+	if err := imp.run(*sourceDir, skipped); err != nil {
+		log.Fatalf("Import failed: %v", err)
 	}
 
-	// Index all imported subjects in Meilisearch
-	if len(importedSubjects) > 0 {
-		log.Printf("Indexing %d subjects in Meilisearch...", len(importedSubjects))
-		if err := searchService.IndexSubjects(importedSubjects); err != nil {
-			log.Printf("Warning: Failed to index subjects in Meilisearch: %v", err)
-		} else {
-			log.Printf("Successfully indexed %d subjects in Meilisearch", len(importedSubjects))
-		}
-	}
+	imp.printReport()
 
+	if imp.dryRun || len(imp.documentIDs)+len(imp.subjectIDs) == 0 {
+		return
+	}
+	if !*wait {
+		log.Printf("Extraction and indexing of %d documents and %d subjects is queued for the API server", len(imp.documentIDs), len(imp.subjectIDs))
+		return
+	}
+	if err := imp.waitForProcessing(*waitTimeout); err != nil {
+		log.Printf("Import finished, but %v", err)
+		os.Exit(1)
+	}
 	log.Println("Import completed successfully!")
 }
 
-func getOrCreateUnassignedSemester(db *gorm.DB) (uuid.UUID, error) {
-	var semester models.Semester
-
-	// Try to find existing "Unassigned" semester
-	err := db.Where("name_cs = ?", "Nepřiřazeno").First(&semester).Error
+func findUploader(db *gorm.DB, email string) (uuid.UUID, error) {
+	var user models.User
+	query := db.Where("role = ?", models.RoleAdmin)
+	if email != "" {
+		query = db.Where("email = ?", email)
+	}
+	if err := query.Order("created_at").First(&user).Error; err != nil {
+		if email != "" {
+			return uuid.Nil, fmt.Errorf("no user with email %s: %w", email, err)
+		}
+		return uuid.Nil, fmt.Errorf("no admin user found, please create one first or use -uploader: %w", err)
+	}
+	log.Printf("Using uploader: %s (%s)", user.Email, user.ID)
+	return user.ID, nil
+}
 
+// findSemester returns the semester with the given ID or name. The default
+// unassigned semester is created when missing.
+func findSemester(db *gorm.DB, value string, dryRun bool) (uuid.UUID, error) {
+	var semester models.Semester
+	var err error
+	if id, parseErr := uuid.Parse(value); parseErr == nil {
+		err = db.First(&semester, "id = ?", id).Error
+	} else {
+		err = db.Where("name_cs = ?", value).First(&semester).Error
+	}
 	if err == nil {
-		log.Printf("Found existing unassigned semester: %s", semester.ID)
+		log.Printf("Using semester: %s (%s)", semester.NameCS, semester.ID)
 		return semester.ID, nil
 	}
-
-	if err != gorm.ErrRecordNotFound {
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
 		return uuid.Nil, fmt.Errorf("error checking for semester: %w", err)
 	}
+	if value != unassignedSemesterName {
+		return uuid.Nil, fmt.Errorf("semester %q does not exist", value)
+	}
 
-	// Create new unassigned semester
+	if dryRun {
+		log.Printf("Would create semester: %s", unassignedSemesterName)
+		return uuid.Nil, nil
+	}
 	semester = models.Semester{
-		ID:         uuid.New(),
-		NameCS:     "Nepřiřazeno",
+		NameCS:     unassignedSemesterName,
 		OrderIndex: 999, // Put at the end
 	}
-
 	if err := db.Create(&semester).Error; err != nil {
 		return uuid.Nil, fmt.Errorf("failed to create semester: %w", err)
 	}
-
-	log.Printf("Created new unassigned semester: %s", semester.ID)
+	log.Printf("Created semester: %s (%s)", semester.NameCS, semester.ID)
 	return semester.ID, nil
 }
 
-func getOrCreateAdminUser(db *gorm.DB) (uuid.UUID, error) {
-	var user models.User
+func (imp *importer) run(baseDir string, skipped map[string]bool) error {
+	entries, err := os.ReadDir(baseDir)
+	if err != nil {
+		return fmt.Errorf("failed to read directory: %w", err)
+	}
 
-	// Try to find an admin user
-	err := db.Where("role = ?", "admin").First(&user).Error
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		subjectName := entry.Name()
+		if skipped[strings.ToLower(subjectName)] {
+			imp.report.SubjectsSkipped++
+			continue
+		}
 
+		subjectID, err := imp.subject(subjectName)
+		if err != nil {
+			log.Printf("Failed to import subject %s: %v", subjectName, err)
+			imp.report.SubjectsSkipped++
+			continue
+		}
+		imp.importFiles(filepath.Join(baseDir, subjectName), subjectName, subjectID)
+	}
+	return nil
+}
+
+// subject returns the subject of a folder, creating it when no subject has
+// the folder's name. In a dry run a new subject gets a nil ID.
+func (imp *importer) subject(name string) (uuid.UUID, error) {
+	var existing models.Subject
+	err := imp.db.Where("name_cs = ?", name).First(&existing).Error
 	if err == nil {
-		log.Printf("Found existing admin user: %s (%s)", user.Email, user.ID)
-		return user.ID, nil
+		log.Printf("Subject %s exists (code: %s)", name, existing.Code)
+		imp.report.SubjectsExisting++
+		return existing.ID, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return uuid.Nil, err
 	}
 
-	if err != gorm.ErrRecordNotFound {
-		return uuid.Nil, fmt.Errorf("error checking for admin user: %w", err)
+	code, err := imp.uniqueCode(generateSubjectCode(name))
+	if err != nil {
+		return uuid.Nil, err
+	}
+	imp.report.SubjectsCreated++
+	if imp.dryRun {
+		log.Printf("Would create subject: %s (code: %s)", name, code)
+		return uuid.Nil, nil
 	}
 
-	// If no admin exists, find any user
-	if err = db.First(&user).Error; err == nil {
-		log.Printf("Using existing user: %s (%s)", user.Email, user.ID)
-		return user.ID, nil
+	subject := models.Subject{
+		SemesterID: imp.semesterID,
+		NameCS:     name,
+		Code:       code,
+	}
+	err = imp.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&subject).Error; err != nil {
+			return err
+		}
+		if err := services.CreateDefaultCategories(tx, subject.ID, imp.uploaderID); err != nil {
+			return err
+		}
+		return imp.outbox.Enqueue(tx, models.SearchEntitySubject, subject.ID, models.SearchOperationIndex)
+	})
+	if err != nil {
+		return uuid.Nil, err
 	}
+	imp.outbox.Notify()
+	log.Printf("Created subject: %s (code: %s, id: %s)", name, code, subject.ID)
+	imp.subjectIDs = append(imp.subjectIDs, subject.ID)
+	return subject.ID, nil
+}
 
-	return uuid.Nil, fmt.Errorf("no users found in database, please create an admin user first")
+// uniqueCode appends a counter to code until no subject uses it
+func (imp *importer) uniqueCode(code string) (string, error) {
+	candidate := code
+	for counter := 1; ; counter++ {
+		if !imp.usedCodes[candidate] {
+			var count int64
+			if err := imp.db.Model(&models.Subject{}).Where("code = ?", candidate).Count(&count).Error; err != nil {
+				return "", err
+			}
+			if count == 0 {
+				imp.usedCodes[candidate] = true
+				return candidate, nil
+			}
+		}
+		suffix := fmt.Sprint(counter)
+		base := []rune(code)
+		if len(base)+len(suffix) > services.MaxSubjectCodeLength {
+			base = base[:services.MaxSubjectCodeLength-len(suffix)]
+		}
+		candidate = string(base) + suffix
+	}
 }
 
-func importSubjects(db *gorm.DB, storage *services.StorageService, baseDir string, semesterID uuid.UUID, uploaderID uuid.UUID) ([]models.Subject, error) {
-	entries, err := os.ReadDir(baseDir)
+func (imp *importer) importFiles(subjectDir, subjectName string, subjectID uuid.UUID) {
+	categories := make(map[string]uuid.UUID)
+	plannedCategories := make(map[string]bool)
+	counts := make(map[string]int)
+
+	err := filepath.WalkDir(subjectDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Printf("  Cannot read %s: %v", path, err)
+			return nil
+		}
+		if d.IsDir() || services.IsJunkFile(d.Name()) {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(subjectDir, path)
+		if err != nil {
+			return nil
+		}
+		relPath = filepath.ToSlash(relPath)
+		manifestPath := subjectName + "/" + relPath
+
+		status, err := imp.importFile(path, manifestPath, relPath, subjectID, categories, plannedCategories)
+		if err != nil {
+			log.Printf("  %s: %v", relPath, err)
+		} else if status != fileUnchanged {
+			log.Printf("  %s: %s", relPath, status)
+		}
+		counts[status]++
+		imp.report.Files[status]++
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to read directory: %w", err)
+		log.Printf("  Error walking directory: %v", err)
 	}
 
-	imported := 0
-	skipped := 0
-	errors := 0
-	totalFiles := 0
-	uploadedFiles := 0
-	var importedSubjects []models.Subject
+	log.Printf("  -> %d new, %d changed, %d unchanged, %d skipped, %d failed",
+		counts[fileNew], counts[fileChanged], counts[fileUnchanged], counts[fileSkipped], counts[fileFailed])
+}
 
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
+// placement returns the document type and category for a file path relative
+// to its subject folder
+func placement(relPath string) (string, string) {
+	parts := strings.Split(relPath, "/")
+	if len(parts) == 1 {
+		return "other", ""
+	}
+	docType, isType := services.FolderDocumentType(parts[0])
+	if !isType {
+		return "other", strings.TrimSpace(parts[0])
+	}
+	if len(parts) > 2 {
+		return docType, strings.TrimSpace(parts[1])
+	}
+	return docType, ""
+}
+
+func (imp *importer) importFile(path, manifestPath, relPath string, subjectID uuid.UUID, categories map[string]uuid.UUID, plannedCategories map[string]bool) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileFailed, err
+	}
+	modTime := info.ModTime().UTC().Truncate(time.Second)
+
+	var manifest models.ImportFile
+	err = imp.db.Where("source = ? AND path = ?", imp.source, manifestPath).First(&manifest).Error
+	known := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fileFailed, err
+	}
+	if known && manifest.Size == info.Size() && manifest.ModTime.Equal(modTime) {
+		return fileUnchanged, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fileFailed, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return fileFailed, err
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+
+	var existing *models.Document
+	if known {
+		if manifest.SHA256 == checksum {
+			// Only the timestamp changed, e.g. after copying the tree
+			if !imp.dryRun {
+				imp.db.Model(&manifest).Updates(map[string]interface{}{"size": info.Size(), "mod_time": modTime})
+			}
+			return fileUnchanged, nil
+		}
+		var document models.Document
+		if err := imp.db.First(&document, "id = ?", manifest.DocumentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				// Deleted by an admin after the last import
+				return fileSkipped, errors.New("document was deleted, not importing it again")
+			}
+			return fileFailed, err
 		}
+		existing = &document
+	}
 
-		subjectName := entry.Name()
+	status := fileNew
+	if existing != nil {
+		status = fileChanged
+	}
 
-		// Skip test directories
-		if strings.ToLower(subjectName) == "test" {
-			skipped++
-			continue
+	docType, categoryName := placement(relPath)
+	if imp.dryRun {
+		if existing == nil {
+			imp.planCategory(subjectID, docType, categoryName, plannedCategories)
 		}
+		imp.report.UploadedBytes += info.Size()
+		return status, nil
+	}
 
-		// Check if subject already exists
-		var existing models.Subject
-		err := db.Where("name_cs = ?", subjectName).First(&existing).Error
-		if err == nil {
-			log.Printf("Subject already exists, skipping: %s", subjectName)
-			skipped++
-			continue
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fileFailed, err
+	}
+	base := filepath.Base(path)
+	mimeType := services.MimeTypeForFile(base)
+	newFilename := fmt.Sprintf("%s%s", uuid.New().String(), strings.ToLower(filepath.Ext(base)))
+	if err := imp.storage.UploadStream(file, newFilename, info.Size(), mimeType); err != nil {
+		return fileFailed, fmt.Errorf("failed to upload file: %w", err)
+	}
+
+	var document models.Document
+	var oldPath string
+	if existing != nil {
+		// A changed file replaces the stored file of its document, which
+		// keeps its category, comments and favorites
+		document = *existing
+		oldPath = document.MinIOPath
+		document.Filename = newFilename
+		document.MinIOPath = newFilename
+		document.FileSize = info.Size()
+		document.MimeType = mimeType
+	} else {
+		categoryID, created, err := services.ResolveCategory(imp.db, subjectID, imp.uploaderID, docType, categoryName, categories)
+		if err != nil {
+			_ = imp.storage.DeleteFile(newFilename)
+			return fileFailed, fmt.Errorf("failed to resolve category: %w", err)
 		}
-		if err != gorm.ErrRecordNotFound {
-			log.Printf("Error checking subject %s: %v", subjectName, err)
-			errors++
-			continue
+		if created {
+			imp.report.CategoriesCreated++
+		}
+		document = models.Document{
+			SubjectID:    subjectID,
+			UploadedBy:   imp.uploaderID,
+			Type:         docType,
+			CategoryID:   categoryID,
+			Filename:     newFilename,
+			OriginalName: base,
+			FileSize:     info.Size(),
+			MimeType:     mimeType,
+			MinIOPath:    newFilename,
 		}
+	}
 
-		// Generate a code from the subject name
-		code := generateSubjectCode(subjectName)
-
-		// Ensure code is unique
-		originalCode := code
-		counter := 1
-		for {
-			var codeCheck models.Subject
-			err := db.Where("code = ?", code).First(&codeCheck).Error
-			if err == gorm.ErrRecordNotFound {
-				break
-			}
-			code = fmt.Sprintf("%s%d", originalCode, counter)
-			counter++
-			if len(code) > 6 {
-				code = code[:6]
+	err = imp.db.Transaction(func(tx *gorm.DB) error {
+		if existing != nil {
+			if err := tx.Model(&models.Document{}).Where("id = ?", document.ID).Updates(map[string]interface{}{
+				"filename":          document.Filename,
+				"minio_path":        document.MinIOPath,
+				"file_size":         document.FileSize,
+				"mime_type":         document.MimeType,
+				"content_text":      "",
+				"extraction_status": models.ExtractionStatusPending,
+			}).Error; err != nil {
+				return err
 			}
+		} else if err := tx.Create(&document).Error; err != nil {
+			return err
 		}
 
-		// Create the subject
-		subject := models.Subject{
-			ID:            uuid.New(),
-			SemesterID:    semesterID,
-			NameCS:        subjectName,
-			Code:          code,
-			DescriptionCS: "",
-			Credits:       0,
+		manifest.Source = imp.source
+		manifest.Path = manifestPath
+		manifest.SubjectID = subjectID
+		manifest.DocumentID = document.ID
+		manifest.Size = info.Size()
+		manifest.ModTime = modTime
+		manifest.SHA256 = checksum
+		if err := tx.Save(&manifest).Error; err != nil {
+			return err
 		}
 
-		if err := db.Create(&subject).Error; err != nil {
-			log.Printf("Failed to create subject %s: %v", subjectName, err)
-			errors++
-			continue
+		if err := imp.extraction.Enqueue(tx, &document); err != nil {
+			return err
 		}
+		return imp.outbox.Enqueue(tx, models.SearchEntityDocument, document.ID, models.SearchOperationIndex)
+	})
+	if err != nil {
+		_ = imp.storage.DeleteFile(newFilename)
+		return fileFailed, fmt.Errorf("failed to save document: %w", err)
+	}
+
+	if oldPath != "" {
+		imp.deleteReplacedFile(oldPath)
+	}
+	imp.extraction.Notify()
+	imp.outbox.Notify()
+	imp.documentIDs = append(imp.documentIDs, document.ID)
+	imp.report.UploadedBytes += info.Size()
+	return status, nil
+}
 
-		log.Printf("Imported subject: %s (code: %s, id: %s)", subjectName, code, subject.ID)
-		imported++
-		importedSubjects = append(importedSubjects, subject)
+// deleteReplacedFile removes the previous file of a changed document unless
+// documents carried over to other semesters still share it
+func (imp *importer) deleteReplacedFile(minioPath string) {
+	var shared int64
+	imp.db.Unscoped().Model(&models.Document{}).Where("minio_path = ?", minioPath).Count(&shared)
+	if shared > 0 {
+		return
+	}
+	if err := imp.storage.DeleteFile(minioPath); err != nil {
+		log.Printf("  Failed to delete replaced file %s: %v", minioPath, err)
+	}
+}
 
-		// Upload files from subject directory
-		subjectDir := filepath.Join(baseDir, subjectName)
-		fileCount, uploadCount := uploadSubjectFiles(db, storage, subjectDir, subject.ID, uploaderID)
-		totalFiles += fileCount
-		uploadedFiles += uploadCount
+// planCategory counts the categories a dry run would create
+func (imp *importer) planCategory(subjectID uuid.UUID, docType, name string, planned map[string]bool) {
+	if name == "" || planned[docType+"/"+name] {
+		return
+	}
+	planned[docType+"/"+name] = true
 
-		if fileCount > 0 {
-			log.Printf("  -> Uploaded %d/%d files", uploadCount, fileCount)
-		}
+	var count int64
+	if subjectID != uuid.Nil {
+		imp.db.Model(&models.DocumentCategory{}).
+			Where("subject_id = ? AND type = ? AND name_cs = ?", subjectID, docType, name).
+			Count(&count)
+	}
+	if count == 0 {
+		imp.report.CategoriesCreated++
 	}
+}
 
-	log.Printf("\nImport Summary:")
-	log.Printf("  Subjects imported: %d", imported)
-	log.Printf("  Subjects skipped: %d", skipped)
-	log.Printf("  Errors: %d", errors)
-	log.Printf("  Files uploaded: %d/%d", uploadedFiles, totalFiles)
+// waitForProcessing runs the extraction workers and the search outbox until
+// every imported document is extracted and indexed, along with new subjects
+func (imp *importer) waitForProcessing(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	log.Printf("Extracting text and indexing %d documents...", len(imp.documentIDs))
+	imp.extraction.Start(ctx)
+	imp.outbox.Start(ctx)
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		var extracting, indexing int64
+		if err := imp.db.Model(&models.ExtractionJob{}).
+			Where("document_id IN ? AND status IN ?", imp.documentIDs,
+				[]models.ExtractionJobStatus{models.ExtractionJobQueued, models.ExtractionJobRunning}).
+			Count(&extracting).Error; err != nil {
+			return err
+		}
+		if err := imp.db.Model(&models.SearchOutboxEntry{}).
+			Where("(entity_type = ? AND entity_id IN ?) OR (entity_type = ? AND entity_id IN ?)",
+				models.SearchEntityDocument, imp.documentIDs, models.SearchEntitySubject, imp.subjectIDs).
+			Count(&indexing).Error; err != nil {
+			return err
+		}
+		if extracting == 0 && indexing == 0 {
+			var failed int64
+			imp.db.Model(&models.ExtractionJob{}).
+				Where("document_id IN ? AND status = ?", imp.documentIDs, models.ExtractionJobFailed).
+				Count(&failed)
+			if failed > 0 {
+				log.Printf("Text extraction failed for %d documents; see the admin extraction jobs", failed)
+			}
+			return nil
+		}
 
-	return importedSubjects, nil
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d documents are still being extracted and %d indexed after %s; the API server will finish them", extracting, indexing, timeout)
+		case <-ticker.C:
+			log.Printf("  %d documents waiting for extraction, %d for indexing", extracting, indexing)
+		}
+	}
+}
+
+func (imp *importer) printReport() {
+	prefix := ""
+	if imp.dryRun {
+		prefix = "[dry run] "
+	}
+	files := imp.report.Files
+	log.Printf("\n%sImport Summary:", prefix)
+	log.Printf("  Subjects created: %d", imp.report.SubjectsCreated)
+	log.Printf("  Subjects already present: %d", imp.report.SubjectsExisting)
+	log.Printf("  Subjects skipped: %d", imp.report.SubjectsSkipped)
+	log.Printf("  Categories created: %d", imp.report.CategoriesCreated)
+	log.Printf("  Files new: %d, changed: %d, unchanged: %d, skipped: %d, failed: %d",
+		files[fileNew], files[fileChanged], files[fileUnchanged], files[fileSkipped], files[fileFailed])
+	log.Printf("  Uploaded: %.1f MB", float64(imp.report.UploadedBytes)/(1024*1024))
 }
 
 func generateSubjectCode(name string) string {
@@ -282,87 +658,3 @@ func generateSubjectCode(name string) string {
 
 	return result
 }
-
-func uploadSubjectFiles(db *gorm.DB, storage *services.StorageService, subjectDir string, subjectID uuid.UUID, uploaderID uuid.UUID) (int, int) {
-	totalFiles := 0
-	uploadedFiles := 0
-
-	err := filepath.WalkDir(subjectDir, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil // Skip files we can't access
-		}
-
-		if d.IsDir() {
-			return nil // Continue walking
-		}
-
-		totalFiles++
-
-		// Open the file
-		file, err := os.Open(path)
-		if err != nil {
-			log.Printf("    Failed to open file %s: %v", path, err)
-			return nil
-		}
-		defer file.Close()
-
-		// Get file info
-		fileInfo, err := file.Stat()
-		if err != nil {
-			log.Printf("    Failed to stat file %s: %v", path, err)
-			return nil
-		}
-
-		// Get relative path from subject directory
-		relPath, err := filepath.Rel(subjectDir, path)
-		if err != nil {
-			relPath = filepath.Base(path)
-		}
-
-		// Generate unique filename for MinIO
-		ext := filepath.Ext(path)
-		filename := fmt.Sprintf("%s/%s%s", subjectID.String(), uuid.New().String(), ext)
-
-		// Detect MIME type
-		mimeType := mime.TypeByExtension(ext)
-		if mimeType == "" {
-			mimeType = "application/octet-stream"
-		}
-
-		// Upload to MinIO
-		ctx := context.Background()
-		_, err = storage.UploadFileFromPath(ctx, file, filename, fileInfo.Size(), mimeType)
-		if err != nil {
-			log.Printf("    Failed to upload file %s: %v", relPath, err)
-			return nil
-		}
-
-		// Create document record
-		document := models.Document{
-			ID:           uuid.New(),
-			SubjectID:    subjectID,
-			UploadedBy:   uploaderID,
-			Filename:     filename,
-			OriginalName: relPath,
-			FileSize:     fileInfo.Size(),
-			MimeType:     mimeType,
-			MinIOPath:    filename,
-		}
-
-		if err := db.Create(&document).Error; err != nil {
-			log.Printf("    Failed to create document record for %s: %v", relPath, err)
-			// Try to delete the uploaded file from MinIO
-			_ = storage.DeleteFile(filename)
-			return nil
-		}
-
-		uploadedFiles++
-		return nil
-	})
-
-	if err != nil {
-		log.Printf("  Error walking directory: %v", err)
-	}
-
-	return totalFiles, uploadedFiles
-}
@@ -1,17 +1,45 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
-	"time"
+	"os"
+	"strings"
 
 	"github.com/P3chys/entoo2-api/internal/config"
 	"github.com/P3chys/entoo2-api/internal/database"
-	"github.com/P3chys/entoo2-api/internal/models"
 	"github.com/P3chys/entoo2-api/internal/services"
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 )
 
+// Modes of the reindex tool
+const (
+	modeRepair  = "repair"
+	modeRebuild = "rebuild"
+)
+
 func main() {
+	mode := flag.String("mode", modeRepair, "repair: fix missing, stale and orphaned index entries; rebuild: index everything into a new index and swap it in")
+	indexes := flag.String("index", "all", "comma-separated indexes to process: documents, subjects, questions, comments or all")
+	subject := flag.String("subject", "", "limit a repair to the records of one subject ID")
+	dryRun := flag.Bool("dry-run", false, "only report what would change")
+	batchSize := flag.Int("batch", 500, "number of records per batch")
+	flag.Parse()
+
+	if *mode != modeRepair && *mode != modeRebuild {
+		log.Fatalf("Unknown mode %q (use %s or %s)", *mode, modeRepair, modeRebuild)
+	}
+	if *subject != "" {
+		if _, err := uuid.Parse(*subject); err != nil {
+			log.Fatalf("Invalid subject ID %q", *subject)
+		}
+		if *mode == modeRebuild {
+			log.Fatal("A rebuild always covers whole indexes; use -mode=repair with -subject")
+		}
+	}
+
 	// Load .env file
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found")
@@ -26,62 +54,106 @@ func main() {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
-	// Initialize search service
-	searchService := services.NewSearchService(cfg)
-	log.Println("Meilisearch service initialized")
-
-	// Get counts
-	var dbCount int64
-	if err := db.Model(&models.Document{}).Count(&dbCount).Error; err != nil {
-		log.Fatalf("Failed to get document count from DB: %v", err)
+	if cfg.SearchBackend == services.SearchBackendPostgres {
+		refreshVectors(services.NewPostgresSearchService(db), *mode, *subject, *dryRun)
+		return
 	}
 
-	meiliCount, err := searchService.GetDocumentCount()
+	kinds, err := selectIndexes(*indexes)
 	if err != nil {
-		log.Fatalf("Failed to get document count from Meilisearch: %v", err)
+		log.Fatal(err)
 	}
 
-	log.Printf("Documents in DB: %d", dbCount)
-	log.Printf("Documents in Meilisearch: %d", meiliCount)
+	searchService := services.NewMeiliSearchService(cfg, db)
+	log.Println("Meilisearch service initialized")
 
-	if meiliCount == dbCount {
-		log.Println("Counts match. Verifying all documents are indexed...")
-	} else {
-		log.Println("Counts do not match. Reindexing all documents...")
+	opts := services.ReindexOptions{
+		SubjectID: *subject,
+		DryRun:    *dryRun,
+		BatchSize: *batchSize,
 	}
 
-	// Fetch all documents in batches
-	batchSize := 100
-	var offset int
-	totalIndexed := 0
+	failed := false
+	for _, kind := range kinds {
+		log.Printf("Running %s of the %s index...", *mode, kind.UID)
 
-	for {
-		var documents []models.Document
-		if err := db.Limit(batchSize).Offset(offset).Find(&documents).Error; err != nil {
-			log.Fatalf("Failed to fetch documents: %v", err)
+		var report *services.ReindexReport
+		if *mode == modeRebuild {
+			report, err = searchService.Rebuild(kind, opts)
+		} else {
+			report, err = searchService.Repair(kind, opts)
+		}
+		if report != nil {
+			logReport(*report, *dryRun)
+			if report.Failed > 0 {
+				failed = true
+			}
 		}
+		if err != nil {
+			log.Printf("%s of %s failed: %v", *mode, kind.UID, err)
+			failed = true
+		}
+	}
+
+	if failed {
+		log.Println("Reindexing finished with failures")
+		os.Exit(1)
+	}
+	log.Println("Reindexing completed")
+}
+
+// selectIndexes resolves the -index flag
+func selectIndexes(value string) ([]services.SearchIndexKind, error) {
+	if value == "" || value == "all" {
+		return services.SearchIndexKinds, nil
+	}
 
-		if len(documents) == 0 {
-			break
+	var kinds []services.SearchIndexKind
+	for _, name := range strings.Split(value, ",") {
+		kind, ok := services.FindSearchIndexKind(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("unknown index %q (use documents, subjects, questions, comments or all)", name)
 		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
 
-		if err := searchService.IndexDocuments(documents); err != nil {
-			log.Printf("Failed to index batch (offset %d): %v", offset, err)
-		} else {
-			totalIndexed += len(documents)
-			log.Printf("Indexed batch of %d documents (total: %d)", len(documents), totalIndexed)
+func logReport(report services.ReindexReport, dryRun bool) {
+	log.Printf("%s: %d rows, %d missing, %d stale, %d orphaned", report.Index, report.Rows, report.Missing, report.Stale, report.Orphaned)
+	if dryRun {
+		for _, id := range report.MissingIDs {
+			log.Printf("  missing:  %s", id)
+		}
+		for _, id := range report.StaleIDs {
+			log.Printf("  stale:    %s", id)
 		}
+		for _, id := range report.OrphanedIDs {
+			log.Printf("  orphaned: %s", id)
+		}
+		return
+	}
 
-		offset += batchSize
-		time.Sleep(100 * time.Millisecond) // Be nice to Meilisearch
+	log.Printf("%s: indexed %d, deleted %d, failed %d", report.Index, report.Indexed, report.Deleted, report.Failed)
+	for _, message := range report.Errors {
+		log.Printf("  error: %s", message)
 	}
+}
 
-	// Final check
-	finalMeiliCount, err := searchService.GetDocumentCount()
-	if err != nil {
-		log.Printf("Failed to get final count: %v", err)
+// refreshVectors handles the postgres backend, where the index is a column
+// of each row: a rebuild recomputes every vector and a repair fills in
+// missing ones
+func refreshVectors(search *services.PostgresSearchService, mode, subject string, dryRun bool) {
+	if subject != "" || dryRun {
+		log.Fatal("-subject and -dry-run apply to the Meilisearch backend only")
 	}
 
-	log.Printf("Reindexing completed.")
-	log.Printf("Final Meilisearch count: %d", finalMeiliCount)
+	counts, err := search.RefreshVectors(mode == modeRepair)
+	for table, count := range counts {
+		log.Printf("Refreshed search vectors of %d %s", count, table)
+	}
+	if err != nil {
+		log.Fatalf("Refreshing search vectors failed: %v", err)
+	}
+	log.Println("Reindexing completed")
 }
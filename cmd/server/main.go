@@ -41,8 +41,12 @@ func main() {
 		log.Printf("Warning: Failed to seed admin user: %v", err)
 	}
 
+	// Background workers stop when this context is cancelled on shutdown
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
 	// Setup router
-	r := router.Setup(db, cfg)
+	r := router.Setup(workerCtx, db, cfg)
 
 	// Create HTTP server
 	srv := &http.Server{
@@ -63,6 +67,7 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Println("Shutting down server...")
+	stopWorkers()
 
 	// Graceful shutdown with 5-second timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/P3chys/entoo2-api/internal/config"
+	"github.com/P3chys/entoo2-api/internal/database"
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/P3chys/entoo2-api/internal/services"
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+	"gorm.io/gorm"
+)
+
+func main() {
+	importFile := flag.String("import", "", "CSV or JSON catalogue to create or update subjects from, keyed by code")
+	exportFile := flag.String("export", "", "file to write the current catalogue to (- for stdout)")
+	format := flag.String("format", "", "csv or json (default: from the file extension, else csv)")
+	dryRun := flag.Bool("dry-run", false, "only report what an import would change")
+	createSemesters := flag.Bool("create-semesters", false, "create semesters named in the catalogue that do not exist")
+	adminEmail := flag.String("admin", "", "email of the admin recorded as creator of new categories (default: first admin)")
+	flag.Parse()
+
+	if (*importFile == "") == (*exportFile == "") {
+		log.Fatal("Use exactly one of -import or -export")
+	}
+
+	path := *importFile + *exportFile
+	catalogueFormat := strings.ToLower(*format)
+	if catalogueFormat == "" {
+		catalogueFormat = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+	if catalogueFormat == "" {
+		catalogueFormat = services.CatalogueFormatCSV
+	}
+	if catalogueFormat != services.CatalogueFormatCSV && catalogueFormat != services.CatalogueFormatJSON {
+		log.Fatalf("Unknown format %q (use %s or %s)", catalogueFormat, services.CatalogueFormatCSV, services.CatalogueFormatJSON)
+	}
+
+	// Load .env file
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found")
+	}
+
+	// Load configuration
+	cfg := config.Load()
+
+	// Initialize database
+	db, err := database.Connect(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	// Search index changes are queued for the API server's outbox dispatcher
+	catalogue := services.NewCatalogueService(db, services.NewSearchOutbox(db, services.NewSearchService(cfg, db)))
+
+	if *exportFile != "" {
+		exportCatalogue(catalogue, *exportFile, catalogueFormat)
+		return
+	}
+
+	adminID, err := findAdmin(db, *adminEmail)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	file, err := os.Open(*importFile)
+	if err != nil {
+		log.Fatalf("Failed to open catalogue: %v", err)
+	}
+	defer file.Close()
+
+	entries, err := services.ParseCatalogue(file, catalogueFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report, err := catalogue.Import(entries, services.CatalogueImportOptions{
+		DryRun:          *dryRun,
+		CreateSemesters: *createSemesters,
+		AdminID:         adminID,
+	})
+	if report != nil {
+		printReport(report)
+	}
+	if err != nil {
+		log.Printf("Import failed: %v", err)
+		os.Exit(1)
+	}
+}
+
+func exportCatalogue(catalogue *services.CatalogueService, path, format string) {
+	entries, err := catalogue.Export()
+	if err != nil {
+		log.Fatalf("Failed to export catalogue: %v", err)
+	}
+
+	out := os.Stdout
+	if path != "-" {
+		if out, err = os.Create(path); err != nil {
+			log.Fatalf("Failed to create %s: %v", path, err)
+		}
+		defer out.Close()
+	}
+	if err := services.WriteCatalogue(out, entries, format); err != nil {
+		log.Fatalf("Failed to write catalogue: %v", err)
+	}
+	if path != "-" {
+		log.Printf("Exported %d subjects to %s", len(entries), path)
+	}
+}
+
+func findAdmin(db *gorm.DB, email string) (uuid.UUID, error) {
+	var user models.User
+	query := db.Where("role = ?", models.RoleAdmin)
+	if email != "" {
+		query = query.Where("email = ?", email)
+	}
+	if err := query.Order("created_at").First(&user).Error; err != nil {
+		if email != "" {
+			return uuid.Nil, fmt.Errorf("no admin with email %s: %w", email, err)
+		}
+		return uuid.Nil, fmt.Errorf("no admin user found, please create one first: %w", err)
+	}
+	return user.ID, nil
+}
+
+func printReport(report *services.CatalogueImportReport) {
+	for _, row := range report.Rows {
+		switch row.Action {
+		case services.CatalogueActionInvalid:
+			log.Printf("row %d %s: invalid: %s", row.Row, row.Code, strings.Join(row.Errors, "; "))
+		case services.CatalogueActionCreate:
+			log.Printf("row %d %s: create", row.Row, row.Code)
+		case services.CatalogueActionUpdate:
+			fields := make([]string, 0, len(row.Changes))
+			for field := range row.Changes {
+				fields = append(fields, field)
+			}
+			sort.Strings(fields)
+			for _, field := range fields {
+				change := row.Changes[field]
+				log.Printf("row %d %s: %s: %v -> %v", row.Row, row.Code, field, change.Old, change.New)
+			}
+		}
+	}
+	for _, name := range report.CreatedSemesters {
+		log.Printf("semester %q: create", name)
+	}
+
+	prefix := ""
+	if report.DryRun {
+		prefix = "[dry run] "
+	}
+	log.Printf("%s%d created, %d updated, %d unchanged, %d invalid",
+		prefix, report.Created, report.Updated, report.Unchanged, report.Invalid)
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -23,12 +24,34 @@ type Config struct {
 	MinIOBucket    string
 	MinIOUseSSL    bool
 
+	// Search backend: "meilisearch" or "postgres"
+	SearchBackend string
+
 	// Meilisearch
 	MeiliURL    string
 	MeiliAPIKey string
 
-	// Tika
-	TikaURL string
+	// Tika (optional); without a URL only the built-in extractors are used
+	TikaURL     string
+	TikaTimeout string
+
+	// Text extraction queue
+	ExtractionWorkers     int
+	ExtractionMaxAttempts int
+
+	// Trash bin
+	TrashRetention     string
+	TrashPurgeInterval string
+
+	// Saved search notifications
+	SavedSearchInterval string
+
+	// Search analytics: how long individual searches are kept
+	SearchLogRetention string
+
+	// Storage quotas in bytes (0 disables the limit)
+	UserStorageQuota    int64
+	SubjectStorageQuota int64
 
 	// JWT
 	JWTSecret        string
@@ -69,10 +92,26 @@ func Load() *Config {
 		MinIOBucket:    getEnv("MINIO_BUCKET", "documents"),
 		MinIOUseSSL:    getEnv("MINIO_USE_SSL", "false") == "true",
 
+		SearchBackend: getEnv("SEARCH_BACKEND", "meilisearch"),
+
 		MeiliURL:    getEnv("MEILI_URL", "http://localhost:7700"),
 		MeiliAPIKey: getEnv("MEILI_API_KEY", "dev_master_key_change_in_production"),
 
-		TikaURL: getEnv("TIKA_URL", "http://localhost:9998"),
+		TikaURL:     getEnv("TIKA_URL", ""),
+		TikaTimeout: getEnv("TIKA_TIMEOUT", "60s"),
+
+		ExtractionWorkers:     int(getEnvInt64("EXTRACTION_WORKERS", 2)),
+		ExtractionMaxAttempts: int(getEnvInt64("EXTRACTION_MAX_ATTEMPTS", 5)),
+
+		TrashRetention:     getEnv("TRASH_RETENTION", "720h"),
+		TrashPurgeInterval: getEnv("TRASH_PURGE_INTERVAL", "1h"),
+
+		SavedSearchInterval: getEnv("SAVED_SEARCH_INTERVAL", "15m"),
+
+		SearchLogRetention: getEnv("SEARCH_LOG_RETENTION", "2160h"),
+
+		UserStorageQuota:    getEnvInt64("USER_STORAGE_QUOTA_MB", 2048) * 1024 * 1024,
+		SubjectStorageQuota: getEnvInt64("SUBJECT_STORAGE_QUOTA_MB", 10240) * 1024 * 1024,
 
 		JWTSecret:        getEnv("JWT_SECRET", "development_secret"),
 		JWTAccessExpiry:  getEnv("JWT_ACCESS_EXPIRY", "15m"),
@@ -100,3 +139,12 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt64(key string, defaultValue int64) int64 {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
@@ -53,14 +53,62 @@ func RunMigrations(db *gorm.DB) error {
 		UpdatedAt     time.Time
 	}
 
+	type Teacher struct {
+		ID         string `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+		Name       string `gorm:"size:200;not null;index"`
+		Title      string `gorm:"size:100"`
+		Department string `gorm:"size:200"`
+		Profile    string `gorm:"type:text"`
+		CreatedAt  time.Time
+		UpdatedAt  time.Time
+	}
+
 	type SubjectTeacher struct {
 		ID          string    `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
 		SubjectID   string    `gorm:"type:uuid;not null"`
+		TeacherID   *string   `gorm:"type:uuid;index"`
 		TeacherName string    `gorm:"size:200;not null"`
+		Role        string    `gorm:"type:varchar(20);not null;default:'lecturer'"`
 		TopicCS     string    `gorm:"size:300"`
 		CreatedAt   time.Time
 	}
 
+	type AcademicYear struct {
+		ID        string `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+		Name      string `gorm:"size:20;not null;uniqueIndex"`
+		StartYear int    `gorm:"not null;uniqueIndex"`
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+
+	type Term struct {
+		ID             string     `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+		AcademicYearID string     `gorm:"type:uuid;not null;uniqueIndex:idx_term_year_kind"`
+		Kind           string     `gorm:"type:varchar(10);not null;uniqueIndex:idx_term_year_kind"`
+		StartsOn       *time.Time `gorm:"type:date"`
+		EndsOn         *time.Time `gorm:"type:date"`
+		CreatedAt      time.Time
+		UpdatedAt      time.Time
+	}
+
+	type SubjectOffering struct {
+		ID        string `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+		SubjectID string `gorm:"type:uuid;not null;uniqueIndex:idx_offering_subject_term"`
+		TermID    string `gorm:"type:uuid;not null;uniqueIndex:idx_offering_subject_term;index"`
+		Note      string `gorm:"size:500"`
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+
+	type OfferingTeacher struct {
+		ID         string `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+		OfferingID string `gorm:"type:uuid;not null;uniqueIndex:idx_offering_teacher"`
+		TeacherID  string `gorm:"type:uuid;not null;uniqueIndex:idx_offering_teacher;index"`
+		Role       string `gorm:"type:varchar(20);not null;default:'lecturer'"`
+		TopicCS    string `gorm:"size:300"`
+		CreatedAt  time.Time
+	}
+
 	type DocumentCategory struct {
 		ID         string    `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
 		SubjectID  string    `gorm:"type:uuid;not null;index:idx_subject_type"`
@@ -80,13 +128,18 @@ func RunMigrations(db *gorm.DB) error {
 		AnswerID     *string   `gorm:"type:uuid;index"`
 		Type         string    `gorm:"size:20;default:'other'"`
 		CategoryID   *string   `gorm:"type:uuid;index"`
+		OfferingID   *string   `gorm:"type:uuid;index"`
+		SourceDocumentID *string `gorm:"type:uuid;index"`
 		Filename     string    `gorm:"size:255;not null"`
 		OriginalName string    `gorm:"size:255;not null"`
 		FileSize     int64     `gorm:"not null"`
 		MimeType     string    `gorm:"size:100;not null"`
 		MinIOPath    string    `gorm:"size:500;not null"`
 		ContentText  string    `gorm:"type:text"`
+		ExtractionStatus string `gorm:"size:20;default:'pending';index"`
 		CreatedAt    time.Time `gorm:"index"`
+		DeletedAt    gorm.DeletedAt `gorm:"index"`
+		DeletedBy    *string   `gorm:"type:uuid"`
 	}
 
 	type User struct {
@@ -110,6 +163,8 @@ func RunMigrations(db *gorm.DB) error {
 		PasswordResetSentAt    *time.Time
 		PasswordResetExpiresAt *time.Time
 
+		StorageQuota *int64
+
 		FavoriteSubjects  []Subject  `gorm:"many2many:user_favorite_subjects;"`
 		FavoriteDocuments []Document `gorm:"many2many:user_favorite_documents;"`
 	}
@@ -155,14 +210,199 @@ func RunMigrations(db *gorm.DB) error {
 	}
 
 	type TeacherRating struct {
-		ID               string    `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-		SubjectTeacherID string    `gorm:"type:uuid;not null;index"`
-		UserID           string    `gorm:"type:uuid;not null;index"`
-		Rating           int       `gorm:"not null;check:rating >= 1 AND rating <= 5"`
+		ID               string  `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+		SubjectTeacherID string  `gorm:"type:uuid;not null;index"`
+		UserID           string  `gorm:"type:uuid;not null;index"`
+		Rating           int     `gorm:"not null;check:rating >= 1 AND rating <= 5"`
+		Review           string  `gorm:"type:text"`
+		Anonymous        bool    `gorm:"not null;default:false"`
+		SemesterTaken    string  `gorm:"size:50"`
+		Hidden           bool    `gorm:"not null;default:false"`
+		HiddenReason     string  `gorm:"size:500"`
+		HiddenBy         *string `gorm:"type:uuid"`
+		HiddenAt         *time.Time
 		CreatedAt        time.Time
 		UpdatedAt        time.Time
 	}
 
+	type TeacherReviewTag struct {
+		RatingID string `gorm:"type:uuid;primaryKey"`
+		Tag      string `gorm:"type:varchar(40);primaryKey;index"`
+	}
+
+	type TeacherReviewReport struct {
+		ID         string  `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+		RatingID   string  `gorm:"type:uuid;not null;uniqueIndex:idx_review_report_reporter"`
+		ReporterID string  `gorm:"type:uuid;not null;uniqueIndex:idx_review_report_reporter"`
+		Reason     string  `gorm:"size:500;not null"`
+		Status     string  `gorm:"type:varchar(20);not null;default:'open';index"`
+		ResolvedBy *string `gorm:"type:uuid"`
+		ResolvedAt *time.Time
+		CreatedAt  time.Time
+	}
+
+	type Job struct {
+		ID         string     `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+		Type       string     `gorm:"type:varchar(50);not null;index"`
+		Status     string     `gorm:"type:varchar(20);not null;default:'pending';index"`
+		CreatedBy  string     `gorm:"type:uuid;not null;index"`
+		SubjectID  *string    `gorm:"type:uuid;index"`
+		Total      int        `gorm:"not null;default:0"`
+		Processed  int        `gorm:"not null;default:0"`
+		Failed     int        `gorm:"not null;default:0"`
+		Result     string     `gorm:"type:jsonb"`
+		Error      string     `gorm:"type:text"`
+		CreatedAt  time.Time
+		UpdatedAt  time.Time
+		FinishedAt *time.Time
+	}
+
+	type ExtractionJob struct {
+		ID         string     `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+		DocumentID string     `gorm:"type:uuid;not null;uniqueIndex"`
+		Status     string     `gorm:"type:varchar(20);not null;default:'queued';index"`
+		Attempts   int        `gorm:"not null;default:0"`
+		NextRunAt  time.Time  `gorm:"not null;index"`
+		LockedAt   *time.Time
+		LastError  string     `gorm:"type:text"`
+		CreatedAt  time.Time
+		UpdatedAt  time.Time
+	}
+
+	type SavedSearch struct {
+		ID           string    `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+		UserID       string    `gorm:"type:uuid;not null;index"`
+		Name         string    `gorm:"size:100;not null"`
+		Query        string    `gorm:"size:200;not null"`
+		SubjectID    *string   `gorm:"type:uuid"`
+		SemesterID   *string   `gorm:"type:uuid"`
+		CategoryID   *string   `gorm:"type:uuid"`
+		Type         string    `gorm:"size:20"`
+		MimeType     string    `gorm:"size:100"`
+		ExactMatch   bool      `gorm:"default:false"`
+		EmailEnabled bool      `gorm:"default:true"`
+		LastRunAt    time.Time `gorm:"not null"`
+		CreatedAt    time.Time
+		UpdatedAt    time.Time
+	}
+
+	type SearchOutboxEntry struct {
+		ID            int64     `gorm:"primaryKey;autoIncrement"`
+		EntityType    string    `gorm:"type:varchar(20);not null;index:idx_search_outbox_entity"`
+		EntityID      string    `gorm:"type:uuid;not null;index:idx_search_outbox_entity"`
+		Operation     string    `gorm:"type:varchar(10);not null"`
+		Attempts      int       `gorm:"not null;default:0"`
+		NextAttemptAt time.Time `gorm:"not null;index"`
+		ClaimedUntil  *time.Time
+		LastError     string    `gorm:"type:text"`
+		CreatedAt     time.Time
+	}
+
+	type SearchSynonym struct {
+		ID        string `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+		Term      string `gorm:"size:100;not null;uniqueIndex:idx_search_synonym_pair"`
+		Synonym   string `gorm:"size:100;not null;uniqueIndex:idx_search_synonym_pair"`
+		CreatedAt time.Time
+	}
+
+	type SearchStopWord struct {
+		ID        string `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+		Word      string `gorm:"size:100;not null;uniqueIndex"`
+		CreatedAt time.Time
+	}
+
+	type DocumentPage struct {
+		ID          string `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+		DocumentID  string `gorm:"type:uuid;not null;uniqueIndex:idx_document_page_number"`
+		PageNumber  int    `gorm:"not null;uniqueIndex:idx_document_page_number"`
+		ContentText string `gorm:"type:text;not null"`
+		CreatedAt   time.Time
+	}
+
+	type ExamDate struct {
+		ID         string    `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+		OfferingID string    `gorm:"type:uuid;not null;index"`
+		StartsAt   time.Time `gorm:"not null;index"`
+		Location   string    `gorm:"size:200"`
+		Note       string    `gorm:"size:500"`
+		CreatedAt  time.Time
+		UpdatedAt  time.Time
+	}
+
+	type Enrollment struct {
+		ID        string `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+		UserID    string `gorm:"type:uuid;not null;uniqueIndex:idx_enrollment_user_subject_term"`
+		SubjectID string `gorm:"type:uuid;not null;uniqueIndex:idx_enrollment_user_subject_term;index"`
+		TermID    string `gorm:"type:uuid;not null;uniqueIndex:idx_enrollment_user_subject_term;index"`
+		CreatedAt time.Time
+	}
+
+	type SubjectRequisite struct {
+		ID                string `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+		SubjectID         string `gorm:"type:uuid;not null;uniqueIndex:idx_subject_requisite"`
+		RequiredSubjectID string `gorm:"type:uuid;not null;uniqueIndex:idx_subject_requisite;index"`
+		Kind              string `gorm:"type:varchar(20);not null"`
+		CreatedAt         time.Time
+	}
+
+	type StudyPlanEntry struct {
+		ID        string `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+		UserID    string `gorm:"type:uuid;not null;uniqueIndex:idx_study_plan_user_subject"`
+		SubjectID string `gorm:"type:uuid;not null;uniqueIndex:idx_study_plan_user_subject;index"`
+		Status    string `gorm:"type:varchar(20);not null"`
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+
+	type ImportFile struct {
+		ID         string    `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+		Source     string    `gorm:"size:100;not null;uniqueIndex:idx_import_file_path"`
+		Path       string    `gorm:"size:1000;not null;uniqueIndex:idx_import_file_path"`
+		SubjectID  string    `gorm:"type:uuid;not null;index"`
+		DocumentID string    `gorm:"type:uuid;not null;index"`
+		Size       int64     `gorm:"not null"`
+		ModTime    time.Time `gorm:"not null"`
+		SHA256     string    `gorm:"size:64;not null"`
+		CreatedAt  time.Time
+		UpdatedAt  time.Time
+	}
+
+	type SearchQueryLog struct {
+		ID            string  `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+		Query         string  `gorm:"size:200;not null"`
+		Scope         string  `gorm:"size:20;not null"`
+		SubjectID     *string `gorm:"type:uuid"`
+		Filters       string  `gorm:"type:jsonb"`
+		ResultCount   int64   `gorm:"not null"`
+		ClickedType   string  `gorm:"size:20"`
+		ClickedID     *string `gorm:"type:uuid"`
+		ClickPosition *int
+		ClickedAt     *time.Time
+		CreatedAt     time.Time `gorm:"index"`
+	}
+
+	type SearchQueryDailyStat struct {
+		ID          int64     `gorm:"primaryKey;autoIncrement"`
+		Day         time.Time `gorm:"type:date;not null;index"`
+		Query       string    `gorm:"size:200;not null"`
+		SubjectID   *string   `gorm:"type:uuid;index"`
+		Searches    int64     `gorm:"not null"`
+		ZeroResults int64     `gorm:"not null"`
+		Clicks      int64     `gorm:"not null"`
+	}
+
+	type Notification struct {
+		ID            string     `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+		UserID        string     `gorm:"type:uuid;not null;index"`
+		Type          string     `gorm:"size:50;not null"`
+		Title         string     `gorm:"size:300;not null"`
+		SavedSearchID *string    `gorm:"type:uuid"`
+		DocumentID    *string    `gorm:"type:uuid"`
+		SubjectID     *string    `gorm:"type:uuid"`
+		ReadAt        *time.Time
+		CreatedAt     time.Time  `gorm:"index"`
+	}
+
 	// Drop English language columns if they exist
 	// This is a one-time migration to remove English fields from the database
 	if err := dropEnglishColumns(db); err != nil {
@@ -170,8 +410,13 @@ func RunMigrations(db *gorm.DB) error {
 		// Continue anyway as columns might already be dropped
 	}
 
+	// The default vocabulary is seeded once, when its tables are first created
+	seedVocabulary := !db.Migrator().HasTable("search_synonyms")
+	// Pages are split from the stored text once, when their table is first created
+	backfillPages := !db.Migrator().HasTable("document_pages")
+
 	// Auto-migrate all models
-	err := db.AutoMigrate(&User{}, &Semester{}, &Subject{}, &SubjectTeacher{}, &DocumentCategory{}, &Document{}, &Activity{}, &Comment{}, &Question{}, &Answer{}, &TeacherRating{})
+	err := db.AutoMigrate(&User{}, &Semester{}, &Subject{}, &Teacher{}, &SubjectTeacher{}, &AcademicYear{}, &Term{}, &SubjectOffering{}, &OfferingTeacher{}, &DocumentCategory{}, &Document{}, &Activity{}, &Comment{}, &Question{}, &Answer{}, &TeacherRating{}, &TeacherReviewTag{}, &TeacherReviewReport{}, &Job{}, &ExtractionJob{}, &SavedSearch{}, &Notification{}, &SearchOutboxEntry{}, &SearchSynonym{}, &SearchStopWord{}, &SearchQueryLog{}, &SearchQueryDailyStat{}, &DocumentPage{}, &ImportFile{}, &SubjectRequisite{}, &StudyPlanEntry{}, &ExamDate{}, &Enrollment{})
 	if err != nil {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
@@ -185,10 +430,208 @@ func RunMigrations(db *gorm.DB) error {
 		// Continue anyway as constraint might already exist
 	}
 
+	// Subject teachers created before teachers were shared between subjects
+	if err := linkSubjectTeachers(db); err != nil {
+		log.Printf("Warning: Failed to link subject teachers to teachers: %v", err)
+	}
+
+	// One teacher per name, so concurrent imports cannot create the same teacher twice
+	if err := mergeDuplicateTeachers(db); err != nil {
+		log.Printf("Warning: Failed to merge duplicate teachers: %v", err)
+	} else if err := db.Exec(`
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_teachers_lower_name ON teachers (lower(name))
+	`).Error; err != nil {
+		log.Printf("Warning: Failed to create unique index on teacher names: %v", err)
+	}
+
+	// Documents created before the extraction queue existed already carry their text
+	if err := db.Exec(`
+		UPDATE documents SET extraction_status = 'done'
+		WHERE extraction_status = 'pending' AND content_text IS NOT NULL AND content_text <> ''
+	`).Error; err != nil {
+		log.Printf("Warning: Failed to backfill extraction status: %v", err)
+	}
+
+	// A saved search notifies about each document at most once
+	if err := db.Exec(`
+		CREATE UNIQUE INDEX IF NOT EXISTS unique_saved_search_document_notification
+		ON notifications(saved_search_id, document_id)
+		WHERE saved_search_id IS NOT NULL AND document_id IS NOT NULL
+	`).Error; err != nil {
+		log.Printf("Warning: Failed to create unique index on notifications: %v", err)
+	}
+
+	if seedVocabulary {
+		if err := SeedSearchVocabulary(db); err != nil {
+			log.Printf("Warning: Failed to seed search vocabulary: %v", err)
+		}
+	}
+
+	// Columns used by the PostgreSQL search backend
+	if err := setupFullTextSearch(db); err != nil {
+		log.Printf("Warning: Failed to set up full-text search: %v", err)
+	}
+
+	if backfillPages {
+		if err := backfillDocumentPages(db); err != nil {
+			log.Printf("Warning: Failed to backfill document pages: %v", err)
+		}
+	}
+
 	log.Println("Migrations completed successfully")
 	return nil
 }
 
+// setupFullTextSearch creates the entoo_cs text search configuration and the
+// indexed search_vector columns used when SEARCH_BACKEND=postgres
+func setupFullTextSearch(db *gorm.DB) error {
+	// unaccent needs superuser rights on some hosts; search still works without it
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS unaccent").Error; err != nil {
+		log.Printf("Warning: Failed to create unaccent extension: %v", err)
+	}
+
+	if err := db.Exec(`
+		DO $$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = 'entoo_cs') THEN
+				CREATE TEXT SEARCH CONFIGURATION entoo_cs (COPY = simple);
+				IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'unaccent') THEN
+					ALTER TEXT SEARCH CONFIGURATION entoo_cs
+						ALTER MAPPING FOR hword, hword_part, word WITH unaccent, simple;
+				END IF;
+			END IF;
+		END
+		$$
+	`).Error; err != nil {
+		return err
+	}
+
+	for _, table := range []string{"documents", "subjects", "questions", "comments", "document_pages"} {
+		if err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS search_vector tsvector", table)).Error; err != nil {
+			return err
+		}
+		if err := db.Exec(fmt.Sprintf(
+			"CREATE INDEX IF NOT EXISTS idx_%s_search_vector ON %s USING GIN (search_vector)", table, table,
+		)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkSubjectTeachers creates a teacher for every distinct name of subject
+// teachers without one and links them. Names match ignoring case and extra
+// whitespace, so one lecturer gets one teacher across all subjects.
+func linkSubjectTeachers(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`
+			INSERT INTO teachers (id, name, created_at, updated_at)
+			SELECT gen_random_uuid(), MIN(regexp_replace(btrim(st.teacher_name), '\s+', ' ', 'g')), NOW(), NOW()
+			FROM subject_teachers st
+			WHERE st.teacher_id IS NULL AND NOT EXISTS (
+				SELECT 1 FROM teachers t
+				WHERE lower(t.name) = lower(regexp_replace(btrim(st.teacher_name), '\s+', ' ', 'g'))
+			)
+			GROUP BY lower(regexp_replace(btrim(st.teacher_name), '\s+', ' ', 'g'))
+		`).Error; err != nil {
+			return err
+		}
+
+		result := tx.Exec(`
+			UPDATE subject_teachers st SET teacher_id = t.id
+			FROM teachers t
+			WHERE st.teacher_id IS NULL
+			AND lower(t.name) = lower(regexp_replace(btrim(st.teacher_name), '\s+', ' ', 'g'))
+		`)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			log.Printf("Linked %d subject teachers to teachers", result.RowsAffected)
+		}
+		return nil
+	})
+}
+
+// mergeDuplicateTeachers merges teachers whose names differ only in case
+// into the oldest of them, moving their subject and offering links
+func mergeDuplicateTeachers(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`
+			CREATE TEMPORARY TABLE teacher_merges ON COMMIT DROP AS
+			SELECT id AS duplicate_id, first_value(id) OVER (PARTITION BY lower(name) ORDER BY created_at, id) AS teacher_id
+			FROM teachers
+		`).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`DELETE FROM teacher_merges WHERE duplicate_id = teacher_id`).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Exec(`
+			UPDATE subject_teachers st SET teacher_id = m.teacher_id
+			FROM teacher_merges m WHERE st.teacher_id = m.duplicate_id
+		`).Error; err != nil {
+			return err
+		}
+		// An offering listing several of the merged teachers keeps one link,
+		// preferring the one to the teacher that stays
+		if err := tx.Exec(`
+			DELETE FROM offering_teachers ot USING (
+				SELECT o.id, row_number() OVER (
+					PARTITION BY o.offering_id, coalesce(m.teacher_id, o.teacher_id)
+					ORDER BY m.teacher_id IS NOT NULL, o.created_at, o.id
+				) AS n
+				FROM offering_teachers o LEFT JOIN teacher_merges m ON m.duplicate_id = o.teacher_id
+			) ranked
+			WHERE ot.id = ranked.id AND ranked.n > 1
+		`).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`
+			UPDATE offering_teachers ot SET teacher_id = m.teacher_id
+			FROM teacher_merges m WHERE ot.teacher_id = m.duplicate_id
+		`).Error; err != nil {
+			return err
+		}
+
+		result := tx.Exec(`DELETE FROM teachers t USING teacher_merges m WHERE t.id = m.duplicate_id`)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			log.Printf("Merged %d duplicate teachers", result.RowsAffected)
+		}
+		return nil
+	})
+}
+
+// backfillDocumentPages stores the pages of documents extracted before pages
+// were kept, splitting their text at form feeds
+func backfillDocumentPages(db *gorm.DB) error {
+	result := db.Exec(`
+		INSERT INTO document_pages (id, document_id, page_number, content_text, created_at)
+		SELECT gen_random_uuid(), d.id, p.page_number, btrim(p.content_text, E' \t\n\r\f'), NOW()
+		FROM documents d
+		CROSS JOIN LATERAL regexp_split_to_table(d.content_text, E'\f') WITH ORDINALITY AS p(content_text, page_number)
+		WHERE d.content_text IS NOT NULL AND btrim(p.content_text, E' \t\n\r\f') <> ''
+	`)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	if err := db.Exec(`
+		UPDATE document_pages SET search_vector = to_tsvector('entoo_cs', left(content_text, 300000))
+	`).Error; err != nil {
+		return err
+	}
+	log.Printf("Backfilled %d document pages", result.RowsAffected)
+	return nil
+}
+
 // dropEnglishColumns drops English language columns from tables
 // This is a one-time migration to remove bilingual support
 func dropEnglishColumns(db *gorm.DB) error {
@@ -60,3 +60,54 @@ func SeedAdmin(db *gorm.DB) error {
 	log.Printf("Created default admin user: %s", adminEmail)
 	return nil
 }
+
+// defaultSearchSynonyms are the Czech study terms students use interchangeably.
+// Diacritics need no synonyms, both search backends ignore them.
+var defaultSearchSynonyms = map[string][]string{
+	"zs":          {"zimní semestr"},
+	"ls":          {"letní semestr"},
+	"zkouška":     {"exam", "test"},
+	"exam":        {"zkouška"},
+	"zápočet":     {"credit", "zápočtový test"},
+	"přednáška":   {"lecture"},
+	"lecture":     {"přednáška"},
+	"seminář":     {"seminar", "cvičení"},
+	"cvičení":     {"seminář", "exercise"},
+	"seminar":     {"seminář"},
+	"skripta":     {"učebnice", "textbook"},
+	"učebnice":    {"skripta"},
+	"otázky":      {"okruhy"},
+	"okruhy":      {"otázky"},
+	"vypracování": {"vypracované otázky"},
+}
+
+// defaultSearchStopWords are common Czech words that carry no meaning in a search
+var defaultSearchStopWords = []string{
+	"a", "aby", "ale", "ani", "do", "i", "jak", "je", "jsou", "k", "ke", "na", "nebo",
+	"o", "od", "po", "pro", "s", "se", "ta", "to", "ten", "u", "v", "ve", "z", "za", "ze", "že",
+}
+
+// SeedSearchVocabulary stores the default Czech synonyms and stop words
+func SeedSearchVocabulary(db *gorm.DB) error {
+	var synonyms []models.SearchSynonym
+	for term, words := range defaultSearchSynonyms {
+		for _, word := range words {
+			synonyms = append(synonyms, models.SearchSynonym{Term: term, Synonym: word})
+		}
+	}
+	stopWords := make([]models.SearchStopWord, len(defaultSearchStopWords))
+	for i, word := range defaultSearchStopWords {
+		stopWords[i] = models.SearchStopWord{Word: word}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&synonyms).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&stopWords).Error; err != nil {
+			return err
+		}
+		log.Printf("Seeded %d search synonyms and %d stop words", len(synonyms), len(stopWords))
+		return nil
+	})
+}
@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/P3chys/entoo2-api/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TermDatesRequest holds the optional dates of a term as YYYY-MM-DD
+type TermDatesRequest struct {
+	StartsOn string `json:"starts_on" binding:"omitempty,datetime=2006-01-02"`
+	EndsOn   string `json:"ends_on" binding:"omitempty,datetime=2006-01-02"`
+}
+
+type CreateAcademicYearRequest struct {
+	StartYear int              `json:"start_year" binding:"required,min=1990,max=2100"`
+	Winter    TermDatesRequest `json:"winter"`
+	Summer    TermDatesRequest `json:"summer"`
+}
+
+type OfferingRequest struct {
+	TermID   string           `json:"term_id" binding:"required,uuid"`
+	Note     string           `json:"note" binding:"max=500"`
+	Teachers []TeacherRequest `json:"teachers" binding:"dive"`
+}
+
+type UpdateOfferingRequest struct {
+	Note     *string           `json:"note" binding:"omitempty,max=500"`
+	Teachers *[]TeacherRequest `json:"teachers" binding:"omitempty,dive"`
+}
+
+type ExamDateRequest struct {
+	StartsAt time.Time `json:"starts_at" binding:"required"`
+	Location string    `json:"location" binding:"max=200"`
+	Note     string    `json:"note" binding:"max=500"`
+}
+
+type SetDocumentOfferingRequest struct {
+	OfferingID *string `json:"offering_id" binding:"omitempty,uuid"`
+}
+
+// termInput parses the dates of a term; the end must not be before the start
+func (r TermDatesRequest) termInput() (services.TermInput, bool) {
+	var input services.TermInput
+	if r.StartsOn != "" {
+		t, _ := time.Parse("2006-01-02", r.StartsOn)
+		input.StartsOn = &t
+	}
+	if r.EndsOn != "" {
+		t, _ := time.Parse("2006-01-02", r.EndsOn)
+		input.EndsOn = &t
+	}
+	if input.StartsOn != nil && input.EndsOn != nil && input.EndsOn.Before(*input.StartsOn) {
+		return input, false
+	}
+	return input, true
+}
+
+// respondOfferingError writes the response for a failed academic year or offering change
+func respondOfferingError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrAcademicYearNotFound), errors.Is(err, services.ErrTermNotFound),
+		errors.Is(err, services.ErrSubjectNotFound), errors.Is(err, services.ErrOfferingNotFound),
+		errors.Is(err, services.ErrExamDateNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, services.ErrAcademicYearExists), errors.Is(err, services.ErrAcademicYearInUse),
+		errors.Is(err, services.ErrOfferingExists):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, services.ErrTeacherNotFound), errors.Is(err, services.ErrInvalidSubjectTeachers):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": message})
+	}
+}
+
+// ListAcademicYears returns all academic years, newest first, with their terms
+// GET /api/v1/academic-years
+func ListAcademicYears(offerings *services.OfferingService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		years, err := offerings.ListYears()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to fetch academic years"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": years})
+	}
+}
+
+// GetAcademicYear returns an academic year with its terms
+// GET /api/v1/academic-years/:id
+func GetAcademicYear(offerings *services.OfferingService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		yearID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid academic year ID"})
+			return
+		}
+
+		year, err := offerings.GetYear(yearID)
+		if err != nil {
+			respondOfferingError(c, err, "Failed to fetch academic year")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": year})
+	}
+}
+
+// CreateAcademicYear adds an academic year with its winter and summer terms (admin only)
+// POST /api/v1/admin/academic-years
+func CreateAcademicYear(offerings *services.OfferingService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreateAcademicYearRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+
+		terms := make(map[models.TermKind]services.TermInput, 2)
+		for kind, dates := range map[models.TermKind]TermDatesRequest{
+			models.TermWinter: req.Winter,
+			models.TermSummer: req.Summer,
+		} {
+			input, ok := dates.termInput()
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "The " + string(kind) + " term ends before it starts"})
+				return
+			}
+			terms[kind] = input
+		}
+
+		year, err := offerings.CreateYear(req.StartYear, terms)
+		if err != nil {
+			respondOfferingError(c, err, "Failed to create academic year")
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"success": true, "data": year})
+	}
+}
+
+// DeleteAcademicYear removes an academic year without offerings (admin only)
+// DELETE /api/v1/admin/academic-years/:id
+func DeleteAcademicYear(offerings *services.OfferingService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		yearID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid academic year ID"})
+			return
+		}
+
+		if err := offerings.DeleteYear(yearID); err != nil {
+			respondOfferingError(c, err, "Failed to delete academic year")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Academic year deleted"})
+	}
+}
+
+// UpdateTerm sets the start and end dates of a term (admin only)
+// PUT /api/v1/admin/terms/:id
+func UpdateTerm(offerings *services.OfferingService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		termID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid term ID"})
+			return
+		}
+
+		var req TermDatesRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		input, ok := req.termInput()
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "The term ends before it starts"})
+			return
+		}
+
+		term, err := offerings.UpdateTerm(termID, input)
+		if err != nil {
+			respondOfferingError(c, err, "Failed to update term")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": term})
+	}
+}
+
+// ListSubjectOfferings returns the terms a subject is offered in with their teachers
+// GET /api/v1/subjects/:id/offerings
+func ListSubjectOfferings(offerings *services.OfferingService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		subjectID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid subject ID"})
+			return
+		}
+
+		list, err := offerings.ListOfferings(subjectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to fetch offerings"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": list})
+	}
+}
+
+// CreateSubjectOffering offers a subject in a term (admin only)
+// POST /api/v1/admin/subjects/:id/offerings
+func CreateSubjectOffering(offerings *services.OfferingService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		subjectID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid subject ID"})
+			return
+		}
+
+		var req OfferingRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+
+		offering, err := offerings.CreateOffering(subjectID, uuid.MustParse(req.TermID), req.Note, subjectTeacherInputs(req.Teachers))
+		if err != nil {
+			respondOfferingError(c, err, "Failed to create offering")
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"success": true, "data": offering})
+	}
+}
+
+// UpdateSubjectOffering changes the note or teachers of an offering (admin only)
+// PUT /api/v1/admin/offerings/:id
+func UpdateSubjectOffering(offerings *services.OfferingService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		offeringID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid offering ID"})
+			return
+		}
+
+		var req UpdateOfferingRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+
+		var teachers *[]services.SubjectTeacherInput
+		if req.Teachers != nil {
+			inputs := subjectTeacherInputs(*req.Teachers)
+			teachers = &inputs
+		}
+
+		offering, err := offerings.UpdateOffering(offeringID, req.Note, teachers)
+		if err != nil {
+			respondOfferingError(c, err, "Failed to update offering")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": offering})
+	}
+}
+
+// DeleteSubjectOffering removes an offering; its documents stay with the subject (admin only)
+// DELETE /api/v1/admin/offerings/:id
+func DeleteSubjectOffering(offerings *services.OfferingService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		offeringID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid offering ID"})
+			return
+		}
+
+		if err := offerings.DeleteOffering(offeringID); err != nil {
+			respondOfferingError(c, err, "Failed to delete offering")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Offering deleted"})
+	}
+}
+
+// CreateExamDate adds an exam to an offering (admin only)
+// POST /api/v1/admin/offerings/:id/exam-dates
+func CreateExamDate(offerings *services.OfferingService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		offeringID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid offering ID"})
+			return
+		}
+
+		var req ExamDateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+
+		exam, err := offerings.CreateExamDate(offeringID, services.ExamDateInput{
+			StartsAt: req.StartsAt,
+			Location: req.Location,
+			Note:     req.Note,
+		})
+		if err != nil {
+			respondOfferingError(c, err, "Failed to create exam date")
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"success": true, "data": exam})
+	}
+}
+
+// DeleteExamDate removes an exam (admin only)
+// DELETE /api/v1/admin/exam-dates/:id
+func DeleteExamDate(offerings *services.OfferingService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		examID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid exam date ID"})
+			return
+		}
+
+		if err := offerings.DeleteExamDate(examID); err != nil {
+			respondOfferingError(c, err, "Failed to delete exam date")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Exam date deleted"})
+	}
+}
+
+// SetDocumentOffering assigns a document to an offering of its subject, or
+// clears it with a null offering_id (admin only)
+// PUT /api/v1/admin/documents/:id/offering
+func SetDocumentOffering(db *gorm.DB, outbox *services.SearchOutbox) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		docID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid document ID"})
+			return
+		}
+
+		var req SetDocumentOfferingRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+
+		var document models.Document
+		if err := db.First(&document, "id = ?", docID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Document not found"})
+			return
+		}
+
+		var offeringID *uuid.UUID
+		if req.OfferingID != nil {
+			id := uuid.MustParse(*req.OfferingID)
+			if err := services.ValidateDocumentOffering(db, document.SubjectID, id); err != nil {
+				if errors.Is(err, services.ErrOfferingNotFound) {
+					c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid offering for this subject"})
+				} else {
+					c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Database error"})
+				}
+				return
+			}
+			offeringID = &id
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&document).Update("offering_id", offeringID).Error; err != nil {
+				return err
+			}
+			return outbox.Enqueue(tx, models.SearchEntityDocument, document.ID, models.SearchOperationIndex)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to update document"})
+			return
+		}
+		outbox.Notify()
+
+		document.OfferingID = offeringID
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": document})
+	}
+}
@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/P3chys/entoo2-api/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	MaxZipUploadSize = 500 * 1024 * 1024 // 500 MB
+	// MaxZipEntries and MaxZipUncompressedSize bound what an archive may
+	// expand to, so a small zip bomb cannot fill the bucket or tie up a job
+	MaxZipEntries          = 5000
+	MaxZipUncompressedSize = 2 * 1024 * 1024 * 1024 // 2 GB
+)
+
+// BulkUploadFileReport describes the outcome for a single file of a ZIP upload
+type BulkUploadFileReport struct {
+	Path       string     `json:"path"`
+	Status     string     `json:"status"` // uploaded, skipped, failed
+	Type       string     `json:"type,omitempty"`
+	Category   string     `json:"category,omitempty"`
+	DocumentID *uuid.UUID `json:"document_id,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// BulkUploadReport is stored as the result of a zip_upload job
+type BulkUploadReport struct {
+	Uploaded          int                    `json:"uploaded"`
+	Skipped           int                    `json:"skipped"`
+	Failed            int                    `json:"failed"`
+	CreatedCategories []string               `json:"created_categories"`
+	Files             []BulkUploadFileReport `json:"files"`
+}
+
+// UploadDocumentsZip accepts a ZIP archive for a subject and imports its files in the background (admin only)
+// POST /api/v1/admin/subjects/:id/documents/zip
+func UploadDocumentsZip(db *gorm.DB, storage *services.StorageService, extraction *services.ExtractionQueue, outbox *services.SearchOutbox, activity *services.ActivityService, quota *services.QuotaService, jobs *services.JobService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		subjectUUID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid subject ID"})
+			return
+		}
+
+		userUUID, err := uuid.Parse(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Invalid user"})
+			return
+		}
+
+		var subject models.Subject
+		if err := db.First(&subject, "id = ?", subjectUUID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Subject not found"})
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxZipUploadSize+1024*1024)
+		file, header, err := c.Request.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "No file uploaded or file too large"})
+			return
+		}
+		defer file.Close()
+
+		if header.Size > MaxZipUploadSize {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Archive exceeds 500MB limit"})
+			return
+		}
+		if strings.ToLower(filepath.Ext(header.Filename)) != ".zip" {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Only ZIP archives are supported"})
+			return
+		}
+
+		// Persist the archive so it outlives the request
+		tmp, err := os.CreateTemp("", "entoo-upload-*.zip")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to store archive"})
+			return
+		}
+		if _, err := io.Copy(tmp, file); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to store archive"})
+			return
+		}
+		tmp.Close()
+
+		// Reject broken archives up front instead of failing the job later
+		archive, err := zip.OpenReader(tmp.Name())
+		if err != nil {
+			os.Remove(tmp.Name())
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid ZIP archive"})
+			return
+		}
+
+		if msg := checkZipLimits(archive); msg != "" {
+			archive.Close()
+			os.Remove(tmp.Name())
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": msg})
+			return
+		}
+
+		job, err := jobs.CreateJob(models.JobTypeZipUpload, userUUID, &subjectUUID)
+		if err != nil {
+			archive.Close()
+			os.Remove(tmp.Name())
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to create upload job"})
+			return
+		}
+
+		err = jobs.Run(job, nil, func() {
+			processZipUpload(db, storage, extraction, outbox, activity, quota, jobs, job.ID, subjectUUID, userUUID, archive)
+		}, func() {
+			archive.Close()
+			os.Remove(tmp.Name())
+		})
+		if err != nil {
+			_ = jobs.FailJob(job.ID, nil, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to start upload job"})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{"success": true, "data": job})
+	}
+}
+
+// checkZipLimits returns an error message when an archive has too many
+// entries or expands to too much data. The declared sizes can be trusted:
+// archive/zip fails reads that go past them.
+func checkZipLimits(archive *zip.ReadCloser) string {
+	entries := 0
+	var total uint64
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		entries++
+		total += f.UncompressedSize64
+		if entries > MaxZipEntries {
+			return fmt.Sprintf("Archive has more than %d files", MaxZipEntries)
+		}
+		if total > MaxZipUncompressedSize {
+			return "Archive expands to more than 2GB"
+		}
+	}
+	return ""
+}
+
+// GetJob returns the status and progress of a background job (admin only)
+// GET /api/v1/admin/jobs/:id
+func GetJob(jobs *services.JobService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		jobID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid job ID"})
+			return
+		}
+
+		job, err := jobs.GetJob(jobID)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Job not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Database error"})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": job})
+	}
+}
+
+func processZipUpload(db *gorm.DB, storage *services.StorageService, extraction *services.ExtractionQueue, outbox *services.SearchOutbox, activity *services.ActivityService, quota *services.QuotaService, jobs *services.JobService, jobID, subjectID, userID uuid.UUID, archive *zip.ReadCloser) {
+	report := BulkUploadReport{
+		CreatedCategories: []string{},
+		Files:             []BulkUploadFileReport{},
+	}
+
+	var entries []*zip.File
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		entries = append(entries, f)
+	}
+
+	if err := jobs.StartJob(jobID, len(entries)); err != nil {
+		log.Printf("Failed to start zip upload job %s: %v", jobID, err)
+	}
+
+	categories := make(map[string]uuid.UUID)
+
+	for i, f := range entries {
+		entry := importZipEntry(db, storage, extraction, outbox, activity, quota, f, subjectID, userID, categories, &report)
+		report.Files = append(report.Files, entry)
+
+		switch entry.Status {
+		case "uploaded":
+			report.Uploaded++
+		case "skipped":
+			report.Skipped++
+		default:
+			report.Failed++
+		}
+
+		if err := jobs.UpdateProgress(jobID, i+1, report.Failed); err != nil {
+			log.Printf("Failed to update progress of job %s: %v", jobID, err)
+		}
+	}
+
+	if err := jobs.CompleteJob(jobID, report); err != nil {
+		log.Printf("Failed to complete zip upload job %s: %v", jobID, err)
+	}
+}
+
+func importZipEntry(db *gorm.DB, storage *services.StorageService, extraction *services.ExtractionQueue, outbox *services.SearchOutbox, activity *services.ActivityService, quota *services.QuotaService, f *zip.File, subjectID, userID uuid.UUID, categories map[string]uuid.UUID, report *BulkUploadReport) BulkUploadFileReport {
+	name := strings.Trim(strings.ReplaceAll(f.Name, "\\", "/"), "/")
+	entry := BulkUploadFileReport{Path: name}
+
+	// Skip OS metadata that zip tools like to include
+	base := path.Base(name)
+	if strings.HasPrefix(name, "__MACOSX/") || services.IsJunkFile(base) {
+		entry.Status = "skipped"
+		return entry
+	}
+
+	// Top-level folder selects the type, the next folder selects the category
+	parts := strings.Split(name, "/")
+	docType := "other"
+	categoryName := ""
+	if len(parts) > 1 {
+		docType, _ = services.FolderDocumentType(parts[0])
+	}
+	if len(parts) > 2 {
+		categoryName = strings.TrimSpace(parts[1])
+		if len([]rune(categoryName)) > 200 {
+			categoryName = string([]rune(categoryName)[:200])
+		}
+	}
+	entry.Type = docType
+	entry.Category = categoryName
+
+	size := int64(f.UncompressedSize64)
+	mimeType := services.MimeTypeForFile(base)
+	if msg := validateUpload(size, mimeType); msg != "" {
+		entry.Status = "failed"
+		entry.Error = msg
+		return entry
+	}
+
+	if err := quota.CheckUpload(userID, subjectID, size); err != nil {
+		entry.Status = "failed"
+		entry.Error = err.Error()
+		return entry
+	}
+
+	categoryID, created, err := services.ResolveCategory(db, subjectID, userID, docType, categoryName, categories)
+	if err != nil {
+		entry.Status = "failed"
+		entry.Error = "Failed to resolve category"
+		return entry
+	}
+	if created {
+		report.CreatedCategories = append(report.CreatedCategories, docType+"/"+categoryName)
+	}
+
+	newFilename := fmt.Sprintf("%s%s", uuid.New().String(), strings.ToLower(path.Ext(base)))
+
+	rc, err := f.Open()
+	if err != nil {
+		entry.Status = "failed"
+		entry.Error = "Failed to read file from archive"
+		return entry
+	}
+	err = storage.UploadStream(rc, newFilename, size, mimeType)
+	rc.Close()
+	if err != nil {
+		entry.Status = "failed"
+		entry.Error = "Failed to upload file"
+		return entry
+	}
+
+	document := models.Document{
+		ID:           uuid.New(),
+		SubjectID:    subjectID,
+		UploadedBy:   userID,
+		Type:         docType,
+		CategoryID:   categoryID,
+		Filename:     newFilename,
+		OriginalName: base,
+		FileSize:     size,
+		MimeType:     mimeType,
+		MinIOPath:    newFilename,
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := quota.CheckUploadTx(tx, userID, subjectID, size); err != nil {
+			return err
+		}
+		if err := tx.Create(&document).Error; err != nil {
+			return err
+		}
+		if err := extraction.Enqueue(tx, &document); err != nil {
+			return err
+		}
+		return outbox.Enqueue(tx, models.SearchEntityDocument, document.ID, models.SearchOperationIndex)
+	})
+	if err != nil {
+		_ = storage.DeleteFile(newFilename)
+		entry.Status = "failed"
+		entry.Error = "Failed to save document record"
+		var quotaErr *services.QuotaExceededError
+		if errors.As(err, &quotaErr) {
+			entry.Error = err.Error()
+		}
+		return entry
+	}
+
+	extraction.Notify()
+	outbox.Notify()
+
+	_ = activity.CreateActivity(userID, models.ActivityDocumentUploaded, &subjectID, &document.ID, map[string]interface{}{"source": "zip"})
+
+	entry.Status = "uploaded"
+	entry.DocumentID = &document.ID
+	return entry
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/P3chys/entoo2-api/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// MaxCatalogueUploadSize limits the size of an imported catalogue file
+const MaxCatalogueUploadSize = 10 * 1024 * 1024
+
+// catalogueFormat returns the format from the query, falling back to the
+// extension of the file name and then to CSV
+func catalogueFormat(c *gin.Context, filename string) (string, bool) {
+	format := strings.ToLower(c.Query("format"))
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
+	}
+	if format == "" {
+		format = services.CatalogueFormatCSV
+	}
+	return format, format == services.CatalogueFormatCSV || format == services.CatalogueFormatJSON
+}
+
+// ImportSubjectCatalogue creates or updates subjects by code from an
+// uploaded CSV or JSON catalogue. With dry_run=true only the changes are
+// reported (admin only)
+// POST /api/v1/admin/catalogue/import?format=csv|json&dry_run=true&create_semesters=true
+func ImportSubjectCatalogue(catalogue *services.CatalogueService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxCatalogueUploadSize+1024*1024)
+		file, header, err := c.Request.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "No file uploaded or file too large"})
+			return
+		}
+		defer file.Close()
+
+		format, ok := catalogueFormat(c, header.Filename)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Format must be csv or json"})
+			return
+		}
+
+		entries, err := services.ParseCatalogue(file, format)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		if len(entries) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Catalogue has no subjects"})
+			return
+		}
+
+		adminID, _ := uuid.Parse(c.GetString("user_id"))
+		report, err := catalogue.Import(entries, services.CatalogueImportOptions{
+			DryRun:          c.Query("dry_run") == "true",
+			CreateSemesters: c.Query("create_semesters") == "true",
+			AdminID:         adminID,
+		})
+		if errors.Is(err, services.ErrInvalidCatalogue) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"success": false, "error": err.Error(), "data": report})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to import catalogue"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": report})
+	}
+}
+
+// ExportSubjectCatalogue downloads all subjects in the catalogue import
+// format (admin only)
+// GET /api/v1/admin/catalogue/export?format=csv|json
+func ExportSubjectCatalogue(catalogue *services.CatalogueService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format, ok := catalogueFormat(c, "")
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Format must be csv or json"})
+			return
+		}
+
+		entries, err := catalogue.Export()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to export catalogue"})
+			return
+		}
+
+		contentType := "text/csv; charset=utf-8"
+		if format == services.CatalogueFormatJSON {
+			contentType = "application/json; charset=utf-8"
+		}
+		filename := fmt.Sprintf("subjects-%s.%s", time.Now().Format("2006-01-02"), format)
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+		c.Status(http.StatusOK)
+		if err := services.WriteCatalogue(c.Writer, entries, format); err != nil {
+			c.Error(err)
+		}
+	}
+}
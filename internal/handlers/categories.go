@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/P3chys/entoo2-api/internal/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -171,7 +172,7 @@ func ListCategories(db *gorm.DB) gin.HandlerFunc {
 
 // UpdateCategory updates a category (admin only)
 // PUT /api/v1/admin/categories/:id
-func UpdateCategory(db *gorm.DB) gin.HandlerFunc {
+func UpdateCategory(db *gorm.DB, outbox *services.SearchOutbox) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		categoryID := c.Param("id")
 		if categoryID == "" {
@@ -227,10 +228,21 @@ func UpdateCategory(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if len(updates) > 0 {
-			if err := db.Model(&category).Updates(updates).Error; err != nil {
+			err := db.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Model(&category).Updates(updates).Error; err != nil {
+					return err
+				}
+				// Document search records carry the category names
+				if req.NameCS != nil || req.NameEN != nil {
+					return outbox.EnqueueCategoryDocuments(tx, category.ID)
+				}
+				return nil
+			})
+			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to update category"})
 				return
 			}
+			outbox.Notify()
 		}
 
 		// Fetch updated category
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/P3chys/entoo2-api/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -14,7 +15,7 @@ type CreateCommentRequest struct {
 	IsAnonymous bool   `json:"is_anonymous"`
 }
 
-func CreateComment(db *gorm.DB) gin.HandlerFunc {
+func CreateComment(db *gorm.DB, outbox *services.SearchOutbox) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		subjectIDStr := c.Param("id")
 		subjectID, err := uuid.Parse(subjectIDStr)
@@ -43,10 +44,17 @@ func CreateComment(db *gorm.DB) gin.HandlerFunc {
 			IsAnonymous: req.IsAnonymous,
 		}
 
-		if result := db.Create(&comment); result.Error != nil {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&comment).Error; err != nil {
+				return err
+			}
+			return outbox.Enqueue(tx, models.SearchEntityComment, comment.ID, models.SearchOperationIndex)
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
 			return
 		}
+		outbox.Notify()
 
 		// Fetch created comment with user details
 		var createdComment models.Comment
@@ -97,7 +105,7 @@ func GetCommentsBySubject(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
-func DeleteComment(db *gorm.DB) gin.HandlerFunc {
+func DeleteComment(db *gorm.DB, outbox *services.SearchOutbox) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		commentIDStr := c.Param("id")
 		commentID, err := uuid.Parse(commentIDStr)
@@ -125,10 +133,17 @@ func DeleteComment(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		if err := db.Delete(&comment).Error; err != nil {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Delete(&comment).Error; err != nil {
+				return err
+			}
+			return outbox.Enqueue(tx, models.SearchEntityComment, comment.ID, models.SearchOperationDelete)
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
 			return
 		}
+		outbox.Notify()
 
 		c.JSON(http.StatusOK, gin.H{"success": true})
 	}
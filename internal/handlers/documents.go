@@ -1,12 +1,15 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"log"
 
 	"net/http"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/P3chys/entoo2-api/internal/config"
 	"github.com/P3chys/entoo2-api/internal/models"
@@ -30,7 +33,7 @@ var AllowedMimeTypes = map[string]bool{
 	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": true, // xlsx
 }
 
-func UploadDocument(db *gorm.DB, cfg *config.Config, storage *services.StorageService, tika *services.TextExtractionService, search *services.SearchService, activity *services.ActivityService) gin.HandlerFunc {
+func UploadDocument(db *gorm.DB, cfg *config.Config, storage *services.StorageService, extraction *services.ExtractionQueue, outbox *services.SearchOutbox, activity *services.ActivityService, quota *services.QuotaService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		subjectID := c.Param("id")
 		userID := c.GetString("user_id")
@@ -62,16 +65,10 @@ func UploadDocument(db *gorm.DB, cfg *config.Config, storage *services.StorageSe
 		categoryIDStr := c.Request.FormValue("category_id")
 		var categoryID *uuid.UUID
 
-		// Validate file size
-		if header.Size > MaxFileSize {
-			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "File exceeds 50MB limit"})
-			return
-		}
-
-		// Validate MIME type
+		// Validate file size and MIME type
 		mimeType := header.Header.Get("Content-Type")
-		if !AllowedMimeTypes[mimeType] {
-			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unsupported file type"})
+		if msg := validateUpload(header.Size, mimeType); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": msg})
 			return
 		}
 
@@ -118,6 +115,32 @@ func UploadDocument(db *gorm.DB, cfg *config.Config, storage *services.StorageSe
 			}
 		}
 
+		// Optional offering (term) the material is from
+		var offeringID *uuid.UUID
+		if offeringIDStr := c.Request.FormValue("offering_id"); offeringIDStr != "" {
+			id, err := uuid.Parse(offeringIDStr)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid offering ID"})
+				return
+			}
+			if err := services.ValidateDocumentOffering(db, subjectUUID, id); err != nil {
+				if errors.Is(err, services.ErrOfferingNotFound) {
+					c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid offering for this subject"})
+				} else {
+					c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Database error"})
+				}
+				return
+			}
+			offeringID = &id
+		}
+
+		// Enforce storage quotas
+		userUUID, _ := uuid.Parse(userID)
+		if err := quota.CheckUpload(userUUID, subjectUUID, header.Size); err != nil {
+			respondQuotaError(c, err)
+			return
+		}
+
 		// Generate unique filename
 		ext := filepath.Ext(header.Filename)
 		newFilename := fmt.Sprintf("%s%s", uuid.New().String(), ext)
@@ -128,18 +151,8 @@ func UploadDocument(db *gorm.DB, cfg *config.Config, storage *services.StorageSe
 			return
 		}
 
-		// Extract text (async, best effort)
-		var extractedText string
-		if IsTextExtractable(mimeType) {
-			text, err := tika.ExtractText(file)
-			if err == nil {
-				extractedText = text
-			}
-		}
-
 		// Create document record
 		docID := uuid.New()
-		userUUID, _ := uuid.Parse(userID)
 
 		document := models.Document{
 			ID:           docID,
@@ -147,25 +160,40 @@ func UploadDocument(db *gorm.DB, cfg *config.Config, storage *services.StorageSe
 			UploadedBy:   userUUID,
 			Type:         docType,
 			CategoryID:   categoryID,
+			OfferingID:   offeringID,
 			Filename:     newFilename,
 			OriginalName: header.Filename,
 			FileSize:     header.Size,
 			MimeType:     mimeType,
 			MinIOPath:    newFilename,
-			ContentText:  extractedText,
 		}
 
-		if err := db.Create(&document).Error; err != nil {
+		// Text is extracted by the background queue; the job is created together with the row
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := quota.CheckUploadTx(tx, userUUID, subjectUUID, header.Size); err != nil {
+				return err
+			}
+			if err := tx.Create(&document).Error; err != nil {
+				return err
+			}
+			if err := extraction.Enqueue(tx, &document); err != nil {
+				return err
+			}
+			return outbox.Enqueue(tx, models.SearchEntityDocument, document.ID, models.SearchOperationIndex)
+		})
+		if err != nil {
 			// Cleanup MinIO
 			_ = storage.DeleteFile(newFilename)
+			var quotaErr *services.QuotaExceededError
+			if errors.As(err, &quotaErr) {
+				respondQuotaError(c, err)
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to save document record"})
 			return
 		}
-
-		// Index in Meilisearch (async)
-		go func() {
-			_ = search.IndexDocument(document)
-		}()
+		extraction.Notify()
+		outbox.Notify()
 
 		// Create activity
 		go func() {
@@ -187,11 +215,28 @@ func ListDocuments(db *gorm.DB) gin.HandlerFunc {
 		
 		// Join with favorites to get status and sort
 		// Order by (user_favorite_documents.user_id IS NOT NULL) DESC
-		query := db.Preload("Uploader").Preload("Category").
+		query := db.Preload("Uploader").Preload("Category").Preload("Offering.Term.AcademicYear").
 			Select("documents.*, (CASE WHEN ufd.user_id IS NOT NULL THEN true ELSE false END) as is_favorite").
 			Joins("LEFT JOIN user_favorite_documents ufd ON documents.id = ufd.document_id AND ufd.user_id = ?", userIDStr).
 			Where("documents.subject_id = ?", subjectID)
 
+		// Optional filter by the offering, or the academic year and term, the material is from
+		if offeringID := c.Query("offering_id"); offeringID != "" {
+			if _, err := uuid.Parse(offeringID); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid offering_id"})
+				return
+			}
+			query = query.Where("documents.offering_id = ?", offeringID)
+		}
+		termFilter, err := services.ParseTermFilter(c.Query("academic_year_id"), c.Query("term"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		if !termFilter.IsZero() {
+			query = query.Where("documents.offering_id IN (?)", termFilter.OfferingIDs(db))
+		}
+
 		query = query.Limit(limit).Offset(offset).Order("is_favorite DESC, documents.created_at desc")
 
 		if err := query.Find(&documents).Error; err != nil {
@@ -207,7 +252,7 @@ func GetDocument(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		docID := c.Param("id")
 		var document models.Document
-		if err := db.Preload("Uploader").Preload("Subject").First(&document, "id = ?", docID).Error; err != nil {
+		if err := db.Preload("Uploader").Preload("Subject").Preload("Offering.Term.AcademicYear").First(&document, "id = ?", docID).Error; err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Document not found"})
 			return
 		}
@@ -215,6 +260,57 @@ func GetDocument(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+// SearchDocument finds the pages of a document matching a query, with highlighted snippets
+// GET /api/v1/documents/:id/search?q=
+func SearchDocument(db *gorm.DB, pages *services.DocumentPageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		docID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid document ID"})
+			return
+		}
+
+		query := c.Query("q")
+		if query == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Query parameter 'q' is required"})
+			return
+		}
+
+		var document models.Document
+		if err := db.Select("id", "extraction_status").First(&document, "id = ?", docID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Document not found"})
+			return
+		}
+
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSearchLimit)))
+		if limit <= 0 || limit > maxSearchLimit {
+			limit = defaultSearchLimit
+		}
+		offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+		if offset < 0 {
+			offset = 0
+		}
+
+		hits, total, err := pages.Search(docID, query, c.Query("exact") == "true", limit, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Search failed"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data": gin.H{
+				"document_id":       document.ID,
+				"extraction_status": document.ExtractionStatus,
+				"hits":              hits,
+				"total":             total,
+				"limit":             limit,
+				"offset":            offset,
+			},
+		})
+	}
+}
+
 func DownloadDocument(db *gorm.DB, storage *services.StorageService, activity *services.ActivityService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		docID := c.Param("id")
@@ -258,7 +354,7 @@ func DownloadDocument(db *gorm.DB, storage *services.StorageService, activity *s
 	}
 }
 
-func DeleteDocument(db *gorm.DB, storage *services.StorageService, search *services.SearchService, activity *services.ActivityService) gin.HandlerFunc {
+func DeleteDocument(db *gorm.DB, outbox *services.SearchOutbox, activity *services.ActivityService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		docID := c.Param("id")
 		userID := c.GetString("user_id")
@@ -289,59 +385,240 @@ func DeleteDocument(db *gorm.DB, storage *services.StorageService, search *servi
 			}
 		}
 
-		// Delete from MinIO
-		if err := storage.DeleteFile(document.MinIOPath); err != nil {
-			// Log error but continue
-			fmt.Printf("Failed to delete from MinIO: %v\n", err)
+		// Move to trash; the file stays in MinIO until the purge job removes it
+		userUUID, _ := uuid.Parse(userID)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&document).Update("deleted_by", userUUID).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&document).Error; err != nil {
+				return err
+			}
+			return outbox.Enqueue(tx, models.SearchEntityDocument, document.ID, models.SearchOperationDelete)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to delete document record"})
+			return
 		}
 
-		// Delete from Meilisearch
-		go func() {
-			_ = search.DeleteDocument(document.ID.String())
-		}()
+		outbox.Notify()
 
 		// Create activity
 		go func() {
-			userUUID, _ := uuid.Parse(userID)
 			_ = activity.CreateActivity(userUUID, models.ActivityDocumentDeleted, &document.SubjectID, &document.ID, nil)
 		}()
 
-		// Delete from DB
-		if err := db.Delete(&document).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to delete document record"})
+		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Document moved to trash"})
+	}
+}
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// Search searches documents and subjects with paging, sorting, filters and facets.
+// Document hits list the pages on which all query terms occur.
+// A new search is logged anonymously and its ID returned as search_id for click reporting.
+// GET /api/v1/search
+func Search(search services.SearchService, analytics *services.SearchAnalyticsService, pages *services.DocumentPageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := c.Query("q")
+		if query == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Query parameter 'q' is required"})
+			return
+		}
+
+		// "scope" selects what to search; "type" is still accepted for it for older clients
+		scope := c.Query("scope")
+		docType := c.Query("type")
+		switch docType {
+		case "all", "documents", "subjects", "questions", "comments":
+			if scope == "" {
+				scope = docType
+			}
+			docType = ""
+		case "", "lecture", "seminar", "other":
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid document type"})
+			return
+		}
+		switch scope {
+		case "", "all", "documents", "subjects", "questions", "comments":
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid search scope"})
+			return
+		}
+
+		opts := services.DocumentSearchOptions{
+			Query:      query,
+			MimeType:   c.Query("mime_type"), // Filter by file type (e.g., "application/pdf")
+			Type:       docType,
+			ExactMatch: c.Query("exact") == "true", // Exact match mode (disables fuzzy)
+			Sort:       c.DefaultQuery("sort", services.SearchSortRelevance),
+		}
+
+		switch opts.Sort {
+		case services.SearchSortRelevance, services.SearchSortDateDesc, services.SearchSortDateAsc,
+			services.SearchSortSizeDesc, services.SearchSortSizeAsc:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid sort order"})
+			return
+		}
+
+		// Paging: either limit/offset or page (1-based) with limit as page size
+		opts.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSearchLimit)))
+		if opts.Limit <= 0 || opts.Limit > maxSearchLimit {
+			opts.Limit = defaultSearchLimit
+		}
+		opts.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+		if page, err := strconv.Atoi(c.Query("page")); err == nil && page > 0 {
+			opts.Offset = (page - 1) * opts.Limit
+		}
+		if opts.Offset < 0 {
+			opts.Offset = 0
+		}
+
+		// ID filters
+		for param, target := range map[string]*string{
+			"subject_id":       &opts.SubjectID,
+			"semester_id":      &opts.SemesterID,
+			"academic_year_id": &opts.AcademicYearID,
+			"category_id":      &opts.CategoryID,
+			"uploaded_by":      &opts.UploadedBy,
+		} {
+			if value := c.Query(param); value != "" {
+				id, err := uuid.Parse(value)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid " + param})
+					return
+				}
+				*target = id.String()
+			}
+		}
+
+		// Term of the offerings documents belong to and subjects are offered in
+		opts.Term = c.Query("term")
+		if opts.Term != "" && !services.IsValidTermKind(models.TermKind(opts.Term)) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid term"})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Document deleted"})
+		// Date range (YYYY-MM-DD or RFC 3339); date_to includes the whole day
+		for param, target := range map[string]**time.Time{
+			"date_from": &opts.CreatedFrom,
+			"date_to":   &opts.CreatedTo,
+		} {
+			value := c.Query(param)
+			if value == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				t, err = time.Parse("2006-01-02", value)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid " + param})
+					return
+				}
+				if param == "date_to" {
+					t = t.Add(24*time.Hour - time.Second)
+				}
+			}
+			*target = &t
+		}
+
+		// File size range in bytes
+		for param, target := range map[string]*int64{
+			"min_size": &opts.MinSize,
+			"max_size": &opts.MaxSize,
+		} {
+			if value := c.Query(param); value != "" {
+				size, err := strconv.ParseInt(value, 10, 64)
+				if err != nil || size < 0 {
+					c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid " + param})
+					return
+				}
+				*target = size
+			}
+		}
+
+		result, err := search.SearchAll(scope, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Search failed"})
+			return
+		}
+
+		if hits, ok := result["documents"].([]interface{}); ok {
+			if err := pages.AddPageNumbers(hits, opts.Query, opts.ExactMatch); err != nil {
+				log.Printf("Failed to find matching pages: %v", err)
+			}
+		}
+
+		// Only the first page is logged, so paging through results is not counted as new searches
+		if opts.Offset == 0 {
+			searchID, err := analytics.Record(scope, opts, result)
+			if err != nil {
+				log.Printf("Failed to log search: %v", err)
+			} else {
+				result["search_id"] = searchID
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": result})
 	}
 }
 
-func Search(search *services.SearchService) gin.HandlerFunc {
+const (
+	suggestLimit    = 5
+	suggestCacheTTL = 30 * time.Second
+	maxSuggestQuery = 100
+)
+
+// SearchSuggest returns autocomplete suggestions for a search box prefix.
+// Results for hot prefixes are cached in Redis for a short time.
+// GET /api/v1/search/suggest
+func SearchSuggest(search services.SearchService, cache *services.CacheService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		query := c.Query("q")
+		query := services.NormalizeSuggestQuery(c.Query("q"))
 		if query == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Query parameter 'q' is required"})
 			return
 		}
+		if len(query) > maxSuggestQuery {
+			query = strings.ToValidUTF8(query[:maxSuggestQuery], "")
+		}
 
-		searchType := c.Query("type")        // "all", "documents", "subjects"
-		subjectID := c.Query("subject_id")   // Filter by specific subject
-		mimeType := c.Query("mime_type")     // Filter by file type (e.g., "application/pdf")
-		exactMatch := c.Query("exact") == "true" // Exact match mode (disables fuzzy)
+		cacheKey := "search:suggest:" + query
+		var suggestions services.Suggestions
+		if found, _ := cache.GetJSON(c.Request.Context(), cacheKey, &suggestions); found {
+			c.JSON(http.StatusOK, gin.H{"success": true, "data": suggestions})
+			return
+		}
 
-		result, err := search.SearchAll(query, searchType, subjectID, mimeType, exactMatch)
+		result, err := search.Suggest(query, suggestLimit)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Search failed"})
 			return
 		}
 
+		_ = cache.SetJSON(c.Request.Context(), cacheKey, result, suggestCacheTTL)
+
 		c.JSON(http.StatusOK, gin.H{"success": true, "data": result})
 	}
 }
 
+// validateUpload checks the size and MIME type of an uploaded file and returns
+// a user-facing error message, or an empty string if the file is acceptable
+func validateUpload(size int64, mimeType string) string {
+	if size > MaxFileSize {
+		return "File exceeds 50MB limit"
+	}
+	if !AllowedMimeTypes[mimeType] {
+		return "Unsupported file type"
+	}
+	return ""
+}
+
 func IsTextExtractable(mimeType string) bool {
-	return mimeType == "application/pdf" || 
-		mimeType == "application/vnd.openxmlformats-officedocument.wordprocessingml.document" || 
-		mimeType == "application/vnd.openxmlformats-officedocument.presentationml.presentation" ||
-		strings.HasPrefix(mimeType, "text/")
+	return services.IsTextExtractable(mimeType)
 }
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/P3chys/entoo2-api/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type EnrollRequest struct {
+	TermID string `json:"term_id" binding:"omitempty,uuid"`
+}
+
+// optionalTermID parses an optional term ID; ok is false for a malformed one
+func optionalTermID(value string) (*uuid.UUID, bool) {
+	if value == "" {
+		return nil, true
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return nil, false
+	}
+	return &id, true
+}
+
+// respondEnrollmentError writes the response for a failed enrollment change
+func respondEnrollmentError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrSubjectNotFound), errors.Is(err, services.ErrTermNotFound),
+		errors.Is(err, services.ErrEnrollmentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, services.ErrNoCurrentTerm):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, services.ErrAlreadyEnrolled):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": message})
+	}
+}
+
+// EnrollSubject enrolls the user in a subject for a term, by default the
+// current one
+// POST /api/v1/subjects/:id/enroll
+func EnrollSubject(enrollments *services.EnrollmentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := uuid.Parse(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Invalid user"})
+			return
+		}
+		subjectID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid subject ID"})
+			return
+		}
+
+		// The body is optional; only an empty one skips it
+		var req EnrollRequest
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		termID, _ := optionalTermID(req.TermID)
+
+		enrollment, err := enrollments.Enroll(userID, subjectID, termID)
+		if err != nil {
+			respondEnrollmentError(c, err, "Failed to enroll in subject")
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"success": true, "data": enrollment})
+	}
+}
+
+// UnenrollSubject removes the user's enrollment in a subject for term_id, or
+// in every term
+// DELETE /api/v1/subjects/:id/enroll?term_id=
+func UnenrollSubject(enrollments *services.EnrollmentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := uuid.Parse(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Invalid user"})
+			return
+		}
+		subjectID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid subject ID"})
+			return
+		}
+		termID, ok := optionalTermID(c.Query("term_id"))
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid term ID"})
+			return
+		}
+
+		if err := enrollments.Unenroll(userID, subjectID, termID); err != nil {
+			respondEnrollmentError(c, err, "Failed to leave subject")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Enrollment removed"})
+	}
+}
+
+// ListMySubjects returns the subjects the user is enrolled in, newest term
+// first, optionally only in an academic year (academic_year_id) or term
+// (term=winter|summer)
+// GET /api/v1/me/subjects
+func ListMySubjects(enrollments *services.EnrollmentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := uuid.Parse(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Invalid user"})
+			return
+		}
+		filter, err := services.ParseTermFilter(c.Query("academic_year_id"), c.Query("term"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+
+		list, err := enrollments.MySubjects(userID, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to fetch subjects"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": list})
+	}
+}
+
+// GetDashboard returns the user's enrolled subjects of a term (term_id, by
+// default the current one) with their latest documents, unanswered questions
+// and upcoming exams
+// GET /api/v1/me/dashboard
+func GetDashboard(enrollments *services.EnrollmentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := uuid.Parse(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Invalid user"})
+			return
+		}
+		termID, ok := optionalTermID(c.Query("term_id"))
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid term ID"})
+			return
+		}
+
+		dashboard, err := enrollments.Dashboard(userID, termID)
+		if err != nil {
+			respondEnrollmentError(c, err, "Failed to fetch dashboard")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": dashboard})
+	}
+}
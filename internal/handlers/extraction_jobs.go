@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/P3chys/entoo2-api/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ListExtractionJobs lists text extraction jobs, optionally filtered by status (admin only)
+// GET /api/v1/admin/extraction-jobs
+func ListExtractionJobs(extraction *services.ExtractionQueue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+		if limit <= 0 || limit > 200 {
+			limit = 50
+		}
+
+		status := c.Query("status")
+		switch models.ExtractionJobStatus(status) {
+		case "", models.ExtractionJobQueued, models.ExtractionJobRunning, models.ExtractionJobDone, models.ExtractionJobFailed:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid status"})
+			return
+		}
+
+		jobs, total, err := extraction.ListJobs(status, limit, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to fetch extraction jobs"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": jobs, "total": total})
+	}
+}
+
+// RetryExtractionJob re-queues a single extraction job (admin only)
+// POST /api/v1/admin/extraction-jobs/:id/retry
+func RetryExtractionJob(extraction *services.ExtractionQueue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		jobID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid job ID"})
+			return
+		}
+
+		if err := extraction.RetryJob(jobID); err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Extraction job not found"})
+			} else {
+				c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Extraction job queued"})
+	}
+}
+
+// RetryFailedExtractionJobs re-queues every failed extraction job (admin only)
+// POST /api/v1/admin/extraction-jobs/retry-failed
+func RetryFailedExtractionJobs(extraction *services.ExtractionQueue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		count, err := extraction.RetryFailed()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to retry extraction jobs"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"queued": count}})
+	}
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ListNotifications lists the current user's notifications, newest first
+// GET /api/v1/notifications
+func ListNotifications(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("user_id")
+
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+		if limit <= 0 || limit > 100 {
+			limit = 20
+		}
+
+		query := db.Model(&models.Notification{}).Where("user_id = ?", userID)
+		if c.Query("unread") == "true" {
+			query = query.Where("read_at IS NULL")
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to fetch notifications"})
+			return
+		}
+
+		var notifications []models.Notification
+		if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&notifications).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to fetch notifications"})
+			return
+		}
+
+		var unread int64
+		if err := db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&unread).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to fetch notifications"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": notifications, "total": total, "unread": unread})
+	}
+}
+
+// MarkNotificationRead marks one notification as read
+// POST /api/v1/notifications/:id/read
+func MarkNotificationRead(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, err := uuid.Parse(c.Param("id")); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid notification ID"})
+			return
+		}
+
+		var notification models.Notification
+		if err := db.Where("id = ? AND user_id = ?", c.Param("id"), c.GetString("user_id")).First(&notification).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Notification not found"})
+			return
+		}
+
+		if notification.ReadAt == nil {
+			now := time.Now()
+			if err := db.Model(&notification).Update("read_at", now).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to update notification"})
+				return
+			}
+			notification.ReadAt = &now
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": notification})
+	}
+}
+
+// MarkAllNotificationsRead marks all of the current user's notifications as read
+// POST /api/v1/notifications/read-all
+func MarkAllNotificationsRead(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := db.Model(&models.Notification{}).
+			Where("user_id = ? AND read_at IS NULL", c.GetString("user_id")).
+			Update("read_at", time.Now())
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to update notifications"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"updated": result.RowsAffected}})
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"path/filepath"
@@ -18,7 +19,7 @@ type CreateQuestionRequest struct {
 	IsAnonymous bool   `json:"is_anonymous"`
 }
 
-func CreateQuestion(db *gorm.DB) gin.HandlerFunc {
+func CreateQuestion(db *gorm.DB, outbox *services.SearchOutbox) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		subjectIDStr := c.Param("id")
 		subjectID, err := uuid.Parse(subjectIDStr)
@@ -47,10 +48,17 @@ func CreateQuestion(db *gorm.DB) gin.HandlerFunc {
 			IsAnonymous: req.IsAnonymous,
 		}
 
-		if result := db.Create(&question); result.Error != nil {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&question).Error; err != nil {
+				return err
+			}
+			return outbox.Enqueue(tx, models.SearchEntityQuestion, question.ID, models.SearchOperationIndex)
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create question"})
 			return
 		}
+		outbox.Notify()
 
 		// Preload User for response
 		if err := db.Preload("User").First(&question, question.ID).Error; err == nil {
@@ -102,7 +110,7 @@ func GetQuestionsBySubject(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
-func CreateAnswer(db *gorm.DB, cfg *config.Config, storage *services.StorageService, tika *services.TextExtractionService, search *services.SearchService) gin.HandlerFunc {
+func CreateAnswer(db *gorm.DB, cfg *config.Config, storage *services.StorageService, extraction *services.ExtractionQueue, outbox *services.SearchOutbox, quota *services.QuotaService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		questionIDStr := c.Param("id")
 		questionID, err := uuid.Parse(questionIDStr)
@@ -145,14 +153,15 @@ func CreateAnswer(db *gorm.DB, cfg *config.Config, storage *services.StorageServ
 			// File is present, handle upload
 			defer file.Close()
 
-			if header.Size > MaxFileSize {
-				c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "File exceeds 50MB limit"})
+			mimeType := header.Header.Get("Content-Type")
+			if msg := validateUpload(header.Size, mimeType); msg != "" {
+				c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": msg})
 				return
 			}
 
-			mimeType := header.Header.Get("Content-Type")
-			if !AllowedMimeTypes[mimeType] {
-				c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unsupported file type"})
+			// Attachments count towards the uploader's and the subject's quota
+			if err := quota.CheckUpload(userID, question.SubjectID, header.Size); err != nil {
+				respondQuotaError(c, err)
 				return
 			}
 
@@ -164,14 +173,6 @@ func CreateAnswer(db *gorm.DB, cfg *config.Config, storage *services.StorageServ
 				return
 			}
 
-			var extractedText string
-			if IsTextExtractable(mimeType) {
-				text, err := tika.ExtractText(file)
-				if err == nil {
-					extractedText = text
-				}
-			}
-
 			docID := uuid.New()
 			// Link document to the Subject of the Question
 			document := models.Document{
@@ -184,21 +185,33 @@ func CreateAnswer(db *gorm.DB, cfg *config.Config, storage *services.StorageServ
 				FileSize:     header.Size,
 				MimeType:     mimeType,
 				MinIOPath:    newFilename,
-				ContentText:  extractedText,
 				// AnswerID will be set after creating Answer? Or we set it here if we had the Answer ID. 
 				// Circular diff. Let's create doc first.
 			}
 
-			if err := db.Create(&document).Error; err != nil {
+			err := db.Transaction(func(tx *gorm.DB) error {
+				if err := quota.CheckUploadTx(tx, userID, question.SubjectID, header.Size); err != nil {
+					return err
+				}
+				if err := tx.Create(&document).Error; err != nil {
+					return err
+				}
+				if err := extraction.Enqueue(tx, &document); err != nil {
+					return err
+				}
+				return outbox.Enqueue(tx, models.SearchEntityDocument, document.ID, models.SearchOperationIndex)
+			})
+			if err != nil {
 				_ = storage.DeleteFile(newFilename)
+				var quotaErr *services.QuotaExceededError
+				if errors.As(err, &quotaErr) {
+					respondQuotaError(c, err)
+					return
+				}
 				c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to save document record"})
 				return
 			}
-
-			// Index
-			go func() {
-				_ = search.IndexDocument(document)
-			}()
+			extraction.Notify()
 
 			documentID = &docID
 		}
@@ -210,7 +223,14 @@ func CreateAnswer(db *gorm.DB, cfg *config.Config, storage *services.StorageServ
 			DocumentID: documentID,
 		}
 
-		if err := db.Create(&answer).Error; err != nil {
+		// Answers are indexed as part of their question
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&answer).Error; err != nil {
+				return err
+			}
+			return outbox.Enqueue(tx, models.SearchEntityQuestion, questionID, models.SearchOperationIndex)
+		})
+		if err != nil {
 			// If we created a document but failed to create answer, we should probably rollback document?
 			// For simplicity, leaving orphan document for now or could delete it.
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create answer"})
@@ -222,6 +242,8 @@ func CreateAnswer(db *gorm.DB, cfg *config.Config, storage *services.StorageServ
 			db.Model(&models.Document{}).Where("id = ?", documentID).Update("answer_id", answer.ID)
 		}
 
+		outbox.Notify()
+
 		// Preload for response (ignore error, just return basic if preload fails)
 		_ = db.Preload("User").Preload("Document").First(&answer, answer.ID).Error
 
@@ -229,7 +251,7 @@ func CreateAnswer(db *gorm.DB, cfg *config.Config, storage *services.StorageServ
 	}
 }
 
-func DeleteQuestion(db *gorm.DB) gin.HandlerFunc {
+func DeleteQuestion(db *gorm.DB, outbox *services.SearchOutbox) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Basic delete implementation
 		questionIDStr := c.Param("id")
@@ -255,10 +277,17 @@ func DeleteQuestion(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		if err := db.Delete(&question).Error; err != nil {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Delete(&question).Error; err != nil {
+				return err
+			}
+			return outbox.Enqueue(tx, models.SearchEntityQuestion, question.ID, models.SearchOperationDelete)
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete question"})
 			return
 		}
+		outbox.Notify()
 
 		c.JSON(http.StatusOK, gin.H{"success": true})
 	}
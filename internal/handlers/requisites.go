@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/P3chys/entoo2-api/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type StudyPlanEntryRequest struct {
+	Status string `json:"status" binding:"required,oneof=passed planned"`
+}
+
+// GetSubjectPrerequisites returns the prerequisites and corequisites of a
+// subject and, transitively, of those subjects
+// GET /api/v1/subjects/:id/prerequisites
+func GetSubjectPrerequisites(requisites *services.RequisiteService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		subjectID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid subject ID"})
+			return
+		}
+
+		graph, err := requisites.Graph(subjectID)
+		if errors.Is(err, services.ErrSubjectNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Subject not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to fetch prerequisites"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": graph})
+	}
+}
+
+// GetStudyPlan returns the user's passed and planned subjects with warnings
+// for unmet prerequisites
+// GET /api/v1/me/study-plan
+func GetStudyPlan(requisites *services.RequisiteService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := uuid.Parse(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Invalid user"})
+			return
+		}
+
+		plan, err := requisites.StudyPlan(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to fetch study plan"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": plan})
+	}
+}
+
+// SetStudyPlanEntry marks a subject as passed or planned and returns the
+// updated study plan
+// PUT /api/v1/me/study-plan/:id
+func SetStudyPlanEntry(requisites *services.RequisiteService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := uuid.Parse(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Invalid user"})
+			return
+		}
+		subjectID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid subject ID"})
+			return
+		}
+
+		var req StudyPlanEntryRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+
+		_, err = requisites.SetStudyPlanEntry(userID, subjectID, models.StudyPlanStatus(req.Status))
+		if errors.Is(err, services.ErrSubjectNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Subject not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to update study plan"})
+			return
+		}
+
+		plan, err := requisites.StudyPlan(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to fetch study plan"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": plan})
+	}
+}
+
+// RemoveStudyPlanEntry removes a subject from the user's study plan
+// DELETE /api/v1/me/study-plan/:id
+func RemoveStudyPlanEntry(requisites *services.RequisiteService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := uuid.Parse(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Invalid user"})
+			return
+		}
+		subjectID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid subject ID"})
+			return
+		}
+
+		removed, err := requisites.RemoveStudyPlanEntry(userID, subjectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to update study plan"})
+			return
+		}
+		if !removed {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Subject is not in the study plan"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Subject removed from study plan"})
+	}
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MaxSavedSearchesPerUser limits how many saved searches a user can keep
+const MaxSavedSearchesPerUser = 20
+
+// SavedSearchRequest defines the request body for creating or updating a saved search.
+// The filters use the same names as the query parameters of GET /search.
+type SavedSearchRequest struct {
+	Name         string     `json:"name" binding:"required,max=100"`
+	Query        string     `json:"query" binding:"required,max=200"`
+	SubjectID    *uuid.UUID `json:"subject_id"`
+	SemesterID   *uuid.UUID `json:"semester_id"`
+	CategoryID   *uuid.UUID `json:"category_id"`
+	Type         string     `json:"type" binding:"omitempty,oneof=lecture seminar other"`
+	MimeType     string     `json:"mime_type" binding:"omitempty,max=100"`
+	ExactMatch   bool       `json:"exact"`
+	EmailEnabled *bool      `json:"email_enabled"`
+}
+
+func (r SavedSearchRequest) apply(saved *models.SavedSearch) {
+	saved.Name = r.Name
+	saved.Query = r.Query
+	saved.SubjectID = r.SubjectID
+	saved.SemesterID = r.SemesterID
+	saved.CategoryID = r.CategoryID
+	saved.Type = r.Type
+	saved.MimeType = r.MimeType
+	saved.ExactMatch = r.ExactMatch
+	if r.EmailEnabled != nil {
+		saved.EmailEnabled = *r.EmailEnabled
+	}
+}
+
+// ListSavedSearches lists the current user's saved searches
+// GET /api/v1/search/saved
+func ListSavedSearches(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("user_id")
+
+		var searches []models.SavedSearch
+		if err := db.Where("user_id = ?", userID).Order("created_at DESC").Find(&searches).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to fetch saved searches"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": searches})
+	}
+}
+
+// CreateSavedSearch saves a search for new-match notifications
+// POST /api/v1/search/saved
+func CreateSavedSearch(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userUUID, err := uuid.Parse(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Invalid user"})
+			return
+		}
+
+		var req SavedSearchRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+
+		var count int64
+		if err := db.Model(&models.SavedSearch{}).Where("user_id = ?", userUUID).Count(&count).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Database error"})
+			return
+		}
+		if count >= MaxSavedSearchesPerUser {
+			c.JSON(http.StatusConflict, gin.H{"success": false, "error": "Saved search limit reached"})
+			return
+		}
+
+		// Only documents uploaded after saving trigger notifications
+		saved := models.SavedSearch{
+			UserID:       userUUID,
+			EmailEnabled: true,
+			LastRunAt:    time.Now(),
+		}
+		req.apply(&saved)
+
+		if err := db.Create(&saved).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to save search"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"success": true, "data": saved})
+	}
+}
+
+// UpdateSavedSearch replaces the query and filters of a saved search
+// PUT /api/v1/search/saved/:id
+func UpdateSavedSearch(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, err := uuid.Parse(c.Param("id")); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid saved search ID"})
+			return
+		}
+
+		var saved models.SavedSearch
+		if err := db.Where("id = ? AND user_id = ?", c.Param("id"), c.GetString("user_id")).First(&saved).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Saved search not found"})
+			return
+		}
+
+		var req SavedSearchRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		req.apply(&saved)
+
+		if err := db.Save(&saved).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to update saved search"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": saved})
+	}
+}
+
+// DeleteSavedSearch deletes a saved search
+// DELETE /api/v1/search/saved/:id
+func DeleteSavedSearch(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, err := uuid.Parse(c.Param("id")); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid saved search ID"})
+			return
+		}
+
+		result := db.Where("id = ? AND user_id = ?", c.Param("id"), c.GetString("user_id")).Delete(&models.SavedSearch{})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to delete saved search"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Saved search not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Saved search deleted"})
+	}
+}
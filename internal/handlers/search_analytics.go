@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/P3chys/entoo2-api/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultSearchReportDays = 30
+	maxSearchReportDays     = 366
+)
+
+type SearchClickRequest struct {
+	ResultType string `json:"result_type" binding:"required,oneof=document subject question comment"`
+	ResultID   string `json:"result_id" binding:"required,uuid"`
+	// Position is the 1-based rank of the result in the list
+	Position int `json:"position" binding:"omitempty,min=1"`
+}
+
+// RecordSearchClick records which result was opened from a search
+// POST /api/v1/search/:id/click
+func RecordSearchClick(analytics *services.SearchAnalyticsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		searchID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid search ID"})
+			return
+		}
+
+		var req SearchClickRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+
+		err = analytics.RecordClick(searchID, req.ResultType, uuid.MustParse(req.ResultID), req.Position)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Search not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to record click"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	}
+}
+
+// GetSearchAnalytics reports the top queries, queries without results and
+// the click-through rate per subject for a date range (admin only)
+// GET /api/v1/admin/search/analytics?from=YYYY-MM-DD&to=YYYY-MM-DD&subject_id=&limit=
+func GetSearchAnalytics(analytics *services.SearchAnalyticsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		opts := services.SearchAnalyticsReportOptions{
+			To: time.Now().UTC(),
+		}
+		opts.From = opts.To.AddDate(0, 0, 1-defaultSearchReportDays)
+
+		for param, target := range map[string]*time.Time{
+			"from": &opts.From,
+			"to":   &opts.To,
+		} {
+			if value := c.Query(param); value != "" {
+				day, err := time.Parse("2006-01-02", value)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid " + param})
+					return
+				}
+				*target = day
+			}
+		}
+		if opts.From.After(opts.To) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "from must not be after to"})
+			return
+		}
+		if opts.To.Sub(opts.From) > maxSearchReportDays*24*time.Hour {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Date range is limited to " + strconv.Itoa(maxSearchReportDays) + " days"})
+			return
+		}
+
+		if value := c.Query("subject_id"); value != "" {
+			subjectID, err := uuid.Parse(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid subject_id"})
+				return
+			}
+			opts.SubjectID = &subjectID
+		}
+
+		opts.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "20"))
+		if opts.Limit <= 0 || opts.Limit > 100 {
+			opts.Limit = 20
+		}
+
+		report, err := analytics.GetReport(opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to build search analytics report"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": report})
+	}
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/P3chys/entoo2-api/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// GetSearchOutboxStats reports the pending search index changes and their lag (admin only)
+// GET /api/v1/admin/search/outbox
+func GetSearchOutboxStats(outbox *services.SearchOutbox) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stats, err := outbox.Stats()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to fetch outbox stats"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": stats})
+	}
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/P3chys/entoo2-api/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// GetSearchVocabulary returns the search synonyms and stop words (admin only)
+// GET /api/v1/admin/search/vocabulary
+func GetSearchVocabulary(vocabulary *services.SearchVocabularyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		current, err := vocabulary.Get()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to fetch search vocabulary"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": current})
+	}
+}
+
+// UpdateSearchVocabulary replaces the search synonyms and stop words and
+// applies them to the search engine (admin only)
+// PUT /api/v1/admin/search/vocabulary
+func UpdateSearchVocabulary(vocabulary *services.SearchVocabularyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req services.SearchVocabulary
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		if err := req.Normalize().Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+
+		updated, err := vocabulary.Replace(req)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": updated})
+	}
+}
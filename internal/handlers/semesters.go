@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/P3chys/entoo2-api/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -201,8 +203,11 @@ func UpdateSemester(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
-// DeleteSemester deletes a semester (admin only)
-func DeleteSemester(db *gorm.DB) gin.HandlerFunc {
+// DeleteSemester deletes a semester without subjects (admin only). Otherwise
+// it is refused with a summary of the content, unless cascade=true deletes
+// the subjects and their content in a background job.
+// DELETE /api/v1/admin/semesters/:id?cascade=true
+func DeleteSemester(deletions *services.DeletionService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
 		semesterID, err := uuid.Parse(id)
@@ -217,46 +222,139 @@ func DeleteSemester(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Check if semester has subjects
-		var subjectCount int64
-		db.Model(&models.Subject{}).Where("semester_id = ?", semesterID).Count(&subjectCount)
-		if subjectCount > 0 {
-			c.JSON(http.StatusConflict, gin.H{
-				"success": false,
-				"error": gin.H{
-					"code":    "HAS_SUBJECTS",
-					"message": "Cannot delete semester with existing subjects",
-				},
-			})
+		if c.Query("cascade") == "true" {
+			adminID, _ := uuid.Parse(c.GetString("user_id"))
+			job, err := deletions.StartSemesterCascade(semesterID, adminID)
+			if err != nil {
+				respondDeletionError(c, err, nil, "HAS_SUBJECTS", "Failed to start semester deletion")
+				return
+			}
+			c.JSON(http.StatusAccepted, gin.H{"success": true, "data": job})
 			return
 		}
 
-		result := db.Delete(&models.Semester{}, "id = ?", semesterID)
-		if result.Error != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
+		summary, err := deletions.DeleteSemester(semesterID)
+		if err != nil {
+			respondDeletionError(c, err, summary, "HAS_SUBJECTS", "Failed to delete semester")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Semester deleted successfully",
+		})
+	}
+}
+
+type CloneSemesterRequest struct {
+	Mode        string            `json:"mode" binding:"required,oneof=codes offerings"`
+	NameCS      string            `json:"name_cs" binding:"max=100"`
+	OrderIndex  int               `json:"order_index"`
+	TermID      string            `json:"term_id" binding:"omitempty,uuid"`
+	SubjectIDs  []string          `json:"subject_ids" binding:"omitempty,dive,uuid"`
+	Codes       map[string]string `json:"codes"`
+	CodeSuffix  string            `json:"code_suffix" binding:"max=7"`
+	DocumentIDs []string          `json:"document_ids" binding:"omitempty,dive,uuid"`
+}
+
+// CloneSemester copies the subjects, teacher assignments and categories of a
+// semester, either as new subjects with new codes in a new semester or as
+// offerings of the same subjects in a term. Selected documents are carried
+// over by reference. With ?preview=true nothing is stored (admin only)
+// POST /api/v1/admin/semesters/:id/clone
+func CloneSemester(clones *services.SemesterCloneService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		semesterID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
 				"success": false,
 				"error": gin.H{
-					"code":    "INTERNAL_ERROR",
-					"message": "Failed to delete semester",
+					"code":    "INVALID_ID",
+					"message": "Invalid semester ID format",
 				},
 			})
 			return
 		}
 
-		if result.RowsAffected == 0 {
-			c.JSON(http.StatusNotFound, gin.H{
+		var req CloneSemesterRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
 				"success": false,
 				"error": gin.H{
-					"code":    "NOT_FOUND",
-					"message": "Semester not found",
+					"code":    "VALIDATION_ERROR",
+					"message": err.Error(),
 				},
 			})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
+		adminID, _ := uuid.Parse(c.GetString("user_id"))
+		opts := services.SemesterCloneOptions{
+			Mode:        services.SemesterCloneMode(req.Mode),
+			NameCS:      req.NameCS,
+			OrderIndex:  req.OrderIndex,
+			Codes:       req.Codes,
+			CodeSuffix:  req.CodeSuffix,
+			SubjectIDs:  parseUUIDs(req.SubjectIDs),
+			DocumentIDs: parseUUIDs(req.DocumentIDs),
+			AdminID:     adminID,
+			Preview:     c.Query("preview") == "true",
+		}
+		if req.TermID != "" {
+			termID := uuid.MustParse(req.TermID)
+			opts.TermID = &termID
+		}
+
+		plan, err := clones.Clone(semesterID, opts)
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrSemesterNotFound):
+				c.JSON(http.StatusNotFound, gin.H{
+					"success": false,
+					"error": gin.H{
+						"code":    "NOT_FOUND",
+						"message": "Semester not found",
+					},
+				})
+			case errors.Is(err, services.ErrInvalidSemesterClone):
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"success": false,
+					"error": gin.H{
+						"code":    "CLONE_CONFLICT",
+						"message": err.Error(),
+					},
+					"data": plan,
+				})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"success": false,
+					"error": gin.H{
+						"code":    "INTERNAL_ERROR",
+						"message": "Failed to clone semester",
+					},
+				})
+			}
+			return
+		}
+
+		status := http.StatusCreated
+		if opts.Preview {
+			status = http.StatusOK
+		}
+		c.JSON(status, gin.H{
 			"success": true,
-			"message": "Semester deleted successfully",
+			"data":    plan,
 		})
 	}
 }
+
+// parseUUIDs converts IDs already validated by binding
+func parseUUIDs(values []string) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(values))
+	for _, value := range values {
+		if id, err := uuid.Parse(value); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
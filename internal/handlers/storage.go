@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/P3chys/entoo2-api/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SetUserQuotaRequest struct {
+	// QuotaBytes overrides the default quota; null restores the default
+	QuotaBytes *int64 `json:"quota_bytes" binding:"omitempty,min=0"`
+}
+
+// respondQuotaError writes the response for a failed quota check in the
+// plain error shape of the upload endpoints, with the exceeded quota's usage
+func respondQuotaError(c *gin.Context, err error) {
+	var quotaErr *services.QuotaExceededError
+	if errors.As(err, &quotaErr) {
+		code := "USER_QUOTA_EXCEEDED"
+		message := "Your storage quota has been exceeded"
+		if quotaErr.Scope == services.QuotaScopeSubject {
+			code = "SUBJECT_QUOTA_EXCEEDED"
+			message = "The storage quota of this subject has been exceeded"
+		}
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"success":     false,
+			"error":       message,
+			"code":        code,
+			"used_bytes":  quotaErr.Used,
+			"quota_bytes": quotaErr.Limit,
+		})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to check storage quota"})
+}
+
+// GetMyUsage returns the storage used by the current user
+// GET /api/v1/auth/me/usage
+func GetMyUsage(quota *services.QuotaService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := uuid.Parse(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "UNAUTHORIZED",
+					"message": "Invalid user ID",
+				},
+			})
+			return
+		}
+
+		usage, err := quota.GetUserUsage(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "INTERNAL_ERROR",
+					"message": "Failed to fetch storage usage",
+				},
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    usage,
+		})
+	}
+}
+
+// GetStorageReport lists the heaviest users, subjects and MIME types (admin only)
+// GET /api/v1/admin/storage/report
+func GetStorageReport(quota *services.QuotaService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		if limit <= 0 || limit > 100 {
+			limit = 10
+		}
+
+		report, err := quota.GetStorageReport(limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "INTERNAL_ERROR",
+					"message": "Failed to build storage report",
+				},
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    report,
+		})
+	}
+}
+
+// SetUserQuota sets a per-user storage quota override (admin only)
+// PUT /api/v1/admin/users/:id/quota
+func SetUserQuota(quota *services.QuotaService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "INVALID_ID",
+					"message": "Invalid user ID format",
+				},
+			})
+			return
+		}
+
+		var req SetUserQuotaRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "VALIDATION_ERROR",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+
+		if err := quota.SetUserQuota(userID, req.QuotaBytes); err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{
+					"success": false,
+					"error": gin.H{
+						"code":    "NOT_FOUND",
+						"message": "User not found",
+					},
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "INTERNAL_ERROR",
+					"message": "Failed to update quota",
+				},
+			})
+			return
+		}
+
+		usage, err := quota.GetUserUsage(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "INTERNAL_ERROR",
+					"message": "Failed to fetch storage usage",
+				},
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    usage,
+		})
+	}
+}
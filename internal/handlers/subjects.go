@@ -1,39 +1,117 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/P3chys/entoo2-api/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 // Request/Response types
+
+// TeacherRequest names a subject teacher by teacher_id or by name. A name
+// matching no teacher creates one.
 type TeacherRequest struct {
-	Name    string `json:"name" binding:"required"`
-	TopicCS string `json:"topic_cs"`
+	TeacherID string `json:"teacher_id" binding:"omitempty,uuid"`
+	Name      string `json:"name" binding:"required_without=TeacherID"`
+	Role      string `json:"role" binding:"omitempty,oneof=guarantor lecturer seminar"`
+	TopicCS   string `json:"topic_cs"`
+}
+
+// RequisiteRequest names a subject that must be passed before
+// (prerequisite) or at the latest together with (corequisite) the subject
+type RequisiteRequest struct {
+	SubjectID string `json:"subject_id" binding:"required,uuid"`
+	Kind      string `json:"kind" binding:"omitempty,oneof=prerequisite corequisite"`
 }
 
 type CreateSubjectRequest struct {
-	SemesterID    string           `json:"semester_id" binding:"required"`
-	NameCS        string           `json:"name_cs" binding:"required"`
-	Code          string           `json:"code" binding:"required,min=3,max=6"`
-	DescriptionCS string           `json:"description_cs"`
-	Credits       int              `json:"credits"`
-	Teachers      []TeacherRequest `json:"teachers"`
+	SemesterID    string             `json:"semester_id" binding:"required"`
+	NameCS        string             `json:"name_cs" binding:"required"`
+	Code          string             `json:"code" binding:"required"`
+	DescriptionCS string             `json:"description_cs"`
+	Credits       int                `json:"credits"`
+	Teachers      []TeacherRequest   `json:"teachers" binding:"dive"`
+	Requisites    []RequisiteRequest `json:"requisites" binding:"dive"`
 }
 
 type UpdateSubjectRequest struct {
-	SemesterID    *string           `json:"semester_id"`
-	NameCS        *string           `json:"name_cs"`
-	Code          *string           `json:"code"`
-	DescriptionCS *string           `json:"description_cs"`
-	Credits       *int              `json:"credits"`
-	Teachers      *[]TeacherRequest `json:"teachers"`
+	SemesterID    *string             `json:"semester_id"`
+	NameCS        *string             `json:"name_cs"`
+	Code          *string             `json:"code"`
+	DescriptionCS *string             `json:"description_cs"`
+	Credits       *int                `json:"credits"`
+	Teachers      *[]TeacherRequest   `json:"teachers" binding:"omitempty,dive"`
+	Requisites    *[]RequisiteRequest `json:"requisites" binding:"omitempty,dive"`
+}
+
+func subjectTeacherInputs(teachers []TeacherRequest) []services.SubjectTeacherInput {
+	inputs := make([]services.SubjectTeacherInput, len(teachers))
+	for i, t := range teachers {
+		inputs[i] = services.SubjectTeacherInput{
+			Name:    t.Name,
+			Role:    models.TeacherRole(t.Role),
+			TopicCS: t.TopicCS,
+		}
+		if teacherID, err := uuid.Parse(t.TeacherID); err == nil {
+			inputs[i].TeacherID = &teacherID
+		}
+	}
+	return inputs
+}
+
+func requisiteInputs(requisites []RequisiteRequest) []services.RequisiteInput {
+	inputs := make([]services.RequisiteInput, len(requisites))
+	for i, r := range requisites {
+		subjectID, _ := uuid.Parse(r.SubjectID)
+		inputs[i] = services.RequisiteInput{
+			SubjectID: subjectID,
+			Kind:      models.RequisiteKind(r.Kind),
+		}
+	}
+	return inputs
+}
+
+// respondSubjectTeachersError writes the response for a failed teacher or
+// requisite update
+func respondSubjectTeachersError(c *gin.Context, err error, message string) {
+	if errors.Is(err, services.ErrRequisiteCycle) {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "REQUISITE_CYCLE",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+	if errors.Is(err, services.ErrTeacherNotFound) || errors.Is(err, services.ErrInvalidSubjectTeachers) ||
+		errors.Is(err, services.ErrInvalidRequisites) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"success": false,
+		"error": gin.H{
+			"code":    "INTERNAL_ERROR",
+			"message": message,
+		},
+	})
 }
 
-// ListSubjects returns all subjects with semester info
+// ListSubjects returns all subjects with semester info, optionally only those
+// offered in an academic year (academic_year_id) or term (term=winter|summer)
+// or those the user is enrolled in (enrolled=true)
 func ListSubjects(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userIDStr := c.GetString("user_id")
@@ -47,16 +125,38 @@ func ListSubjects(db *gorm.DB) gin.HandlerFunc {
 		// Order by (user_favorite_subjects.user_id IS NOT NULL) DESC
 		
 		query := db.Preload("Semester").
-			Select("subjects.*, (CASE WHEN ufs.user_id IS NOT NULL THEN true ELSE false END) as is_favorite").
+			Select(`subjects.*, (CASE WHEN ufs.user_id IS NOT NULL THEN true ELSE false END) as is_favorite,
+				EXISTS (SELECT 1 FROM enrollments e WHERE e.subject_id = subjects.id AND e.user_id = ?) as is_enrolled`, userIDStr).
 			Joins("LEFT JOIN user_favorite_subjects ufs ON subjects.id = ufs.subject_id AND ufs.user_id = ?", userIDStr)
 
+		// Optional filter to the subjects the user is enrolled in, in any term
+		if c.Query("enrolled") == "true" {
+			query = query.Where("EXISTS (SELECT 1 FROM enrollments e WHERE e.subject_id = subjects.id AND e.user_id = ?)", userIDStr)
+		}
+
 		// Optional filter by semester
 		if semesterID := c.Query("semester_id"); semesterID != "" {
 			query = query.Where("subjects.semester_id = ?", semesterID)
 		}
 
-		// Sort: Favorites first, then by code
-		query = query.Order("is_favorite DESC, subjects.code ASC")
+		// Optional filter by academic year and term the subject is offered in
+		termFilter, err := services.ParseTermFilter(c.Query("academic_year_id"), c.Query("term"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "VALIDATION_ERROR",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+		if !termFilter.IsZero() {
+			query = query.Where("subjects.id IN (?)", termFilter.SubjectIDs(db))
+		}
+
+		// Sort: Enrolled subjects first, then favorites, then by code
+		query = query.Order("is_enrolled DESC, is_favorite DESC, subjects.code ASC")
 
 		if err := query.Find(&subjects).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -108,6 +208,8 @@ func GetSubject(db *gorm.DB) gin.HandlerFunc {
 					Joins("LEFT JOIN teacher_ratings ON teacher_ratings.subject_teacher_id = subject_teachers.id").
 					Group("subject_teachers.id")
 			}).
+			Preload("Teachers.Teacher").
+			Preload("Requisites.RequiredSubject").
 			First(&subject, "id = ?", subjectID).Error
 
 		if err != nil {
@@ -139,7 +241,7 @@ func GetSubject(db *gorm.DB) gin.HandlerFunc {
 }
 
 // CreateSubject creates a new subject (admin only)
-func CreateSubject(db *gorm.DB) gin.HandlerFunc {
+func CreateSubject(db *gorm.DB, outbox *services.SearchOutbox) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req CreateSubjectRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -152,6 +254,16 @@ func CreateSubject(db *gorm.DB) gin.HandlerFunc {
 			})
 			return
 		}
+		if err := services.ValidateSubjectCode(req.Code); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error": gin.H{
+					"code":    "VALIDATION_ERROR",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
 
 		semesterID, err := uuid.Parse(req.SemesterID)
 		if err != nil {
@@ -186,48 +298,31 @@ func CreateSubject(db *gorm.DB) gin.HandlerFunc {
 			Credits:       req.Credits,
 		}
 
-		if len(req.Teachers) > 0 {
-			subject.Teachers = make([]models.SubjectTeacher, len(req.Teachers))
-			for i, t := range req.Teachers {
-				subject.Teachers[i] = models.SubjectTeacher{
-					TeacherName: t.Name,
-					TopicCS:     t.TopicCS,
-				}
+		adminID, _ := uuid.Parse(c.GetString("user_id"))
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&subject).Error; err != nil {
+				return err
 			}
-		}
-
-		if err := db.Create(&subject).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"error": gin.H{
-					"code":    "INTERNAL_ERROR",
-					"message": "Failed to create subject",
-				},
-			})
-			return
-		}
-
-		// Get user ID for category creation
-		userID, _ := c.Get("user_id")
-		userUUID, _ := uuid.Parse(userID.(string))
-
-		// Auto-create "Unassigned" categories for each document type
-		types := []string{"lecture", "seminar", "other"}
-		for i, docType := range types {
-			category := models.DocumentCategory{
-				SubjectID:  subject.ID,
-				Type:       docType,
-				NameCS:     "Nepřiřazeno",
-				NameEN:     "Unassigned",
-				OrderIndex: 999, // Put at end
-				CreatedBy:  userUUID,
+			if err := services.CreateDefaultCategories(tx, subject.ID, adminID); err != nil {
+				return err
+			}
+			teachers, err := services.SyncSubjectTeachers(tx, subject.ID, subjectTeacherInputs(req.Teachers))
+			if err != nil {
+				return err
 			}
-			if err := db.Create(&category).Error; err != nil {
-				// Log error but don't fail subject creation
-				// Categories can be created manually later if needed
-				_ = i // avoid unused variable warning
+			subject.Teachers = teachers
+			requisites, err := services.SyncSubjectRequisites(tx, subject.ID, requisiteInputs(req.Requisites))
+			if err != nil {
+				return err
 			}
+			subject.Requisites = requisites
+			return outbox.Enqueue(tx, models.SearchEntitySubject, subject.ID, models.SearchOperationIndex)
+		})
+		if err != nil {
+			respondSubjectTeachersError(c, err, "Failed to create subject")
+			return
 		}
+		outbox.Notify()
 
 		c.JSON(http.StatusCreated, gin.H{
 			"success": true,
@@ -237,7 +332,7 @@ func CreateSubject(db *gorm.DB) gin.HandlerFunc {
 }
 
 // UpdateSubject updates an existing subject (admin only)
-func UpdateSubject(db *gorm.DB) gin.HandlerFunc {
+func UpdateSubject(db *gorm.DB, outbox *services.SearchOutbox) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
 		subjectID, err := uuid.Parse(id)
@@ -285,6 +380,21 @@ func UpdateSubject(db *gorm.DB) gin.HandlerFunc {
 			})
 			return
 		}
+		if req.Code != nil {
+			if err := services.ValidateSubjectCode(*req.Code); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"success": false,
+					"error": gin.H{
+						"code":    "VALIDATION_ERROR",
+						"message": err.Error(),
+					},
+				})
+				return
+			}
+		}
+
+		// Search records of the subject's content carry its code, name and semester
+		previous := subject
 
 		// Update fields if provided
 		if req.SemesterID != nil {
@@ -312,38 +422,36 @@ func UpdateSubject(db *gorm.DB) gin.HandlerFunc {
 
 		err = db.Transaction(func(tx *gorm.DB) error {
 			if req.Teachers != nil {
-				// Delete existing teachers
-				if err := tx.Delete(&models.SubjectTeacher{}, "subject_id = ?", subject.ID).Error; err != nil {
+				// Teachers that stay keep their link and with it their ratings
+				teachers, err := services.SyncSubjectTeachers(tx, subject.ID, subjectTeacherInputs(*req.Teachers))
+				if err != nil {
+					return err
+				}
+				subject.Teachers = teachers
+			}
+			if req.Requisites != nil {
+				requisites, err := services.SyncSubjectRequisites(tx, subject.ID, requisiteInputs(*req.Requisites))
+				if err != nil {
 					return err
 				}
-				// Add new teachers
-				if len(*req.Teachers) > 0 {
-					newTeachers := make([]models.SubjectTeacher, len(*req.Teachers))
-					for i, t := range *req.Teachers {
-						newTeachers[i] = models.SubjectTeacher{
-							SubjectID:   subject.ID,
-							TeacherName: t.Name,
-							TopicCS:     t.TopicCS,
-						}
-					}
-					if err := tx.Create(&newTeachers).Error; err != nil {
-						return err
-					}
+				subject.Requisites = requisites
+			}
+			if err := tx.Omit("Teachers", "Requisites").Save(&subject).Error; err != nil {
+				return err
+			}
+			if subject.Code != previous.Code || subject.NameCS != previous.NameCS || subject.SemesterID != previous.SemesterID {
+				if err := outbox.EnqueueSubjectContent(tx, subject.ID); err != nil {
+					return err
 				}
 			}
-			return tx.Save(&subject).Error
+			return outbox.Enqueue(tx, models.SearchEntitySubject, subject.ID, models.SearchOperationIndex)
 		})
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"error": gin.H{
-					"code":    "INTERNAL_ERROR",
-					"message": "Failed to update subject",
-				},
-			})
+			respondSubjectTeachersError(c, err, "Failed to update subject")
 			return
 		}
+		outbox.Notify()
 
 		c.JSON(http.StatusOK, gin.H{
 			"success": true,
@@ -352,8 +460,60 @@ func UpdateSubject(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
-// DeleteSubject deletes a subject (admin only)
-func DeleteSubject(db *gorm.DB) gin.HandlerFunc {
+// respondDeletionError writes the response for a refused or failed subject
+// or semester deletion
+func respondDeletionError(c *gin.Context, err error, summary *services.DeletionSummary, conflictCode, message string) {
+	switch {
+	case errors.Is(err, services.ErrSubjectNotFound), errors.Is(err, services.ErrSemesterNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": err.Error(),
+			},
+		})
+	case errors.Is(err, services.ErrHasDependencies):
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":         conflictCode,
+				"message":      "Delete with cascade=true to remove all dependent content",
+				"dependencies": summary,
+			},
+		})
+	case errors.Is(err, services.ErrDeletionInProgress):
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "DELETION_IN_PROGRESS",
+				"message": err.Error(),
+			},
+		})
+	case errors.Is(err, services.ErrStorageUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "STORAGE_UNAVAILABLE",
+				"message": err.Error(),
+			},
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": message,
+			},
+		})
+	}
+}
+
+// DeleteSubject deletes a subject with its teachers, categories and
+// requisites (admin only). A subject with other content is refused with a
+// summary of it, unless cascade=true deletes everything in a background job
+// polled at GET /api/v1/admin/jobs/:id.
+// DELETE /api/v1/admin/subjects/:id?cascade=true
+func DeleteSubject(deletions *services.DeletionService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
 		subjectID, err := uuid.Parse(id)
@@ -368,29 +528,20 @@ func DeleteSubject(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Delete associated teachers first
-		db.Where("subject_id = ?", subjectID).Delete(&models.SubjectTeacher{})
-
-		result := db.Delete(&models.Subject{}, "id = ?", subjectID)
-		if result.Error != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"error": gin.H{
-					"code":    "INTERNAL_ERROR",
-					"message": "Failed to delete subject",
-				},
-			})
+		if c.Query("cascade") == "true" {
+			adminID, _ := uuid.Parse(c.GetString("user_id"))
+			job, err := deletions.StartSubjectCascade(subjectID, adminID)
+			if err != nil {
+				respondDeletionError(c, err, nil, "HAS_DEPENDENCIES", "Failed to start subject deletion")
+				return
+			}
+			c.JSON(http.StatusAccepted, gin.H{"success": true, "data": job})
 			return
 		}
 
-		if result.RowsAffected == 0 {
-			c.JSON(http.StatusNotFound, gin.H{
-				"success": false,
-				"error": gin.H{
-					"code":    "NOT_FOUND",
-					"message": "Subject not found",
-				},
-			})
+		summary, err := deletions.DeleteSubject(subjectID)
+		if err != nil {
+			respondDeletionError(c, err, summary, "HAS_DEPENDENCIES", "Failed to delete subject")
 			return
 		}
 
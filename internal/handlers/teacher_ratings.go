@@ -1,21 +1,29 @@
 package handlers
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/P3chys/entoo2-api/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"gorm.io/gorm"
 )
 
 type RateTeacherRequest struct {
 	Rating int `json:"rating" binding:"required,min=1,max=5"`
+	// Optional written review
+	Review        string             `json:"review" binding:"max=2000"`
+	Anonymous     bool               `json:"anonymous"`
+	SemesterTaken string             `json:"semester_taken" binding:"max=50"`
+	Tags          []models.ReviewTag `json:"tags" binding:"max=5"`
 }
 
-// RateTeacher creates or updates a teacher rating
-func RateTeacher(db *gorm.DB) gin.HandlerFunc {
+// RateTeacher creates or updates a teacher rating with an optional review.
+// The ID is that of the subject teacher, as a teacher is rated per subject.
+// POST /api/v1/subject-teachers/:id/rate (deprecated alias: /api/v1/teachers/:id/rate)
+func RateTeacher(reviews *services.TeacherReviewService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		teacherIDStr := c.Param("id")
 		teacherID, err := uuid.Parse(teacherIDStr)
@@ -37,61 +45,43 @@ func RateTeacher(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Verify teacher exists
-		var teacher models.SubjectTeacher
-		if err := db.First(&teacher, "id = ?", teacherID).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Teacher not found"})
-			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-			}
+		rating, created, err := reviews.Rate(teacherID, userID, services.ReviewInput{
+			Rating:        req.Rating,
+			Review:        req.Review,
+			Anonymous:     req.Anonymous,
+			SemesterTaken: req.SemesterTaken,
+			Tags:          req.Tags,
+		})
+		switch {
+		case errors.Is(err, services.ErrTeacherNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Teacher not found"})
+			return
+		case errors.Is(err, services.ErrInvalidReview):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save rating"})
 			return
 		}
 
-		// Check if rating already exists
-		var existingRating models.TeacherRating
-		err = db.Where("subject_teacher_id = ? AND user_id = ?", teacherID, userID).
-			First(&existingRating).Error
-
-		statusCode := http.StatusCreated
-		message := "Rating created successfully"
-
-		if err == nil {
-			// Update existing rating
-			existingRating.Rating = req.Rating
-			if err := db.Save(&existingRating).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update rating"})
-				return
-			}
-			statusCode = http.StatusOK
-			message = "Rating updated successfully"
-		} else if err == gorm.ErrRecordNotFound {
-			// Create new rating
-			newRating := models.TeacherRating{
-				SubjectTeacherID: teacherID,
-				UserID:           userID,
-				Rating:           req.Rating,
-			}
-			if err := db.Create(&newRating).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create rating"})
-				return
-			}
-			existingRating = newRating
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-			return
+		statusCode := http.StatusOK
+		message := "Rating updated successfully"
+		if created {
+			statusCode = http.StatusCreated
+			message = "Rating created successfully"
 		}
 
 		c.JSON(statusCode, gin.H{
 			"success": true,
-			"data":    existingRating,
+			"data":    rating,
 			"message": message,
 		})
 	}
 }
 
-// DeleteTeacherRating removes a user's rating for a teacher
-func DeleteTeacherRating(db *gorm.DB) gin.HandlerFunc {
+// DeleteTeacherRating removes a user's rating for a teacher with its review
+// DELETE /api/v1/subject-teachers/:id/rate (deprecated alias: /api/v1/teachers/:id/rate)
+func DeleteTeacherRating(reviews *services.TeacherReviewService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		teacherIDStr := c.Param("id")
 		teacherID, err := uuid.Parse(teacherIDStr)
@@ -107,19 +97,15 @@ func DeleteTeacherRating(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		result := db.Where("subject_teacher_id = ? AND user_id = ?", teacherID, userID).
-			Delete(&models.TeacherRating{})
-
-		if result.Error != nil {
+		if err := reviews.Delete(teacherID, userID); err != nil {
+			if errors.Is(err, services.ErrRatingNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Rating not found"})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete rating"})
 			return
 		}
 
-		if result.RowsAffected == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Rating not found"})
-			return
-		}
-
 		c.JSON(http.StatusOK, gin.H{
 			"success": true,
 			"message": "Rating deleted successfully",
@@ -127,8 +113,10 @@ func DeleteTeacherRating(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
-// GetTeacherRatings returns aggregate rating data for a teacher
-func GetTeacherRatings(db *gorm.DB) gin.HandlerFunc {
+// GetTeacherRatings returns aggregate rating data for a teacher with a page
+// of written reviews, sorted by sort (newest, oldest, highest or lowest)
+// GET /api/v1/subject-teachers/:id/ratings (deprecated alias: /api/v1/teachers/:id/ratings)
+func GetTeacherRatings(reviews *services.TeacherReviewService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		teacherIDStr := c.Param("id")
 		teacherID, err := uuid.Parse(teacherIDStr)
@@ -137,59 +125,31 @@ func GetTeacherRatings(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		userIDStr := c.GetString("user_id")
-
-		// Get average rating and count
-		var result struct {
-			AverageRating float64
-			TotalRatings  int64
-		}
-
-		err = db.Model(&models.TeacherRating{}).
-			Select("COALESCE(AVG(rating), 0) as average_rating, COUNT(*) as total_ratings").
-			Where("subject_teacher_id = ?", teacherID).
-			Scan(&result).Error
-
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ratings"})
+		opts := services.ReviewListOptions{Sort: c.DefaultQuery("sort", services.ReviewSortNewest)}
+		if !services.IsValidReviewSort(opts.Sort) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort order"})
 			return
 		}
-
-		// Get rating distribution
-		var distribution []struct {
-			Rating int
-			Count  int64
-		}
-		db.Model(&models.TeacherRating{}).
-			Select("rating, COUNT(*) as count").
-			Where("subject_teacher_id = ?", teacherID).
-			Group("rating").
-			Scan(&distribution)
-
-		distMap := make(map[string]int64)
-		for i := 1; i <= 5; i++ {
-			distMap[fmt.Sprintf("%d", i)] = 0
+		opts.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "10"))
+		if opts.Limit <= 0 || opts.Limit > 50 {
+			opts.Limit = 10
 		}
-		for _, d := range distribution {
-			distMap[fmt.Sprintf("%d", d.Rating)] = d.Count
+		opts.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+		if opts.Offset < 0 {
+			opts.Offset = 0
 		}
 
-		// Get user's rating
-		var userRating *int
-		var ur models.TeacherRating
-		if err := db.Where("subject_teacher_id = ? AND user_id = ?", teacherID, userIDStr).
-			First(&ur).Error; err == nil {
-			userRating = &ur.Rating
+		summary, err := reviews.Summary(teacherID, c.GetString("user_id"), opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ratings"})
+			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"success": true,
-			"data": gin.H{
-				"average_rating":      result.AverageRating,
-				"total_ratings":       result.TotalRatings,
-				"user_rating":         userRating,
-				"rating_distribution": distMap,
-			},
+			"data":    summary,
+			"limit":   opts.Limit,
+			"offset":  opts.Offset,
 		})
 	}
 }
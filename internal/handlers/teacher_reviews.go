@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/P3chys/entoo2-api/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ReportReviewRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+type HideReviewRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// ReportTeacherReview puts a written review into the moderation queue
+// POST /api/v1/reviews/:id/report
+func ReportTeacherReview(reviews *services.TeacherReviewService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ratingID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid review ID"})
+			return
+		}
+		userID, err := uuid.Parse(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Invalid user ID"})
+			return
+		}
+
+		var req ReportReviewRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+
+		report, err := reviews.Report(ratingID, userID, req.Reason)
+		switch {
+		case errors.Is(err, services.ErrRatingNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Review not found"})
+			return
+		case errors.Is(err, services.ErrReviewAlreadyReported):
+			c.JSON(http.StatusConflict, gin.H{"success": false, "error": "You have already reported this review"})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to report review"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"success": true, "data": report})
+	}
+}
+
+// ListReviewReports returns the moderation queue of reported reviews (admin only)
+// GET /api/v1/admin/reviews/reports?status=open|dismissed|resolved
+func ListReviewReports(reviews *services.TeacherReviewService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := models.ReviewReportStatus(c.DefaultQuery("status", string(models.ReviewReportOpen)))
+		switch status {
+		case models.ReviewReportOpen, models.ReviewReportDismissed, models.ReviewReportResolved:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid status"})
+			return
+		}
+
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if limit <= 0 || limit > 200 {
+			limit = 50
+		}
+		offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+		if offset < 0 {
+			offset = 0
+		}
+
+		reports, total, err := reviews.ListReports(status, limit, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to fetch review reports"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": reports, "total": total})
+	}
+}
+
+// HideTeacherReview hides a review from other users and resolves its reports (admin only)
+// POST /api/v1/admin/reviews/:id/hide
+func HideTeacherReview(reviews *services.TeacherReviewService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req HideReviewRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+				return
+			}
+		}
+		setReviewHidden(c, reviews, true, req.Reason)
+	}
+}
+
+// UnhideTeacherReview shows a hidden review again (admin only)
+// POST /api/v1/admin/reviews/:id/unhide
+func UnhideTeacherReview(reviews *services.TeacherReviewService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		setReviewHidden(c, reviews, false, "")
+	}
+}
+
+func setReviewHidden(c *gin.Context, reviews *services.TeacherReviewService, hidden bool, reason string) {
+	ratingID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid review ID"})
+		return
+	}
+	adminID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Invalid user ID"})
+		return
+	}
+
+	rating, err := reviews.SetHidden(ratingID, adminID, hidden, reason)
+	if errors.Is(err, services.ErrRatingNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Review not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to update review"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": rating})
+}
+
+// DismissReviewReport closes a report without hiding the review (admin only)
+// POST /api/v1/admin/reviews/reports/:id/dismiss
+func DismissReviewReport(reviews *services.TeacherReviewService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reportID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid report ID"})
+			return
+		}
+		adminID, err := uuid.Parse(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Invalid user ID"})
+			return
+		}
+
+		if err := reviews.DismissReport(reportID, adminID); err != nil {
+			if errors.Is(err, services.ErrReviewReportNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Open report not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to dismiss report"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Report dismissed"})
+	}
+}
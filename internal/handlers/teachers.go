@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/P3chys/entoo2-api/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CreateTeacherRequest struct {
+	Name       string `json:"name" binding:"required,max=200"`
+	Title      string `json:"title" binding:"max=100"`
+	Department string `json:"department" binding:"max=200"`
+	Profile    string `json:"profile"`
+}
+
+type UpdateTeacherRequest struct {
+	Name       *string `json:"name" binding:"omitempty,min=1,max=200"`
+	Title      *string `json:"title" binding:"omitempty,max=100"`
+	Department *string `json:"department" binding:"omitempty,max=200"`
+	Profile    *string `json:"profile"`
+}
+
+// ListTeachers returns teachers with their ratings aggregated over all subjects
+// GET /api/v1/teachers?q=&department=&limit=&offset=
+func ListTeachers(teachers *services.TeacherService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if limit <= 0 || limit > 200 {
+			limit = 50
+		}
+		offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+		if offset < 0 {
+			offset = 0
+		}
+
+		list, total, err := teachers.List(c.Query("q"), c.Query("department"), limit, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to fetch teachers"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": list, "total": total})
+	}
+}
+
+// GetTeacher returns a teacher with their subjects and ratings over all of them
+// GET /api/v1/teachers/:id
+func GetTeacher(teachers *services.TeacherService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		teacherID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid teacher ID"})
+			return
+		}
+
+		detail, err := teachers.Get(teacherID, c.GetString("user_id"))
+		if errors.Is(err, services.ErrTeacherNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Teacher not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to fetch teacher"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": detail})
+	}
+}
+
+// CreateTeacher adds a teacher (admin only)
+// POST /api/v1/admin/teachers
+func CreateTeacher(teachers *services.TeacherService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreateTeacherRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		if services.NormalizeTeacherName(req.Name) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Name is required"})
+			return
+		}
+
+		teacher := models.Teacher{
+			Name:       req.Name,
+			Title:      req.Title,
+			Department: req.Department,
+			Profile:    req.Profile,
+		}
+		if err := teachers.Create(&teacher); err != nil {
+			if errors.Is(err, services.ErrTeacherNameTaken) {
+				c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to create teacher"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"success": true, "data": teacher})
+	}
+}
+
+// UpdateTeacher changes a teacher's name, title, department or profile (admin only)
+// PUT /api/v1/admin/teachers/:id
+func UpdateTeacher(teachers *services.TeacherService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		teacherID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid teacher ID"})
+			return
+		}
+
+		var req UpdateTeacherRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+
+		updates := map[string]interface{}{}
+		if req.Name != nil {
+			if services.NormalizeTeacherName(*req.Name) == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Name must not be empty"})
+				return
+			}
+			updates["name"] = *req.Name
+		}
+		if req.Title != nil {
+			updates["title"] = *req.Title
+		}
+		if req.Department != nil {
+			updates["department"] = *req.Department
+		}
+		if req.Profile != nil {
+			updates["profile"] = *req.Profile
+		}
+		if len(updates) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "No fields to update"})
+			return
+		}
+
+		teacher, err := teachers.Update(teacherID, updates)
+		if errors.Is(err, services.ErrTeacherNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Teacher not found"})
+			return
+		}
+		if errors.Is(err, services.ErrTeacherNameTaken) {
+			c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to update teacher"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": teacher})
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/P3chys/entoo2-api/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ListTrash lists soft-deleted documents, globally or for a single subject (admin only)
+// GET /api/v1/admin/trash
+// GET /api/v1/admin/subjects/:id/trash
+func ListTrash(db *gorm.DB, trash *services.TrashService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+		if limit <= 0 || limit > 200 {
+			limit = 50
+		}
+
+		query := db.Unscoped().Model(&models.Document{}).Where("documents.deleted_at IS NOT NULL")
+
+		if subjectID := c.Param("id"); subjectID != "" {
+			subjectUUID, err := uuid.Parse(subjectID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid subject ID"})
+				return
+			}
+			query = query.Where("documents.subject_id = ?", subjectUUID)
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to fetch trash"})
+			return
+		}
+
+		var documents []models.Document
+		if err := query.Preload("Uploader").Preload("Subject").Preload("Category").
+			Order("documents.deleted_at DESC").
+			Limit(limit).Offset(offset).
+			Find(&documents).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to fetch trash"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success":         true,
+			"data":            documents,
+			"total":           total,
+			"retention_hours": int(trash.Retention() / time.Hour),
+		})
+	}
+}
+
+// RestoreDocument moves a document out of the trash (uploader or admin)
+// POST /api/v1/documents/:id/restore
+func RestoreDocument(db *gorm.DB, outbox *services.SearchOutbox, activity *services.ActivityService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		docID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid document ID"})
+			return
+		}
+
+		userID := c.GetString("user_id")
+		userUUID, err := uuid.Parse(userID)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Invalid user"})
+			return
+		}
+
+		var document models.Document
+		if err := db.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", docID).First(&document).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Document not found in trash"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Database error"})
+			}
+			return
+		}
+
+		if document.UploadedBy != userUUID && c.GetString("role") != string(models.RoleAdmin) {
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Not authorized to restore this document"})
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Unscoped().Model(&document).Updates(map[string]interface{}{
+				"deleted_at": nil,
+				"deleted_by": nil,
+			}).Error; err != nil {
+				return err
+			}
+			return outbox.Enqueue(tx, models.SearchEntityDocument, document.ID, models.SearchOperationIndex)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to restore document"})
+			return
+		}
+		document.DeletedAt = gorm.DeletedAt{}
+		document.DeletedBy = nil
+
+		outbox.Notify()
+
+		// Create activity
+		go func() {
+			_ = activity.CreateActivity(userUUID, models.ActivityDocumentRestored, &document.SubjectID, &document.ID, nil)
+		}()
+
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": document})
+	}
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type TermKind string
+
+const (
+	TermWinter TermKind = "winter"
+	TermSummer TermKind = "summer"
+)
+
+// AcademicYear is a study year such as "2024/2025". It has a winter and a
+// summer term in which subjects are offered.
+type AcademicYear struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name      string    `gorm:"size:20;not null;uniqueIndex" json:"name"`
+	StartYear int       `gorm:"not null;uniqueIndex" json:"start_year"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Relations
+	Terms []Term `gorm:"foreignKey:AcademicYearID" json:"terms,omitempty"`
+}
+
+func (AcademicYear) TableName() string {
+	return "academic_years"
+}
+
+func (y *AcademicYear) BeforeCreate(tx *gorm.DB) error {
+	if y.ID == uuid.Nil {
+		y.ID = uuid.New()
+	}
+	return nil
+}
+
+// Term is the winter or summer term of an academic year
+type Term struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	AcademicYearID uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_term_year_kind" json:"academic_year_id"`
+	Kind           TermKind   `gorm:"type:varchar(10);not null;uniqueIndex:idx_term_year_kind" json:"kind"`
+	StartsOn       *time.Time `gorm:"type:date" json:"starts_on,omitempty"`
+	EndsOn         *time.Time `gorm:"type:date" json:"ends_on,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	// Relations
+	AcademicYear *AcademicYear `gorm:"foreignKey:AcademicYearID" json:"academic_year,omitempty"`
+}
+
+func (Term) TableName() string {
+	return "terms"
+}
+
+func (t *Term) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+// SubjectOffering is a subject taught in a term. It has its own teachers, and
+// documents may belong to it so that materials of different years stay apart.
+type SubjectOffering struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	SubjectID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_offering_subject_term" json:"subject_id"`
+	TermID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_offering_subject_term;index" json:"term_id"`
+	Note      string    `gorm:"size:500" json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Relations
+	Subject   *Subject          `gorm:"foreignKey:SubjectID" json:"subject,omitempty"`
+	Term      *Term             `gorm:"foreignKey:TermID" json:"term,omitempty"`
+	Teachers  []OfferingTeacher `gorm:"foreignKey:OfferingID" json:"teachers,omitempty"`
+	ExamDates []ExamDate        `gorm:"foreignKey:OfferingID" json:"exam_dates,omitempty"`
+}
+
+func (SubjectOffering) TableName() string {
+	return "subject_offerings"
+}
+
+func (o *SubjectOffering) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == uuid.Nil {
+		o.ID = uuid.New()
+	}
+	return nil
+}
+
+// OfferingTeacher is a teacher of a subject in one term
+type OfferingTeacher struct {
+	ID         uuid.UUID   `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	OfferingID uuid.UUID   `gorm:"type:uuid;not null;uniqueIndex:idx_offering_teacher" json:"offering_id"`
+	TeacherID  uuid.UUID   `gorm:"type:uuid;not null;uniqueIndex:idx_offering_teacher;index" json:"teacher_id"`
+	Role       TeacherRole `gorm:"type:varchar(20);not null;default:'lecturer'" json:"role"`
+	TopicCS    string      `gorm:"size:300" json:"topic_cs"`
+	CreatedAt  time.Time   `json:"created_at"`
+
+	// Relations
+	Teacher *Teacher `gorm:"foreignKey:TeacherID" json:"teacher,omitempty"`
+}
+
+func (OfferingTeacher) TableName() string {
+	return "offering_teachers"
+}
+
+func (ot *OfferingTeacher) BeforeCreate(tx *gorm.DB) error {
+	if ot.ID == uuid.Nil {
+		ot.ID = uuid.New()
+	}
+	return nil
+}
+
+// ExamDate is an exam of a subject offering
+type ExamDate struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	OfferingID uuid.UUID `gorm:"type:uuid;not null;index" json:"offering_id"`
+	StartsAt   time.Time `gorm:"not null;index" json:"starts_at"`
+	Location   string    `gorm:"size:200" json:"location"`
+	Note       string    `gorm:"size:500" json:"note"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (ExamDate) TableName() string {
+	return "exam_dates"
+}
+
+func (e *ExamDate) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
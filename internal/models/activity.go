@@ -12,6 +12,7 @@ type ActivityType string
 const (
 	ActivityDocumentUploaded ActivityType = "document_uploaded"
 	ActivityDocumentDeleted  ActivityType = "document_deleted"
+	ActivityDocumentRestored ActivityType = "document_restored"
 )
 
 type Activity struct {
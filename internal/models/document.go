@@ -7,6 +7,15 @@ import (
 	"gorm.io/gorm"
 )
 
+type ExtractionStatus string
+
+const (
+	ExtractionStatusPending     ExtractionStatus = "pending"
+	ExtractionStatusDone        ExtractionStatus = "done"
+	ExtractionStatusFailed      ExtractionStatus = "failed"
+	ExtractionStatusUnsupported ExtractionStatus = "unsupported"
+)
+
 type Document struct {
 	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
 	SubjectID    uuid.UUID `gorm:"type:uuid;not null;index" json:"subject_id"`
@@ -14,18 +23,26 @@ type Document struct {
 	AnswerID     *uuid.UUID `gorm:"type:uuid;index" json:"answer_id,omitempty"` // Link to answer if attached
 	Type         string     `gorm:"size:20;default:'other'" json:"type"`       // lecture, seminar, other
 	CategoryID   *uuid.UUID `gorm:"type:uuid;index" json:"category_id,omitempty"`
+	OfferingID   *uuid.UUID `gorm:"type:uuid;index" json:"offering_id,omitempty"` // Term the material is from
+	SourceDocumentID *uuid.UUID `gorm:"type:uuid;index" json:"source_document_id,omitempty"` // Carried over by reference; shares the stored file
 	Filename     string     `gorm:"size:255;not null" json:"filename"`
 	OriginalName string    `gorm:"size:255;not null" json:"original_name"`
 	FileSize     int64     `gorm:"not null" json:"file_size"`
 	MimeType     string    `gorm:"size:100;not null" json:"mime_type"`
 	MinIOPath    string    `gorm:"size:500;not null" json:"minio_path"`
 	ContentText  string    `gorm:"type:text" json:"content_text,omitempty"`
+	ExtractionStatus ExtractionStatus `gorm:"size:20;default:'pending';index" json:"extraction_status"`
 	CreatedAt    time.Time `gorm:"index" json:"created_at"`
 
+	// Soft delete (trash bin)
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	DeletedBy *uuid.UUID     `gorm:"type:uuid" json:"deleted_by,omitempty"`
+
 	// Relations
 	Subject  Subject           `gorm:"foreignKey:SubjectID" json:"subject,omitempty"`
 	Uploader User              `gorm:"foreignKey:UploadedBy" json:"uploader,omitempty"`
 	Category *DocumentCategory `gorm:"foreignKey:CategoryID" json:"category,omitempty"`
+	Offering *SubjectOffering  `gorm:"foreignKey:OfferingID" json:"offering,omitempty"`
 
 	// Computed
 	IsFavorite bool `gorm:"->" json:"is_favorite"`
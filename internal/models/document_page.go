@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DocumentPage is the extracted text of one page of a document: a PDF page
+// or a presentation slide. Documents without pages are stored as page 1.
+// Document.ContentText keeps the full text.
+type DocumentPage struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	DocumentID  uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_document_page_number" json:"document_id"`
+	PageNumber  int       `gorm:"not null;uniqueIndex:idx_document_page_number" json:"page_number"`
+	ContentText string    `gorm:"type:text;not null" json:"content_text"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (DocumentPage) TableName() string {
+	return "document_pages"
+}
+
+func (p *DocumentPage) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
+}
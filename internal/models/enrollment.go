@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Enrollment records that a user takes a subject in a term. Unlike a
+// favorite it says what the user studies, not what they like.
+type Enrollment struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_enrollment_user_subject_term" json:"user_id"`
+	SubjectID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_enrollment_user_subject_term;index" json:"subject_id"`
+	TermID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_enrollment_user_subject_term;index" json:"term_id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Relations
+	Subject *Subject `gorm:"foreignKey:SubjectID" json:"subject,omitempty"`
+	Term    *Term    `gorm:"foreignKey:TermID" json:"term,omitempty"`
+}
+
+func (Enrollment) TableName() string {
+	return "enrollments"
+}
+
+func (e *Enrollment) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ExtractionJobStatus string
+
+const (
+	ExtractionJobQueued  ExtractionJobStatus = "queued"
+	ExtractionJobRunning ExtractionJobStatus = "running"
+	ExtractionJobDone    ExtractionJobStatus = "done"
+	ExtractionJobFailed  ExtractionJobStatus = "failed"
+)
+
+// ExtractionJob is a persistent queue entry for extracting the text of a document
+type ExtractionJob struct {
+	ID         uuid.UUID           `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	DocumentID uuid.UUID           `gorm:"type:uuid;not null;uniqueIndex" json:"document_id"`
+	Status     ExtractionJobStatus `gorm:"type:varchar(20);not null;default:'queued';index" json:"status"`
+	Attempts   int                 `gorm:"not null;default:0" json:"attempts"`
+	NextRunAt  time.Time           `gorm:"not null;index" json:"next_run_at"`
+	LockedAt   *time.Time          `json:"locked_at,omitempty"`
+	LastError  string              `gorm:"type:text" json:"last_error,omitempty"`
+	CreatedAt  time.Time           `json:"created_at"`
+	UpdatedAt  time.Time           `json:"updated_at"`
+
+	// Relations
+	Document *Document `gorm:"foreignKey:DocumentID" json:"document,omitempty"`
+}
+
+func (ExtractionJob) TableName() string {
+	return "extraction_jobs"
+}
+
+func (j *ExtractionJob) BeforeCreate(tx *gorm.DB) error {
+	if j.ID == uuid.Nil {
+		j.ID = uuid.New()
+	}
+	if j.NextRunAt.IsZero() {
+		j.NextRunAt = time.Now()
+	}
+	return nil
+}
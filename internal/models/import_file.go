@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ImportFile is the import manifest entry of one file imported from a legacy
+// folder tree. It lets reruns of the importer skip files that did not change
+// and replace the documents of files that did.
+type ImportFile struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Source     string    `gorm:"size:100;not null;uniqueIndex:idx_import_file_path" json:"source"`
+	Path       string    `gorm:"size:1000;not null;uniqueIndex:idx_import_file_path" json:"path"`
+	SubjectID  uuid.UUID `gorm:"type:uuid;not null;index" json:"subject_id"`
+	DocumentID uuid.UUID `gorm:"type:uuid;not null;index" json:"document_id"`
+	Size       int64     `gorm:"not null" json:"size"`
+	ModTime    time.Time `gorm:"not null" json:"mod_time"`
+	SHA256     string    `gorm:"size:64;not null" json:"sha256"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (ImportFile) TableName() string {
+	return "import_files"
+}
+
+func (f *ImportFile) BeforeCreate(tx *gorm.DB) error {
+	if f.ID == uuid.Nil {
+		f.ID = uuid.New()
+	}
+	return nil
+}
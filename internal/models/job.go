@@ -0,0 +1,93 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type JobType string
+
+const (
+	JobTypeZipUpload      JobType = "zip_upload"
+	JobTypeSubjectDelete  JobType = "subject_delete"
+	JobTypeSemesterDelete JobType = "semester_delete"
+)
+
+type JobStatus string
+
+const (
+	JobStatusPending   JobStatus = "pending"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusCompleted JobStatus = "completed"
+	JobStatusFailed    JobStatus = "failed"
+)
+
+// JobResult is the JSON result of a job, served as an object rather than a
+// string holding JSON
+type JobResult json.RawMessage
+
+func (r JobResult) Value() (driver.Value, error) {
+	if len(r) == 0 {
+		return "{}", nil
+	}
+	return string(r), nil
+}
+
+func (r *JobResult) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*r = nil
+	case []byte:
+		*r = append(JobResult{}, v...)
+	case string:
+		*r = JobResult(v)
+	default:
+		return fmt.Errorf("unsupported job result type %T", value)
+	}
+	return nil
+}
+
+func (r JobResult) MarshalJSON() ([]byte, error) {
+	if len(r) == 0 {
+		return []byte("null"), nil
+	}
+	return r, nil
+}
+
+func (r *JobResult) UnmarshalJSON(data []byte) error {
+	*r = append(JobResult{}, data...)
+	return nil
+}
+
+// Job tracks a long-running background task started from an API request
+type Job struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Type       JobType    `gorm:"type:varchar(50);not null;index" json:"type"`
+	Status     JobStatus  `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+	CreatedBy  uuid.UUID  `gorm:"type:uuid;not null;index" json:"created_by"`
+	SubjectID  *uuid.UUID `gorm:"type:uuid;index" json:"subject_id,omitempty"`
+	Total      int        `gorm:"not null;default:0" json:"total"`
+	Processed  int        `gorm:"not null;default:0" json:"processed"`
+	Failed     int        `gorm:"not null;default:0" json:"failed"`
+	Result     JobResult  `gorm:"type:jsonb" json:"result,omitempty"`
+	Error      string     `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+func (Job) TableName() string {
+	return "jobs"
+}
+
+func (j *Job) BeforeCreate(tx *gorm.DB) error {
+	if j.ID == uuid.Nil {
+		j.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type NotificationType string
+
+const (
+	NotificationSavedSearchMatch NotificationType = "saved_search_match"
+)
+
+// Notification is an in-app message for a single user
+type Notification struct {
+	ID            uuid.UUID        `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID        uuid.UUID        `gorm:"type:uuid;not null;index" json:"user_id"`
+	Type          NotificationType `gorm:"size:50;not null" json:"type"`
+	Title         string           `gorm:"size:300;not null" json:"title"`
+	SavedSearchID *uuid.UUID       `gorm:"type:uuid" json:"saved_search_id,omitempty"`
+	DocumentID    *uuid.UUID       `gorm:"type:uuid" json:"document_id,omitempty"`
+	SubjectID     *uuid.UUID       `gorm:"type:uuid" json:"subject_id,omitempty"`
+	ReadAt        *time.Time       `json:"read_at,omitempty"`
+	CreatedAt     time.Time        `gorm:"index" json:"created_at"`
+}
+
+func (Notification) TableName() string {
+	return "notifications"
+}
+
+func (n *Notification) BeforeCreate(tx *gorm.DB) error {
+	if n.ID == uuid.Nil {
+		n.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SavedSearch is a document search a user wants to be notified about.
+// The filters mirror the query parameters of GET /search.
+type SavedSearch struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Name         string     `gorm:"size:100;not null" json:"name"`
+	Query        string     `gorm:"size:200;not null" json:"query"`
+	SubjectID    *uuid.UUID `gorm:"type:uuid" json:"subject_id,omitempty"`
+	SemesterID   *uuid.UUID `gorm:"type:uuid" json:"semester_id,omitempty"`
+	CategoryID   *uuid.UUID `gorm:"type:uuid" json:"category_id,omitempty"`
+	Type         string     `gorm:"size:20" json:"type,omitempty"`
+	MimeType     string     `gorm:"size:100" json:"mime_type,omitempty"`
+	ExactMatch   bool       `gorm:"default:false" json:"exact"`
+	EmailEnabled bool       `gorm:"not null" json:"email_enabled"` // no default tag, so false is stored on create
+	LastRunAt    time.Time  `gorm:"not null" json:"last_run_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func (SavedSearch) TableName() string {
+	return "saved_searches"
+}
+
+func (s *SavedSearch) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
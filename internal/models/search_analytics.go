@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SearchQueryLog records one search made from the search box. It holds no
+// user reference; the query is normalized and personal data in it is masked
+// before it is stored.
+type SearchQueryLog struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Query         string     `gorm:"size:200;not null" json:"query"`
+	Scope         string     `gorm:"size:20;not null" json:"scope"`
+	SubjectID     *uuid.UUID `gorm:"type:uuid" json:"subject_id,omitempty"`
+	Filters       string     `gorm:"type:jsonb" json:"filters,omitempty"`
+	ResultCount   int64      `gorm:"not null" json:"result_count"`
+	ClickedType   string     `gorm:"size:20" json:"clicked_type,omitempty"`
+	ClickedID     *uuid.UUID `gorm:"type:uuid" json:"clicked_id,omitempty"`
+	ClickPosition *int       `json:"click_position,omitempty"`
+	ClickedAt     *time.Time `json:"clicked_at,omitempty"`
+	CreatedAt     time.Time  `gorm:"index" json:"created_at"`
+}
+
+func (SearchQueryLog) TableName() string {
+	return "search_query_logs"
+}
+
+func (s *SearchQueryLog) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+// SearchQueryDailyStat aggregates the logged searches of one day by query
+// and subject filter. Rows are recomputed from the logs, so they stay
+// available after the logs themselves are purged.
+type SearchQueryDailyStat struct {
+	ID          int64      `gorm:"primaryKey;autoIncrement" json:"-"`
+	Day         time.Time  `gorm:"type:date;not null;index" json:"day"`
+	Query       string     `gorm:"size:200;not null" json:"query"`
+	SubjectID   *uuid.UUID `gorm:"type:uuid;index" json:"subject_id,omitempty"`
+	Searches    int64      `gorm:"not null" json:"searches"`
+	ZeroResults int64      `gorm:"not null" json:"zero_results"`
+	Clicks      int64      `gorm:"not null" json:"clicks"`
+}
+
+func (SearchQueryDailyStat) TableName() string {
+	return "search_query_daily_stats"
+}
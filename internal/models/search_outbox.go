@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SearchEntityType string
+
+const (
+	SearchEntityDocument SearchEntityType = "document"
+	SearchEntityQuestion SearchEntityType = "question"
+	SearchEntityComment  SearchEntityType = "comment"
+	SearchEntitySubject  SearchEntityType = "subject"
+)
+
+type SearchOperation string
+
+const (
+	SearchOperationIndex  SearchOperation = "index"
+	SearchOperationDelete SearchOperation = "delete"
+)
+
+// SearchOutboxEntry is a pending search index change, written in the same
+// transaction as the database change it mirrors. The sequential ID orders
+// the changes of one entity. ClaimedUntil is set while a dispatcher applies
+// the change; an expired claim is picked up again.
+type SearchOutboxEntry struct {
+	ID            int64            `gorm:"primaryKey;autoIncrement" json:"id"`
+	EntityType    SearchEntityType `gorm:"type:varchar(20);not null;index:idx_search_outbox_entity" json:"entity_type"`
+	EntityID      uuid.UUID        `gorm:"type:uuid;not null;index:idx_search_outbox_entity" json:"entity_id"`
+	Operation     SearchOperation  `gorm:"type:varchar(10);not null" json:"operation"`
+	Attempts      int              `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time        `gorm:"not null;index" json:"next_attempt_at"`
+	ClaimedUntil  *time.Time       `json:"claimed_until,omitempty"`
+	LastError     string           `gorm:"type:text" json:"last_error,omitempty"`
+	CreatedAt     time.Time        `json:"created_at"`
+}
+
+func (SearchOutboxEntry) TableName() string {
+	return "search_outbox_entries"
+}
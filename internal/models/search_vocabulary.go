@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SearchSynonym makes searches for Term also match Synonym. A term with
+// several synonyms has one row per synonym.
+type SearchSynonym struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Term      string    `gorm:"size:100;not null;uniqueIndex:idx_search_synonym_pair" json:"term"`
+	Synonym   string    `gorm:"size:100;not null;uniqueIndex:idx_search_synonym_pair" json:"synonym"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (SearchSynonym) TableName() string {
+	return "search_synonyms"
+}
+
+func (s *SearchSynonym) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+// SearchStopWord is a word ignored in search queries
+type SearchStopWord struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Word      string    `gorm:"size:100;not null;uniqueIndex" json:"word"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (SearchStopWord) TableName() string {
+	return "search_stop_words"
+}
+
+func (w *SearchStopWord) BeforeCreate(tx *gorm.DB) error {
+	if w.ID == uuid.Nil {
+		w.ID = uuid.New()
+	}
+	return nil
+}
@@ -23,9 +23,11 @@ type Subject struct {
 	Documents []Document      `gorm:"foreignKey:SubjectID" json:"documents,omitempty"`
 	Comments  []Comment       `gorm:"foreignKey:SubjectID" json:"comments,omitempty"`
 	Questions []Question      `gorm:"foreignKey:SubjectID" json:"questions,omitempty"`
+	Requisites []SubjectRequisite `gorm:"foreignKey:SubjectID" json:"requisites,omitempty"`
 
 	// Computed
 	IsFavorite bool `gorm:"->" json:"is_favorite"`
+	IsEnrolled bool `gorm:"->" json:"is_enrolled"`
 }
 
 func (Subject) TableName() string {
@@ -39,12 +41,28 @@ func (s *Subject) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+type TeacherRole string
+
+const (
+	TeacherRoleGuarantor TeacherRole = "guarantor"
+	TeacherRoleLecturer  TeacherRole = "lecturer"
+	TeacherRoleSeminar   TeacherRole = "seminar"
+)
+
+// SubjectTeacher links a teacher to a subject. Ratings belong to the link, so
+// a teacher is rated per subject. TeacherName repeats the teacher's name for
+// older clients.
 type SubjectTeacher struct {
-	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	SubjectID   uuid.UUID `gorm:"type:uuid;not null" json:"subject_id"`
-	TeacherName string    `gorm:"size:200;not null" json:"teacher_name"`
-	TopicCS     string    `gorm:"size:300" json:"topic_cs"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          uuid.UUID   `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	SubjectID   uuid.UUID   `gorm:"type:uuid;not null" json:"subject_id"`
+	TeacherID   uuid.UUID   `gorm:"type:uuid;index" json:"teacher_id"`
+	TeacherName string      `gorm:"size:200;not null" json:"teacher_name"`
+	Role        TeacherRole `gorm:"type:varchar(20);not null;default:'lecturer'" json:"role"`
+	TopicCS     string      `gorm:"size:300" json:"topic_cs"`
+	CreatedAt   time.Time   `json:"created_at"`
+
+	// Relations
+	Teacher *Teacher `gorm:"foreignKey:TeacherID" json:"teacher,omitempty"`
 
 	// Computed fields (not stored in DB, computed in queries)
 	AverageRating *float64 `gorm:"->" json:"average_rating,omitempty"`
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RequisiteKind string
+
+const (
+	// RequisitePrerequisite must be passed before the subject is taken
+	RequisitePrerequisite RequisiteKind = "prerequisite"
+	// RequisiteCorequisite must be passed before or taken with the subject
+	RequisiteCorequisite RequisiteKind = "corequisite"
+)
+
+// SubjectRequisite is a subject that must be passed before, or taken together
+// with, another subject
+type SubjectRequisite struct {
+	ID                uuid.UUID     `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	SubjectID         uuid.UUID     `gorm:"type:uuid;not null;uniqueIndex:idx_subject_requisite" json:"subject_id"`
+	RequiredSubjectID uuid.UUID     `gorm:"type:uuid;not null;uniqueIndex:idx_subject_requisite;index" json:"required_subject_id"`
+	Kind              RequisiteKind `gorm:"type:varchar(20);not null" json:"kind"`
+	CreatedAt         time.Time     `json:"created_at"`
+
+	// Relations
+	RequiredSubject *Subject `gorm:"foreignKey:RequiredSubjectID" json:"required_subject,omitempty"`
+}
+
+func (SubjectRequisite) TableName() string {
+	return "subject_requisites"
+}
+
+func (r *SubjectRequisite) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+type StudyPlanStatus string
+
+const (
+	StudyPlanPassed  StudyPlanStatus = "passed"
+	StudyPlanPlanned StudyPlanStatus = "planned"
+)
+
+// StudyPlanEntry is a subject a user has passed or plans to take
+type StudyPlanEntry struct {
+	ID        uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID       `gorm:"type:uuid;not null;uniqueIndex:idx_study_plan_user_subject" json:"user_id"`
+	SubjectID uuid.UUID       `gorm:"type:uuid;not null;uniqueIndex:idx_study_plan_user_subject;index" json:"subject_id"`
+	Status    StudyPlanStatus `gorm:"type:varchar(20);not null" json:"status"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+
+	// Relations
+	Subject *Subject `gorm:"foreignKey:SubjectID" json:"subject,omitempty"`
+}
+
+func (StudyPlanEntry) TableName() string {
+	return "study_plan_entries"
+}
+
+func (e *StudyPlanEntry) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
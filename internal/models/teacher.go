@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Teacher is a lecturer who may teach several subjects. SubjectTeacher links
+// a teacher to a subject with their role and topic there.
+type Teacher struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name       string    `gorm:"size:200;not null;index" json:"name"`
+	Title      string    `gorm:"size:100" json:"title"`
+	Department string    `gorm:"size:200" json:"department"`
+	Profile    string    `gorm:"type:text" json:"profile"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	// Computed over the ratings of all subjects of the teacher
+	AverageRating *float64 `gorm:"->" json:"average_rating,omitempty"`
+	TotalRatings  *int     `gorm:"->" json:"total_ratings,omitempty"`
+	SubjectCount  *int     `gorm:"->" json:"subject_count,omitempty"`
+}
+
+func (Teacher) TableName() string {
+	return "teachers"
+}
+
+func (t *Teacher) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
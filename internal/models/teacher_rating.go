@@ -7,13 +7,42 @@ import (
 	"gorm.io/gorm"
 )
 
+type ReviewTag string
+
+const (
+	ReviewTagStrictGrading     ReviewTag = "strict_grading"
+	ReviewTagFairGrading       ReviewTag = "fair_grading"
+	ReviewTagClearExplanations ReviewTag = "clear_explanations"
+	ReviewTagEngaging          ReviewTag = "engaging"
+	ReviewTagHelpful           ReviewTag = "helpful"
+	ReviewTagWellOrganized     ReviewTag = "well_organized"
+	ReviewTagHeavyWorkload     ReviewTag = "heavy_workload"
+	ReviewTagAttendanceChecked ReviewTag = "attendance_checked"
+)
+
+// ReviewTags lists the tags a review can carry
+var ReviewTags = []ReviewTag{
+	ReviewTagStrictGrading, ReviewTagFairGrading, ReviewTagClearExplanations, ReviewTagEngaging,
+	ReviewTagHelpful, ReviewTagWellOrganized, ReviewTagHeavyWorkload, ReviewTagAttendanceChecked,
+}
+
+// TeacherRating is a user's 1-5 rating of a subject teacher with an optional
+// written review. Hidden reviews were removed by a moderator; their rating
+// still counts.
 type TeacherRating struct {
-	ID               uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	SubjectTeacherID uuid.UUID `gorm:"type:uuid;not null;index" json:"subject_teacher_id"`
-	UserID           uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
-	Rating           int       `gorm:"not null;check:rating >= 1 AND rating <= 5" json:"rating"`
-	CreatedAt        time.Time `json:"created_at"`
-	UpdatedAt        time.Time `json:"updated_at"`
+	ID               uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	SubjectTeacherID uuid.UUID  `gorm:"type:uuid;not null;index" json:"subject_teacher_id"`
+	UserID           uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Rating           int        `gorm:"not null;check:rating >= 1 AND rating <= 5" json:"rating"`
+	Review           string     `gorm:"type:text" json:"review,omitempty"`
+	Anonymous        bool       `gorm:"not null;default:false" json:"anonymous"`
+	SemesterTaken    string     `gorm:"size:50" json:"semester_taken,omitempty"`
+	Hidden           bool       `gorm:"not null;default:false" json:"hidden"`
+	HiddenReason     string     `gorm:"size:500" json:"hidden_reason,omitempty"`
+	HiddenBy         *uuid.UUID `gorm:"type:uuid" json:"hidden_by,omitempty"`
+	HiddenAt         *time.Time `json:"hidden_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
 
 	// Relations
 	SubjectTeacher SubjectTeacher `gorm:"foreignKey:SubjectTeacherID" json:"subject_teacher,omitempty"`
@@ -30,3 +59,45 @@ func (tr *TeacherRating) BeforeCreate(tx *gorm.DB) error {
 	}
 	return nil
 }
+
+// TeacherReviewTag is a tag of a teacher review
+type TeacherReviewTag struct {
+	RatingID uuid.UUID `gorm:"type:uuid;primaryKey" json:"rating_id"`
+	Tag      ReviewTag `gorm:"type:varchar(40);primaryKey;index" json:"tag"`
+}
+
+func (TeacherReviewTag) TableName() string {
+	return "teacher_review_tags"
+}
+
+type ReviewReportStatus string
+
+const (
+	ReviewReportOpen      ReviewReportStatus = "open"
+	ReviewReportDismissed ReviewReportStatus = "dismissed"
+	ReviewReportResolved  ReviewReportStatus = "resolved" // the review was hidden
+)
+
+// TeacherReviewReport is a user's report of an inappropriate review, waiting
+// in the moderation queue while open
+type TeacherReviewReport struct {
+	ID         uuid.UUID          `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	RatingID   uuid.UUID          `gorm:"type:uuid;not null;uniqueIndex:idx_review_report_reporter" json:"rating_id"`
+	ReporterID uuid.UUID          `gorm:"type:uuid;not null;uniqueIndex:idx_review_report_reporter" json:"reporter_id"`
+	Reason     string             `gorm:"size:500;not null" json:"reason"`
+	Status     ReviewReportStatus `gorm:"type:varchar(20);not null;default:'open';index" json:"status"`
+	ResolvedBy *uuid.UUID         `gorm:"type:uuid" json:"resolved_by,omitempty"`
+	ResolvedAt *time.Time         `json:"resolved_at,omitempty"`
+	CreatedAt  time.Time          `json:"created_at"`
+}
+
+func (TeacherReviewReport) TableName() string {
+	return "teacher_review_reports"
+}
+
+func (r *TeacherReviewReport) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
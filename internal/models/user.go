@@ -35,6 +35,9 @@ type User struct {
 	PasswordResetSentAt    *time.Time `json:"-"`
 	PasswordResetExpiresAt *time.Time `json:"-"`
 
+	// Storage quota override in bytes (nil uses the configured default)
+	StorageQuota *int64 `json:"storage_quota,omitempty"`
+
 	// Favorites
 	FavoriteSubjects  []Subject  `gorm:"many2many:user_favorite_subjects;" json:"favorite_subjects,omitempty"`
 	FavoriteDocuments []Document `gorm:"many2many:user_favorite_documents;" json:"favorite_documents,omitempty"`
@@ -1,6 +1,7 @@
 package router
 
 import (
+	"context"
 	"log"
 
 	"github.com/P3chys/entoo2-api/internal/config"
@@ -12,17 +13,64 @@ import (
 	"gorm.io/gorm"
 )
 
-func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
+// Setup wires services and routes. Background workers started here run until ctx is cancelled.
+func Setup(ctx context.Context, db *gorm.DB, cfg *config.Config) *gin.Engine {
 	// Initialize Services
 	storageService, err := services.NewStorageService(cfg)
 	if err != nil {
 		log.Printf("Warning: Failed to initialize storage service: %v", err)
 	}
 
-	tikaService := services.NewTextExtractionService(cfg)
-	searchService := services.NewSearchService(cfg)
+	var tikaService *services.TextExtractionService
+	if cfg.TikaURL != "" {
+		tikaService = services.NewTextExtractionService(cfg)
+	}
+	textExtractor := services.NewFallbackExtractor(tikaService, services.NewLocalTextExtractor())
+	searchService := services.NewSearchService(cfg, db)
 	activityService := services.NewActivityService(db)
 	emailService := services.NewEmailService(cfg)
+	jobService := services.NewJobService(db)
+	trashService := services.NewTrashService(db, cfg, storageService)
+	quotaService := services.NewQuotaService(db, cfg)
+	savedSearchService := services.NewSavedSearchService(db, cfg, searchService, emailService)
+	searchOutbox := services.NewSearchOutbox(db, searchService)
+	vocabularyService := services.NewSearchVocabularyService(db, searchService)
+	searchAnalytics := services.NewSearchAnalyticsService(db, cfg)
+	documentPages := services.NewDocumentPageService(db)
+	teacherService := services.NewTeacherService(db)
+	reviewService := services.NewTeacherReviewService(db)
+	offeringService := services.NewOfferingService(db, searchOutbox)
+	extractionQueue := services.NewExtractionQueue(db, cfg, storageService, textExtractor, searchOutbox)
+	cloneService := services.NewSemesterCloneService(db, extractionQueue, searchOutbox)
+	catalogueService := services.NewCatalogueService(db, searchOutbox)
+	requisiteService := services.NewRequisiteService(db)
+	enrollmentService := services.NewEnrollmentService(db)
+	deletionService := services.NewDeletionService(db, storageService, searchOutbox, jobService)
+
+	// Jobs left pending or running by a process that is gone never finish
+	if failed, err := jobService.FailInterruptedJobs(); err != nil {
+		log.Printf("Warning: Failed to check for interrupted jobs: %v", err)
+	} else if failed > 0 {
+		log.Printf("Marked %d interrupted jobs as failed", failed)
+	}
+
+	// Start background workers
+	go trashService.StartPurgeScheduler(ctx)
+	extractionQueue.Start(ctx)
+	searchOutbox.Start(ctx)
+	go savedSearchService.StartScheduler(ctx)
+	go searchAnalytics.StartScheduler(ctx)
+	go func() {
+		if err := vocabularyService.Apply(); err != nil {
+			log.Printf("Warning: Failed to apply search vocabulary: %v", err)
+		}
+	}()
+
+	// Initialize cache
+	cacheService, err := services.NewCacheService(cfg.RedisURL)
+	if err != nil {
+		log.Printf("Warning: Failed to initialize cache: %v. Caching will be disabled.", err)
+	}
 
 	// Initialize rate limiter
 	rateLimiter, err := middleware.NewRateLimiter(cfg.RedisURL)
@@ -82,38 +130,58 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 		{
 			// Auth
 			protected.GET("/auth/me", handlers.GetCurrentUser(db))
+			protected.GET("/auth/me/usage", handlers.GetMyUsage(quotaService))
 			protected.POST("/auth/logout", handlers.Logout())
 
 			// Semesters
 			protected.GET("/semesters", handlers.ListSemesters(db))
 			protected.GET("/semesters/:id", handlers.GetSemester(db))
 
+			// Academic years and terms
+			protected.GET("/academic-years", handlers.ListAcademicYears(offeringService))
+			protected.GET("/academic-years/:id", handlers.GetAcademicYear(offeringService))
+
 			// Subjects
 			protected.GET("/subjects", handlers.ListSubjects(db))
 			protected.GET("/subjects/:id", handlers.GetSubject(db))
+			protected.GET("/subjects/:id/offerings", handlers.ListSubjectOfferings(offeringService))
+			protected.GET("/subjects/:id/prerequisites", handlers.GetSubjectPrerequisites(requisiteService))
 			protected.POST("/subjects/:id/favorite", handlers.ToggleFavoriteSubject(db))
+			protected.POST("/subjects/:id/enroll", handlers.EnrollSubject(enrollmentService))
+			protected.DELETE("/subjects/:id/enroll", handlers.UnenrollSubject(enrollmentService))
+
+			// Personal views
+			protected.GET("/me/subjects", handlers.ListMySubjects(enrollmentService))
+			protected.GET("/me/dashboard", handlers.GetDashboard(enrollmentService))
 
 			// Documents
-			protected.POST("/subjects/:id/documents", handlers.UploadDocument(db, cfg, storageService, tikaService, searchService, activityService))
+			protected.POST("/subjects/:id/documents", handlers.UploadDocument(db, cfg, storageService, extractionQueue, searchOutbox, activityService, quotaService))
 			protected.GET("/subjects/:id/documents", handlers.ListDocuments(db))
 			protected.POST("/documents/:id/favorite", handlers.ToggleFavoriteDocument(db))
 			protected.GET("/documents/:id", handlers.GetDocument(db))
+			protected.GET("/documents/:id/search", handlers.SearchDocument(db, documentPages))
 			protected.GET("/documents/:id/download", handlers.DownloadDocument(db, storageService, activityService))
-			protected.DELETE("/documents/:id", handlers.DeleteDocument(db, storageService, searchService, activityService))
+			protected.DELETE("/documents/:id", handlers.DeleteDocument(db, searchOutbox, activityService))
+			protected.POST("/documents/:id/restore", handlers.RestoreDocument(db, searchOutbox, activityService))
 
 			// Categories
 			protected.GET("/subjects/:id/categories", handlers.ListCategories(db))
 
 			// Comments
-			protected.POST("/subjects/:id/comments", handlers.CreateComment(db))
+			protected.POST("/subjects/:id/comments", handlers.CreateComment(db, searchOutbox))
 			protected.GET("/subjects/:id/comments", handlers.GetCommentsBySubject(db))
-			protected.DELETE("/comments/:id", handlers.DeleteComment(db))
+			protected.DELETE("/comments/:id", handlers.DeleteComment(db, searchOutbox))
 
 			// Questions & Answers
-			protected.POST("/subjects/:id/questions", handlers.CreateQuestion(db))
+			protected.POST("/subjects/:id/questions", handlers.CreateQuestion(db, searchOutbox))
 			protected.GET("/subjects/:id/questions", handlers.GetQuestionsBySubject(db))
-			protected.DELETE("/questions/:id", handlers.DeleteQuestion(db))
-			protected.POST("/questions/:id/answers", handlers.CreateAnswer(db, cfg, storageService, tikaService, searchService))
+			protected.DELETE("/questions/:id", handlers.DeleteQuestion(db, searchOutbox))
+			protected.POST("/questions/:id/answers", handlers.CreateAnswer(db, cfg, storageService, extractionQueue, searchOutbox, quotaService))
+
+			// Study plan
+			protected.GET("/me/study-plan", handlers.GetStudyPlan(requisiteService))
+			protected.PUT("/me/study-plan/:id", handlers.SetStudyPlanEntry(requisiteService))
+			protected.DELETE("/me/study-plan/:id", handlers.RemoveStudyPlanEntry(requisiteService))
 
 			// Activities
 			protected.GET("/activities/recent", handlers.GetRecentActivities(activityService))
@@ -121,13 +189,33 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 			// Favorites
 			protected.GET("/favorites", handlers.ListFavorites(db))
 
-			// Teacher ratings
-			protected.POST("/teachers/:id/rate", handlers.RateTeacher(db))
-			protected.DELETE("/teachers/:id/rate", handlers.DeleteTeacherRating(db))
-			protected.GET("/teachers/:id/ratings", handlers.GetTeacherRatings(db))
+			// Teachers
+			protected.GET("/teachers", handlers.ListTeachers(teacherService))
+			protected.GET("/teachers/:id", handlers.GetTeacher(teacherService))
+
+			// Teacher ratings, per subject: the ID is that of the subject teacher
+			protected.POST("/subject-teachers/:id/rate", handlers.RateTeacher(reviewService))
+			protected.DELETE("/subject-teachers/:id/rate", handlers.DeleteTeacherRating(reviewService))
+			protected.GET("/subject-teachers/:id/ratings", handlers.GetTeacherRatings(reviewService))
+			// Deprecated: the former rating paths, which also take a subject teacher ID
+			protected.POST("/teachers/:id/rate", handlers.RateTeacher(reviewService))
+			protected.DELETE("/teachers/:id/rate", handlers.DeleteTeacherRating(reviewService))
+			protected.GET("/teachers/:id/ratings", handlers.GetTeacherRatings(reviewService))
+			protected.POST("/reviews/:id/report", handlers.ReportTeacherReview(reviewService))
 
 			// Search
-			protected.GET("/search", handlers.Search(searchService))
+			protected.GET("/search", handlers.Search(searchService, searchAnalytics, documentPages))
+			protected.POST("/search/:id/click", handlers.RecordSearchClick(searchAnalytics))
+			protected.GET("/search/suggest", handlers.SearchSuggest(searchService, cacheService))
+			protected.GET("/search/saved", handlers.ListSavedSearches(db))
+			protected.POST("/search/saved", handlers.CreateSavedSearch(db))
+			protected.PUT("/search/saved/:id", handlers.UpdateSavedSearch(db))
+			protected.DELETE("/search/saved/:id", handlers.DeleteSavedSearch(db))
+
+			// Notifications
+			protected.GET("/notifications", handlers.ListNotifications(db))
+			protected.POST("/notifications/read-all", handlers.MarkAllNotificationsRead(db))
+			protected.POST("/notifications/:id/read", handlers.MarkNotificationRead(db))
 		}
 
 		// Admin routes
@@ -137,18 +225,71 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 			// Semester management
 			admin.POST("/semesters", handlers.CreateSemester(db))
 			admin.PUT("/semesters/:id", handlers.UpdateSemester(db))
-			admin.DELETE("/semesters/:id", handlers.DeleteSemester(db))
+			admin.DELETE("/semesters/:id", handlers.DeleteSemester(deletionService))
+			admin.POST("/semesters/:id/clone", handlers.CloneSemester(cloneService))
 
 			// Subject management
-			admin.POST("/subjects", handlers.CreateSubject(db))
-			admin.PUT("/subjects/:id", handlers.UpdateSubject(db))
-			admin.DELETE("/subjects/:id", handlers.DeleteSubject(db))
+			admin.POST("/subjects", handlers.CreateSubject(db, searchOutbox))
+			admin.PUT("/subjects/:id", handlers.UpdateSubject(db, searchOutbox))
+			admin.DELETE("/subjects/:id", handlers.DeleteSubject(deletionService))
+			admin.POST("/catalogue/import", handlers.ImportSubjectCatalogue(catalogueService))
+			admin.GET("/catalogue/export", handlers.ExportSubjectCatalogue(catalogueService))
+
+			// Academic years, terms and subject offerings
+			admin.POST("/academic-years", handlers.CreateAcademicYear(offeringService))
+			admin.DELETE("/academic-years/:id", handlers.DeleteAcademicYear(offeringService))
+			admin.PUT("/terms/:id", handlers.UpdateTerm(offeringService))
+			admin.POST("/subjects/:id/offerings", handlers.CreateSubjectOffering(offeringService))
+			admin.PUT("/offerings/:id", handlers.UpdateSubjectOffering(offeringService))
+			admin.DELETE("/offerings/:id", handlers.DeleteSubjectOffering(offeringService))
+			admin.POST("/offerings/:id/exam-dates", handlers.CreateExamDate(offeringService))
+			admin.DELETE("/exam-dates/:id", handlers.DeleteExamDate(offeringService))
+			admin.PUT("/documents/:id/offering", handlers.SetDocumentOffering(db, searchOutbox))
+
+			// Teacher management
+			admin.POST("/teachers", handlers.CreateTeacher(teacherService))
+			admin.PUT("/teachers/:id", handlers.UpdateTeacher(teacherService))
+
+			// Review moderation
+			admin.GET("/reviews/reports", handlers.ListReviewReports(reviewService))
+			admin.POST("/reviews/reports/:id/dismiss", handlers.DismissReviewReport(reviewService))
+			admin.POST("/reviews/:id/hide", handlers.HideTeacherReview(reviewService))
+			admin.POST("/reviews/:id/unhide", handlers.UnhideTeacherReview(reviewService))
 
 			// Category management
 			admin.POST("/subjects/:id/categories", handlers.CreateCategory(db))
-			admin.PUT("/categories/:id", handlers.UpdateCategory(db))
+			admin.PUT("/categories/:id", handlers.UpdateCategory(db, searchOutbox))
 			admin.DELETE("/categories/:id", handlers.DeleteCategory(db))
 			admin.PUT("/categories/reorder", handlers.ReorderCategories(db))
+
+			// Bulk document upload
+			admin.POST("/subjects/:id/documents/zip", handlers.UploadDocumentsZip(db, storageService, extractionQueue, searchOutbox, activityService, quotaService, jobService))
+
+			// Trash bin
+			admin.GET("/trash", handlers.ListTrash(db, trashService))
+			admin.GET("/subjects/:id/trash", handlers.ListTrash(db, trashService))
+
+			// Storage quotas
+			admin.GET("/storage/report", handlers.GetStorageReport(quotaService))
+			admin.PUT("/users/:id/quota", handlers.SetUserQuota(quotaService))
+
+			// Text extraction queue
+			admin.GET("/extraction-jobs", handlers.ListExtractionJobs(extractionQueue))
+			admin.POST("/extraction-jobs/retry-failed", handlers.RetryFailedExtractionJobs(extractionQueue))
+			admin.POST("/extraction-jobs/:id/retry", handlers.RetryExtractionJob(extractionQueue))
+
+			// Search index outbox
+			admin.GET("/search/outbox", handlers.GetSearchOutboxStats(searchOutbox))
+
+			// Search synonyms and stop words
+			admin.GET("/search/vocabulary", handlers.GetSearchVocabulary(vocabularyService))
+			admin.PUT("/search/vocabulary", handlers.UpdateSearchVocabulary(vocabularyService))
+
+			// Search analytics
+			admin.GET("/search/analytics", handlers.GetSearchAnalytics(searchAnalytics))
+
+			// Background jobs
+			admin.GET("/jobs/:id", handlers.GetJob(jobService))
 		}
 	}
 
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// CacheService is a small JSON cache on top of Redis. A nil *CacheService is
+// valid and behaves as an always-empty cache, so callers work without Redis.
+type CacheService struct {
+	redis *redis.Client
+}
+
+// NewCacheService connects to Redis
+func NewCacheService(redisURL string) (*CacheService, error) {
+	opt, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
+	}
+
+	client := redis.NewClient(opt)
+
+	// Test connection
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	return &CacheService{redis: client}, nil
+}
+
+// GetJSON decodes the cached value of key into dest and reports whether it was found
+func (s *CacheService) GetJSON(ctx context.Context, key string, dest interface{}) (bool, error) {
+	if s == nil {
+		return false, nil
+	}
+
+	data, err := s.redis.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, dest); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SetJSON stores value under key for ttl
+func (s *CacheService) SetJSON(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	if s == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return s.redis.Set(ctx, key, data, ttl).Err()
+}
@@ -0,0 +1,570 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Catalogue file formats
+const (
+	CatalogueFormatCSV  = "csv"
+	CatalogueFormatJSON = "json"
+)
+
+const (
+	maxCatalogueCredits = 60
+	// catalogueTeacherSeparator separates teachers in a CSV cell and
+	// catalogueTeacherFieldSeparator the name, role and topic of one teacher
+	catalogueTeacherSeparator      = ";"
+	catalogueTeacherFieldSeparator = "|"
+)
+
+// ErrInvalidCatalogue is returned when rows of an import fail validation;
+// nothing is stored and the report lists the errors per row
+var ErrInvalidCatalogue = errors.New("catalogue has invalid rows")
+
+// catalogueColumns are the CSV columns in export order
+var catalogueColumns = []string{"code", "name_cs", "semester", "credits", "description_cs", "teachers"}
+
+// CatalogueTeacher is a teacher of a catalogue subject
+type CatalogueTeacher struct {
+	Name    string `json:"name"`
+	Role    string `json:"role,omitempty"`
+	TopicCS string `json:"topic_cs,omitempty"`
+}
+
+// CatalogueEntry is one subject of the catalogue, keyed by its code. Nil
+// optional fields leave the stored values unchanged on import.
+type CatalogueEntry struct {
+	Code          string              `json:"code"`
+	NameCS        string              `json:"name_cs"`
+	Semester      string              `json:"semester"`
+	Credits       *int                `json:"credits,omitempty"`
+	DescriptionCS *string             `json:"description_cs,omitempty"`
+	Teachers      *[]CatalogueTeacher `json:"teachers,omitempty"`
+
+	row         int
+	parseErrors []string
+}
+
+// CatalogueChange is the old and new value of a changed field
+type CatalogueChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// Import actions of a catalogue row
+const (
+	CatalogueActionCreate    = "create"
+	CatalogueActionUpdate    = "update"
+	CatalogueActionUnchanged = "unchanged"
+	CatalogueActionInvalid   = "invalid"
+)
+
+// CatalogueRowResult is the outcome, or in a dry run the planned outcome,
+// of one row
+type CatalogueRowResult struct {
+	Row     int                        `json:"row"`
+	Code    string                     `json:"code"`
+	Action  string                     `json:"action"`
+	Changes map[string]CatalogueChange `json:"changes,omitempty"`
+	Errors  []string                   `json:"errors,omitempty"`
+}
+
+// CatalogueImportReport summarises an import
+type CatalogueImportReport struct {
+	DryRun           bool                 `json:"dry_run"`
+	Created          int                  `json:"created"`
+	Updated          int                  `json:"updated"`
+	Unchanged        int                  `json:"unchanged"`
+	Invalid          int                  `json:"invalid"`
+	CreatedSemesters []string             `json:"created_semesters"`
+	Rows             []CatalogueRowResult `json:"rows"`
+	// SubjectIDs are the created and updated subjects, to be reindexed
+	SubjectIDs []uuid.UUID `json:"-"`
+}
+
+type CatalogueImportOptions struct {
+	DryRun bool
+	// CreateSemesters creates semesters named in the catalogue that do not
+	// exist; otherwise such rows are invalid
+	CreateSemesters bool
+	AdminID         uuid.UUID
+}
+
+// ParseCatalogue reads a catalogue in the given format
+func ParseCatalogue(r io.Reader, format string) ([]CatalogueEntry, error) {
+	switch format {
+	case CatalogueFormatCSV:
+		return ParseCatalogueCSV(r)
+	case CatalogueFormatJSON:
+		return ParseCatalogueJSON(r)
+	}
+	return nil, fmt.Errorf("unknown catalogue format %q", format)
+}
+
+// ParseCatalogueJSON reads a JSON array of catalogue entries
+func ParseCatalogueJSON(r io.Reader) ([]CatalogueEntry, error) {
+	var entries []CatalogueEntry
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("invalid JSON catalogue: %w", err)
+	}
+	for i := range entries {
+		entries[i].row = i + 1
+	}
+	return entries, nil
+}
+
+// ParseCatalogueCSV reads a CSV catalogue with a header row. The code,
+// name_cs and semester columns are required. Teachers are separated by ";"
+// and written as name|role|topic, where role and topic may be left out.
+// Empty credits, description_cs and teachers cells leave the stored values
+// unchanged, like fields missing from JSON. Errors in a cell are reported
+// with its row rather than failing the file.
+func ParseCatalogueCSV(r io.Reader) ([]CatalogueEntry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV catalogue: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, required := range []string{"code", "name_cs", "semester"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("invalid CSV catalogue: missing column %q", required)
+		}
+	}
+
+	var entries []CatalogueEntry
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV catalogue: %w", err)
+		}
+		cell := func(column string) (string, bool) {
+			i, ok := columns[column]
+			if !ok {
+				return "", false
+			}
+			if i >= len(record) {
+				return "", true
+			}
+			return strings.TrimSpace(record[i]), true
+		}
+
+		entry := CatalogueEntry{row: line}
+		entry.Code, _ = cell("code")
+		entry.NameCS, _ = cell("name_cs")
+		entry.Semester, _ = cell("semester")
+		if value, _ := cell("credits"); value != "" {
+			credits, err := strconv.Atoi(value)
+			if err != nil {
+				entry.parseErrors = append(entry.parseErrors, fmt.Sprintf("credits %q is not a number", value))
+			}
+			entry.Credits = &credits
+		}
+		if value, _ := cell("description_cs"); value != "" {
+			entry.DescriptionCS = &value
+		}
+		if value, _ := cell("teachers"); value != "" {
+			if teachers := parseCatalogueTeachers(value); len(teachers) > 0 {
+				entry.Teachers = &teachers
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func parseCatalogueTeachers(value string) []CatalogueTeacher {
+	teachers := []CatalogueTeacher{}
+	for _, item := range strings.Split(value, catalogueTeacherSeparator) {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		fields := strings.SplitN(item, catalogueTeacherFieldSeparator, 3)
+		teacher := CatalogueTeacher{Name: strings.TrimSpace(fields[0])}
+		if len(fields) > 1 {
+			teacher.Role = strings.TrimSpace(fields[1])
+		}
+		if len(fields) > 2 {
+			teacher.TopicCS = strings.TrimSpace(fields[2])
+		}
+		teachers = append(teachers, teacher)
+	}
+	return teachers
+}
+
+func formatCatalogueTeachers(teachers []CatalogueTeacher) string {
+	items := make([]string, len(teachers))
+	for i, teacher := range teachers {
+		item := teacher.Name + catalogueTeacherFieldSeparator + teacher.Role
+		if teacher.TopicCS != "" {
+			item += catalogueTeacherFieldSeparator + teacher.TopicCS
+		}
+		items[i] = item
+	}
+	return strings.Join(items, catalogueTeacherSeparator+" ")
+}
+
+// WriteCatalogue writes entries in the given format
+func WriteCatalogue(w io.Writer, entries []CatalogueEntry, format string) error {
+	switch format {
+	case CatalogueFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	case CatalogueFormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(catalogueColumns); err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			record := []string{entry.Code, entry.NameCS, entry.Semester, "", "", ""}
+			if entry.Credits != nil {
+				record[3] = strconv.Itoa(*entry.Credits)
+			}
+			if entry.DescriptionCS != nil {
+				record[4] = *entry.DescriptionCS
+			}
+			if entry.Teachers != nil {
+				record[5] = formatCatalogueTeachers(*entry.Teachers)
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+	return fmt.Errorf("unknown catalogue format %q", format)
+}
+
+// CreateDefaultCategories adds the "Unassigned" category of each document
+// type, which uploads without a category fall into
+func CreateDefaultCategories(tx *gorm.DB, subjectID, createdBy uuid.UUID) error {
+	for _, docType := range []string{"lecture", "seminar", "other"} {
+		category := models.DocumentCategory{
+			SubjectID:  subjectID,
+			Type:       docType,
+			NameCS:     UnassignedCategoryName,
+			NameEN:     "Unassigned",
+			OrderIndex: 999, // Put at end
+			CreatedBy:  createdBy,
+		}
+		if err := tx.Create(&category).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type CatalogueService struct {
+	db     *gorm.DB
+	outbox *SearchOutbox
+}
+
+func NewCatalogueService(db *gorm.DB, outbox *SearchOutbox) *CatalogueService {
+	return &CatalogueService{db: db, outbox: outbox}
+}
+
+// Export returns all subjects as catalogue entries ordered by code
+func (s *CatalogueService) Export() ([]CatalogueEntry, error) {
+	var subjects []models.Subject
+	if err := s.db.Preload("Semester").
+		Preload("Teachers", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at")
+		}).
+		Order("code").Find(&subjects).Error; err != nil {
+		return nil, err
+	}
+
+	entries := make([]CatalogueEntry, len(subjects))
+	for i, subject := range subjects {
+		entries[i] = catalogueEntryOf(subject)
+	}
+	return entries, nil
+}
+
+func catalogueEntryOf(subject models.Subject) CatalogueEntry {
+	credits := subject.Credits
+	description := subject.DescriptionCS
+	teachers := make([]CatalogueTeacher, len(subject.Teachers))
+	for i, link := range subject.Teachers {
+		teachers[i] = CatalogueTeacher{Name: link.TeacherName, Role: string(link.Role), TopicCS: link.TopicCS}
+	}
+	return CatalogueEntry{
+		Code:          subject.Code,
+		NameCS:        subject.NameCS,
+		Semester:      subject.Semester.NameCS,
+		Credits:       &credits,
+		DescriptionCS: &description,
+		Teachers:      &teachers,
+	}
+}
+
+// catalogueTeachersKey compares teacher lists regardless of name case and spacing
+func catalogueTeachersKey(teachers []CatalogueTeacher) string {
+	keys := make([]string, len(teachers))
+	for i, teacher := range teachers {
+		role := teacher.Role
+		if role == "" {
+			role = string(models.TeacherRoleLecturer)
+		}
+		keys[i] = strings.ToLower(NormalizeTeacherName(teacher.Name)) + "|" + role + "|" + teacher.TopicCS
+	}
+	return strings.Join(keys, ";")
+}
+
+// Import creates or updates subjects by code. Every row is validated first;
+// when any row is invalid nothing is stored and ErrInvalidCatalogue is
+// returned with the report. A dry run only reports the changes.
+func (s *CatalogueService) Import(entries []CatalogueEntry, opts CatalogueImportOptions) (*CatalogueImportReport, error) {
+	report := &CatalogueImportReport{
+		DryRun:           opts.DryRun,
+		CreatedSemesters: []string{},
+		Rows:             make([]CatalogueRowResult, len(entries)),
+	}
+
+	var semesters []models.Semester
+	if err := s.db.Find(&semesters).Error; err != nil {
+		return nil, err
+	}
+	semesterByName := make(map[string]models.Semester, len(semesters))
+	for _, semester := range semesters {
+		semesterByName[strings.ToLower(strings.TrimSpace(semester.NameCS))] = semester
+	}
+
+	codes := make([]string, 0, len(entries))
+	for i := range entries {
+		entries[i].Code = strings.TrimSpace(entries[i].Code)
+		codes = append(codes, entries[i].Code)
+	}
+	var existing []models.Subject
+	if err := s.db.Preload("Semester").
+		Preload("Teachers", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at")
+		}).
+		Where("code IN ?", codes).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	subjectByCode := make(map[string]models.Subject, len(existing))
+	for _, subject := range existing {
+		subjectByCode[subject.Code] = subject
+	}
+
+	newSemesters := make(map[string]string)
+	seenCodes := make(map[string]int, len(entries))
+	for i, entry := range entries {
+		result := CatalogueRowResult{Row: entry.row, Code: entry.Code, Errors: entry.parseErrors}
+		result.Errors = append(result.Errors, validateCatalogueEntry(entry)...)
+		if row, ok := seenCodes[entry.Code]; ok && entry.Code != "" {
+			result.Errors = append(result.Errors, fmt.Sprintf("code %s is already used in row %d", entry.Code, row))
+		}
+		seenCodes[entry.Code] = entry.row
+
+		semesterKey := strings.ToLower(strings.TrimSpace(entry.Semester))
+		if _, ok := semesterByName[semesterKey]; !ok && semesterKey != "" {
+			if opts.CreateSemesters {
+				if _, ok := newSemesters[semesterKey]; !ok {
+					newSemesters[semesterKey] = strings.TrimSpace(entry.Semester)
+					report.CreatedSemesters = append(report.CreatedSemesters, strings.TrimSpace(entry.Semester))
+				}
+			} else {
+				result.Errors = append(result.Errors, fmt.Sprintf("semester %q does not exist", entry.Semester))
+			}
+		}
+
+		if len(result.Errors) > 0 {
+			result.Action = CatalogueActionInvalid
+			report.Invalid++
+		} else if subject, ok := subjectByCode[entry.Code]; ok {
+			result.Changes = catalogueChanges(catalogueEntryOf(subject), entry)
+			if len(result.Changes) == 0 {
+				result.Action = CatalogueActionUnchanged
+				report.Unchanged++
+			} else {
+				result.Action = CatalogueActionUpdate
+				report.Updated++
+			}
+		} else {
+			result.Action = CatalogueActionCreate
+			report.Created++
+		}
+		report.Rows[i] = result
+	}
+
+	if report.Invalid > 0 {
+		return report, ErrInvalidCatalogue
+	}
+	if opts.DryRun {
+		return report, nil
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for key, name := range newSemesters {
+			semester := models.Semester{NameCS: name, OrderIndex: len(semesterByName)}
+			if err := tx.Create(&semester).Error; err != nil {
+				return err
+			}
+			semesterByName[key] = semester
+		}
+
+		for i, entry := range entries {
+			result := report.Rows[i]
+			if result.Action == CatalogueActionUnchanged {
+				continue
+			}
+			semester := semesterByName[strings.ToLower(strings.TrimSpace(entry.Semester))]
+			subjectID, err := s.applyEntry(tx, entry, semester.ID, subjectByCode, result, opts.AdminID)
+			if err != nil {
+				return fmt.Errorf("row %d (%s): %w", entry.row, entry.Code, err)
+			}
+			report.SubjectIDs = append(report.SubjectIDs, subjectID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(report.CreatedSemesters)
+	s.outbox.Notify()
+	return report, nil
+}
+
+func (s *CatalogueService) applyEntry(tx *gorm.DB, entry CatalogueEntry, semesterID uuid.UUID, subjectByCode map[string]models.Subject, result CatalogueRowResult, adminID uuid.UUID) (uuid.UUID, error) {
+	subject, exists := subjectByCode[entry.Code]
+	subject.Code = entry.Code
+	subject.NameCS = strings.TrimSpace(entry.NameCS)
+	subject.SemesterID = semesterID
+	if entry.Credits != nil {
+		subject.Credits = *entry.Credits
+	}
+	if entry.DescriptionCS != nil {
+		subject.DescriptionCS = *entry.DescriptionCS
+	}
+
+	if exists {
+		if err := tx.Model(&models.Subject{}).Where("id = ?", subject.ID).Updates(map[string]interface{}{
+			"name_cs":        subject.NameCS,
+			"semester_id":    subject.SemesterID,
+			"credits":        subject.Credits,
+			"description_cs": subject.DescriptionCS,
+		}).Error; err != nil {
+			return uuid.Nil, err
+		}
+		// Search records of the subject's content carry its name and semester
+		_, renamed := result.Changes["name_cs"]
+		_, moved := result.Changes["semester"]
+		if renamed || moved {
+			if err := s.outbox.EnqueueSubjectContent(tx, subject.ID); err != nil {
+				return uuid.Nil, err
+			}
+		}
+	} else {
+		subject = models.Subject{
+			SemesterID:    subject.SemesterID,
+			NameCS:        subject.NameCS,
+			Code:          subject.Code,
+			DescriptionCS: subject.DescriptionCS,
+			Credits:       subject.Credits,
+		}
+		if err := tx.Create(&subject).Error; err != nil {
+			return uuid.Nil, err
+		}
+		if err := CreateDefaultCategories(tx, subject.ID, adminID); err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	// Teachers are synced only when they changed, so unchanged links keep their ratings
+	if _, changed := result.Changes["teachers"]; entry.Teachers != nil && (changed || !exists) {
+		inputs := make([]SubjectTeacherInput, len(*entry.Teachers))
+		for i, teacher := range *entry.Teachers {
+			inputs[i] = SubjectTeacherInput{
+				Name:    teacher.Name,
+				Role:    models.TeacherRole(teacher.Role),
+				TopicCS: teacher.TopicCS,
+			}
+		}
+		if _, err := SyncSubjectTeachers(tx, subject.ID, inputs); err != nil {
+			return uuid.Nil, err
+		}
+	}
+	return subject.ID, s.outbox.Enqueue(tx, models.SearchEntitySubject, subject.ID, models.SearchOperationIndex)
+}
+
+func validateCatalogueEntry(entry CatalogueEntry) []string {
+	var problems []string
+	if err := ValidateSubjectCode(entry.Code); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if name := strings.TrimSpace(entry.NameCS); name == "" {
+		problems = append(problems, "name_cs is required")
+	} else if len([]rune(name)) > 200 {
+		problems = append(problems, "name_cs must have at most 200 characters")
+	}
+	if strings.TrimSpace(entry.Semester) == "" {
+		problems = append(problems, "semester is required")
+	}
+	if entry.Credits != nil && (*entry.Credits < 0 || *entry.Credits > maxCatalogueCredits) {
+		problems = append(problems, fmt.Sprintf("credits must be between 0 and %d", maxCatalogueCredits))
+	}
+	if entry.Teachers != nil {
+		names := make(map[string]bool, len(*entry.Teachers))
+		for _, teacher := range *entry.Teachers {
+			name := NormalizeTeacherName(teacher.Name)
+			if name == "" {
+				problems = append(problems, "teacher name is required")
+				continue
+			}
+			if names[strings.ToLower(name)] {
+				problems = append(problems, fmt.Sprintf("teacher %q is listed twice", name))
+			}
+			names[strings.ToLower(name)] = true
+			if teacher.Role != "" && !IsValidTeacherRole(models.TeacherRole(teacher.Role)) {
+				problems = append(problems, fmt.Sprintf("teacher %q has unknown role %q", name, teacher.Role))
+			}
+		}
+	}
+	return problems
+}
+
+// catalogueChanges lists the fields of current that entry changes
+func catalogueChanges(current, entry CatalogueEntry) map[string]CatalogueChange {
+	changes := make(map[string]CatalogueChange)
+	if name := strings.TrimSpace(entry.NameCS); name != current.NameCS {
+		changes["name_cs"] = CatalogueChange{Old: current.NameCS, New: name}
+	}
+	if !strings.EqualFold(strings.TrimSpace(entry.Semester), current.Semester) {
+		changes["semester"] = CatalogueChange{Old: current.Semester, New: strings.TrimSpace(entry.Semester)}
+	}
+	if entry.Credits != nil && *entry.Credits != *current.Credits {
+		changes["credits"] = CatalogueChange{Old: *current.Credits, New: *entry.Credits}
+	}
+	if entry.DescriptionCS != nil && *entry.DescriptionCS != *current.DescriptionCS {
+		changes["description_cs"] = CatalogueChange{Old: *current.DescriptionCS, New: *entry.DescriptionCS}
+	}
+	if entry.Teachers != nil && catalogueTeachersKey(*entry.Teachers) != catalogueTeachersKey(*current.Teachers) {
+		changes["teachers"] = CatalogueChange{Old: *current.Teachers, New: *entry.Teachers}
+	}
+	return changes
+}
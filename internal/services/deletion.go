@@ -0,0 +1,498 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrHasDependencies is returned when a subject or semester still has
+	// content and the deletion was not asked to cascade
+	ErrHasDependencies = errors.New("still has dependent content")
+	// ErrDeletionInProgress is returned when a cascade of the same subject or
+	// semester is already running, on any instance
+	ErrDeletionInProgress = errors.New("deletion is already in progress")
+	// ErrStorageUnavailable is returned when stored files cannot be removed
+	ErrStorageUnavailable = errors.New("storage service unavailable")
+)
+
+// DeletionSummary counts what deleting a subject or semester would remove.
+// Teachers, categories and requisites are removed along with a subject; the
+// rest is user content that only a cascade deletes.
+type DeletionSummary struct {
+	Subjects         int64 `json:"subjects,omitempty"`
+	Documents        int64 `json:"documents"`
+	TrashedDocuments int64 `json:"trashed_documents"`
+	Categories       int64 `json:"categories"`
+	Comments         int64 `json:"comments"`
+	Questions        int64 `json:"questions"`
+	Answers          int64 `json:"answers"`
+	Teachers         int64 `json:"teachers"`
+	TeacherRatings   int64 `json:"teacher_ratings"`
+	Offerings        int64 `json:"offerings"`
+	Requisites       int64 `json:"requisites"`
+	Enrollments      int64 `json:"enrollments"`
+	StudyPlanEntries int64 `json:"study_plan_entries"`
+	Favorites        int64 `json:"favorites"`
+}
+
+// HasDependencies reports whether the summary holds content that only a
+// cascade may delete
+func (s DeletionSummary) HasDependencies() bool {
+	return s.Subjects+s.Documents+s.TrashedDocuments+s.Comments+s.Questions+s.TeacherRatings+
+		s.Offerings+s.Enrollments+s.StudyPlanEntries+s.Favorites > 0
+}
+
+// DeletionReport is the result of a cascade job
+type DeletionReport struct {
+	SemesterID       *uuid.UUID      `json:"semester_id,omitempty"`
+	Summary          DeletionSummary `json:"summary"`
+	DeletedSubjects  int             `json:"deleted_subjects"`
+	DeletedDocuments int             `json:"deleted_documents"`
+	DeletedObjects   int             `json:"deleted_objects"`
+	Errors           []string        `json:"errors"`
+}
+
+// DeletionService deletes subjects and semesters. Without dependent content
+// they are deleted right away; otherwise a cascade job removes documents with
+// their stored files and search entries, discussions, ratings, offerings and
+// user data in the background.
+type DeletionService struct {
+	db      *gorm.DB
+	storage *StorageService
+	outbox  *SearchOutbox
+	jobs    *JobService
+}
+
+func NewDeletionService(db *gorm.DB, storage *StorageService, outbox *SearchOutbox, jobs *JobService) *DeletionService {
+	return &DeletionService{
+		db:      db,
+		storage: storage,
+		outbox:  outbox,
+		jobs:    jobs,
+	}
+}
+
+// SubjectDependencies summarizes what deleting a subject would remove
+func (s *DeletionService) SubjectDependencies(subjectID uuid.UUID) (*DeletionSummary, error) {
+	if err := s.db.First(&models.Subject{}, "id = ?", subjectID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSubjectNotFound
+		}
+		return nil, err
+	}
+	return s.summarize([]uuid.UUID{subjectID})
+}
+
+// SemesterDependencies summarizes what deleting a semester and its subjects
+// would remove
+func (s *DeletionService) SemesterDependencies(semesterID uuid.UUID) (*DeletionSummary, error) {
+	subjectIDs, err := s.semesterSubjects(semesterID)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := s.summarize(subjectIDs)
+	if err != nil {
+		return nil, err
+	}
+	summary.Subjects = int64(len(subjectIDs))
+	return summary, nil
+}
+
+func (s *DeletionService) semesterSubjects(semesterID uuid.UUID) ([]uuid.UUID, error) {
+	if err := s.db.First(&models.Semester{}, "id = ?", semesterID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSemesterNotFound
+		}
+		return nil, err
+	}
+	var subjectIDs []uuid.UUID
+	err := s.db.Model(&models.Subject{}).Where("semester_id = ?", semesterID).Order("code").Pluck("id", &subjectIDs).Error
+	return subjectIDs, err
+}
+
+func (s *DeletionService) summarize(subjectIDs []uuid.UUID) (*DeletionSummary, error) {
+	summary := &DeletionSummary{}
+	if len(subjectIDs) == 0 {
+		return summary, nil
+	}
+
+	subjectTeachers := s.db.Model(&models.SubjectTeacher{}).Select("id").Where("subject_id IN ?", subjectIDs)
+	counts := []struct {
+		target *int64
+		query  *gorm.DB
+	}{
+		{&summary.Documents, s.db.Model(&models.Document{}).Where("subject_id IN ?", subjectIDs)},
+		{&summary.TrashedDocuments, s.db.Unscoped().Model(&models.Document{}).Where("subject_id IN ? AND deleted_at IS NOT NULL", subjectIDs)},
+		{&summary.Categories, s.db.Model(&models.DocumentCategory{}).Where("subject_id IN ?", subjectIDs)},
+		{&summary.Comments, s.db.Model(&models.Comment{}).Where("subject_id IN ?", subjectIDs)},
+		{&summary.Questions, s.db.Model(&models.Question{}).Where("subject_id IN ?", subjectIDs)},
+		{&summary.Answers, s.db.Model(&models.Answer{}).
+			Where("question_id IN (?)", s.db.Model(&models.Question{}).Select("id").Where("subject_id IN ?", subjectIDs))},
+		{&summary.Teachers, s.db.Model(&models.SubjectTeacher{}).Where("subject_id IN ?", subjectIDs)},
+		{&summary.TeacherRatings, s.db.Model(&models.TeacherRating{}).Where("subject_teacher_id IN (?)", subjectTeachers)},
+		{&summary.Offerings, s.db.Model(&models.SubjectOffering{}).Where("subject_id IN ?", subjectIDs)},
+		{&summary.Requisites, s.db.Model(&models.SubjectRequisite{}).
+			Where("subject_id IN ? OR required_subject_id IN ?", subjectIDs, subjectIDs)},
+		{&summary.Enrollments, s.db.Model(&models.Enrollment{}).Where("subject_id IN ?", subjectIDs)},
+		{&summary.StudyPlanEntries, s.db.Model(&models.StudyPlanEntry{}).Where("subject_id IN ?", subjectIDs)},
+	}
+	for _, count := range counts {
+		if err := count.query.Count(count.target).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	var favorites struct{ Subjects, Documents int64 }
+	if err := s.db.Raw(`SELECT
+		(SELECT COUNT(*) FROM user_favorite_subjects WHERE subject_id IN @ids) AS subjects,
+		(SELECT COUNT(*) FROM user_favorite_documents f JOIN documents d ON d.id = f.document_id WHERE d.subject_id IN @ids) AS documents`,
+		map[string]interface{}{"ids": subjectIDs}).Scan(&favorites).Error; err != nil {
+		return nil, err
+	}
+	summary.Favorites = favorites.Subjects + favorites.Documents
+	return summary, nil
+}
+
+// DeleteSubject deletes a subject with its teachers, categories and
+// requisites. A subject with other content is refused with ErrHasDependencies
+// and the summary.
+func (s *DeletionService) DeleteSubject(subjectID uuid.UUID) (*DeletionSummary, error) {
+	summary, err := s.SubjectDependencies(subjectID)
+	if err != nil {
+		return nil, err
+	}
+	if summary.HasDependencies() {
+		return summary, ErrHasDependencies
+	}
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		return s.deleteSubjectRows(tx, subjectID)
+	}); err != nil {
+		return nil, err
+	}
+	s.outbox.Notify()
+	return summary, nil
+}
+
+// DeleteSemester deletes a semester without subjects. A semester with
+// subjects is refused with ErrHasDependencies and the summary.
+func (s *DeletionService) DeleteSemester(semesterID uuid.UUID) (*DeletionSummary, error) {
+	summary, err := s.SemesterDependencies(semesterID)
+	if err != nil {
+		return nil, err
+	}
+	if summary.HasDependencies() {
+		return summary, ErrHasDependencies
+	}
+	if err := s.db.Delete(&models.Semester{}, "id = ?", semesterID).Error; err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// StartSubjectCascade starts a background job deleting a subject and
+// everything that belongs to it
+func (s *DeletionService) StartSubjectCascade(subjectID, adminID uuid.UUID) (*models.Job, error) {
+	summary, err := s.SubjectDependencies(subjectID)
+	if err != nil {
+		return nil, err
+	}
+	return s.startCascade(models.JobTypeSubjectDelete, adminID, nil, []uuid.UUID{subjectID}, summary)
+}
+
+// StartSemesterCascade starts a background job deleting a semester, its
+// subjects and everything that belongs to them
+func (s *DeletionService) StartSemesterCascade(semesterID, adminID uuid.UUID) (*models.Job, error) {
+	summary, err := s.SemesterDependencies(semesterID)
+	if err != nil {
+		return nil, err
+	}
+	subjectIDs, err := s.semesterSubjects(semesterID)
+	if err != nil {
+		return nil, err
+	}
+	return s.startCascade(models.JobTypeSemesterDelete, adminID, &semesterID, subjectIDs, summary)
+}
+
+func (s *DeletionService) startCascade(jobType models.JobType, adminID uuid.UUID, semesterID *uuid.UUID, subjectIDs []uuid.UUID, summary *DeletionSummary) (*models.Job, error) {
+	if s.storage == nil {
+		return nil, ErrStorageUnavailable
+	}
+
+	// The job holds advisory locks on the subjects and the semester while it runs
+	keys := append([]uuid.UUID{}, subjectIDs...)
+	if semesterID != nil {
+		keys = append(keys, *semesterID)
+	}
+	lock, err := s.jobs.TryLock(keys...)
+	if errors.Is(err, ErrJobLocked) {
+		return nil, ErrDeletionInProgress
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var jobSubject *uuid.UUID
+	if semesterID == nil {
+		jobSubject = &subjectIDs[0]
+	}
+	job, err := s.jobs.CreateJob(jobType, adminID, jobSubject)
+	if err != nil {
+		lock.Release()
+		return nil, err
+	}
+
+	if err := s.jobs.Run(job, lock, func() {
+		s.runCascade(job.ID, semesterID, subjectIDs, *summary)
+	}, nil); err != nil {
+		if failErr := s.jobs.FailJob(job.ID, nil, err); failErr != nil {
+			log.Printf("Failed to fail deletion job %s: %v", job.ID, failErr)
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
+func (s *DeletionService) runCascade(jobID uuid.UUID, semesterID *uuid.UUID, subjectIDs []uuid.UUID, summary DeletionSummary) {
+	report := DeletionReport{
+		SemesterID: semesterID,
+		Summary:    summary,
+		Errors:     []string{},
+	}
+
+	total := int(summary.Documents+summary.TrashedDocuments) + len(subjectIDs)
+	if err := s.jobs.StartJob(jobID, total); err != nil {
+		log.Printf("Failed to start deletion job %s: %v", jobID, err)
+	}
+
+	processed, failed := 0, 0
+	progress := func() {
+		if err := s.jobs.UpdateProgress(jobID, processed, failed); err != nil {
+			log.Printf("Failed to update progress of job %s: %v", jobID, err)
+		}
+	}
+
+	for _, subjectID := range subjectIDs {
+		var documents []models.Document
+		if err := s.db.Unscoped().Where("subject_id = ?", subjectID).Find(&documents).Error; err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("subject %s: %v", subjectID, err))
+			failed++
+			continue
+		}
+
+		documentsFailed := false
+		for _, document := range documents {
+			objectDeleted, err := s.deleteDocument(document)
+			processed++
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("document %s: %v", document.ID, err))
+				failed++
+			}
+			if err == nil {
+				report.DeletedDocuments++
+			} else {
+				documentsFailed = true
+			}
+			if objectDeleted {
+				report.DeletedObjects++
+			}
+			progress()
+		}
+
+		processed++
+		if documentsFailed {
+			report.Errors = append(report.Errors, fmt.Sprintf("subject %s: kept because some of its documents could not be deleted", subjectID))
+			failed++
+			progress()
+			continue
+		}
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			// Documents uploaded while the job ran
+			var remaining int64
+			if err := tx.Unscoped().Model(&models.Document{}).Where("subject_id = ?", subjectID).Count(&remaining).Error; err != nil {
+				return err
+			}
+			if remaining > 0 {
+				return fmt.Errorf("%d documents were added during the deletion", remaining)
+			}
+			return s.deleteSubjectRows(tx, subjectID)
+		})
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("subject %s: %v", subjectID, err))
+			failed++
+		} else {
+			report.DeletedSubjects++
+			s.outbox.Notify()
+		}
+		progress()
+	}
+
+	var jobErr error
+	if report.DeletedSubjects < len(subjectIDs) {
+		jobErr = fmt.Errorf("%d of %d subjects could not be deleted", len(subjectIDs)-report.DeletedSubjects, len(subjectIDs))
+	} else if semesterID != nil {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			// Subjects created while the job ran
+			var remaining int64
+			if err := tx.Model(&models.Subject{}).Where("semester_id = ?", *semesterID).Count(&remaining).Error; err != nil {
+				return err
+			}
+			if remaining > 0 {
+				return fmt.Errorf("%d subjects were added during the deletion", remaining)
+			}
+			return tx.Delete(&models.Semester{}, "id = ?", *semesterID).Error
+		})
+		if err != nil {
+			jobErr = fmt.Errorf("failed to delete semester: %w", err)
+		}
+	}
+
+	if jobErr != nil {
+		if err := s.jobs.FailJob(jobID, report, jobErr); err != nil {
+			log.Printf("Failed to fail deletion job %s: %v", jobID, err)
+		}
+		return
+	}
+	if err := s.jobs.CompleteJob(jobID, report); err != nil {
+		log.Printf("Failed to complete deletion job %s: %v", jobID, err)
+	}
+}
+
+// deleteDocument permanently deletes a document, including a trashed one,
+// and its search entry. Its stored file goes first, unless other documents
+// share it: should deleting the rows fail, a retry finds the document again,
+// while a row deleted first would leave an orphaned file behind.
+// objectDeleted reports whether the file was removed.
+func (s *DeletionService) deleteDocument(document models.Document) (objectDeleted bool, err error) {
+	var sharing int64
+	if err := s.db.Unscoped().Model(&models.Document{}).
+		Where("minio_path = ? AND id <> ?", document.MinIOPath, document.ID).
+		Count(&sharing).Error; err != nil {
+		return false, err
+	}
+	if sharing == 0 {
+		if err := s.storage.DeleteFile(document.MinIOPath); err != nil {
+			return false, fmt.Errorf("failed to delete stored file: %w", err)
+		}
+		objectDeleted = true
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id = ?", document.ID).Delete(&models.ExtractionJob{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("document_id = ?", document.ID).Delete(&models.Activity{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("document_id = ?", document.ID).Delete(&models.ImportFile{}).Error; err != nil {
+			return err
+		}
+		if err := deleteDocumentRows(tx, document.ID); err != nil {
+			return err
+		}
+		return s.outbox.Enqueue(tx, models.SearchEntityDocument, document.ID, models.SearchOperationDelete)
+	})
+	if err != nil {
+		return objectDeleted, err
+	}
+	s.outbox.Notify()
+	return objectDeleted, nil
+}
+
+// deleteSubjectRows deletes a subject row and everything that refers to it,
+// except documents, which have to be deleted first
+func (s *DeletionService) deleteSubjectRows(tx *gorm.DB, subjectID uuid.UUID) error {
+	var commentIDs []uuid.UUID
+	if err := tx.Model(&models.Comment{}).Where("subject_id = ?", subjectID).Pluck("id", &commentIDs).Error; err != nil {
+		return err
+	}
+	for _, id := range commentIDs {
+		if err := s.outbox.Enqueue(tx, models.SearchEntityComment, id, models.SearchOperationDelete); err != nil {
+			return err
+		}
+	}
+
+	var questionIDs []uuid.UUID
+	if err := tx.Model(&models.Question{}).Where("subject_id = ?", subjectID).Pluck("id", &questionIDs).Error; err != nil {
+		return err
+	}
+	for _, id := range questionIDs {
+		if err := s.outbox.Enqueue(tx, models.SearchEntityQuestion, id, models.SearchOperationDelete); err != nil {
+			return err
+		}
+	}
+
+	subjectTeachers := tx.Model(&models.SubjectTeacher{}).Select("id").Where("subject_id = ?", subjectID)
+	ratings := tx.Model(&models.TeacherRating{}).Select("id").Where("subject_teacher_id IN (?)", subjectTeachers)
+	offerings := tx.Model(&models.SubjectOffering{}).Select("id").Where("subject_id = ?", subjectID)
+	questions := tx.Model(&models.Question{}).Select("id").Where("subject_id = ?", subjectID)
+
+	if err := tx.Where("subject_id = ?", subjectID).Delete(&models.Comment{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("question_id IN (?)", questions).Delete(&models.Answer{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("subject_id = ?", subjectID).Delete(&models.Question{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("rating_id IN (?)", ratings).Delete(&models.TeacherReviewTag{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("rating_id IN (?)", ratings).Delete(&models.TeacherReviewReport{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("subject_teacher_id IN (?)", subjectTeachers).Delete(&models.TeacherRating{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("subject_id = ?", subjectID).Delete(&models.SubjectTeacher{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("offering_id IN (?)", offerings).Delete(&models.ExamDate{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("offering_id IN (?)", offerings).Delete(&models.OfferingTeacher{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("subject_id = ?", subjectID).Delete(&models.SubjectOffering{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("subject_id = ? OR required_subject_id = ?", subjectID, subjectID).Delete(&models.SubjectRequisite{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("subject_id = ?", subjectID).Delete(&models.StudyPlanEntry{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("subject_id = ?", subjectID).Delete(&models.Enrollment{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Exec("DELETE FROM user_favorite_subjects WHERE subject_id = ?", subjectID).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("subject_id = ?", subjectID).Delete(&models.ImportFile{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("subject_id = ?", subjectID).Delete(&models.Activity{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("subject_id = ?", subjectID).Delete(&models.Notification{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("subject_id = ?", subjectID).Delete(&models.DocumentCategory{}).Error; err != nil {
+		return err
+	}
+
+	result := tx.Delete(&models.Subject{}, "id = ?", subjectID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSubjectNotFound
+	}
+	return s.outbox.Enqueue(tx, models.SearchEntitySubject, subjectID, models.SearchOperationDelete)
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// UnassignedCategoryName is the category documents without a folder fall into
+const UnassignedCategoryName = "Nepřiřazeno"
+
+// mimeTypesByExtension maps file extensions of imported files to the MIME
+// types accepted by uploads. The standard library table does not know the
+// Office formats, so they are listed explicitly.
+var mimeTypesByExtension = map[string]string{
+	".pdf":  "application/pdf",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".xls":  "application/vnd.ms-excel",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".txt":  "text/plain",
+	".csv":  "text/csv",
+}
+
+// MimeTypeForFile returns the MIME type for a file name by its extension
+func MimeTypeForFile(filename string) string {
+	ext := strings.ToLower(path.Ext(filename))
+	if mimeType, ok := mimeTypesByExtension[ext]; ok {
+		return mimeType
+	}
+	mimeType, _, err := mime.ParseMediaType(mime.TypeByExtension(ext))
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return mimeType
+}
+
+// IsJunkFile reports OS metadata files that archives and folder copies tend
+// to include
+func IsJunkFile(name string) bool {
+	base := path.Base(strings.ReplaceAll(name, "\\", "/"))
+	return strings.HasPrefix(base, ".") || base == "Thumbs.db" || base == "desktop.ini"
+}
+
+// FolderDocumentType maps a folder name to a document type. The second
+// result is false when the name does not name a type and "other" is only
+// the fallback.
+func FolderDocumentType(folder string) (string, bool) {
+	name := foldAccents(strings.TrimSpace(folder))
+	switch {
+	case strings.HasPrefix(name, "lecture"), strings.HasPrefix(name, "prednas"):
+		return "lecture", true
+	case strings.HasPrefix(name, "seminar"), strings.HasPrefix(name, "cvic"):
+		return "seminar", true
+	case strings.HasPrefix(name, "other"), strings.HasPrefix(name, "ostatni"):
+		return "other", true
+	default:
+		return "other", false
+	}
+}
+
+// ResolveCategory returns the category of a subject with the given type and
+// name, creating it when it does not exist yet; created reports that. An
+// empty name selects the "Unassigned" category, which is never created: a
+// subject without one keeps such documents uncategorised, like uploads do.
+// Resolved IDs are cached by type and name.
+func ResolveCategory(db *gorm.DB, subjectID, userID uuid.UUID, docType, name string, cache map[string]uuid.UUID) (*uuid.UUID, bool, error) {
+	if name == "" {
+		name = UnassignedCategoryName
+	}
+	if len([]rune(name)) > 200 {
+		name = string([]rune(name)[:200])
+	}
+
+	key := docType + "/" + name
+	if id, ok := cache[key]; ok {
+		return &id, false, nil
+	}
+
+	var category models.DocumentCategory
+	err := db.Where("subject_id = ? AND type = ? AND name_cs = ?", subjectID, docType, name).First(&category).Error
+	if err == nil {
+		cache[key] = category.ID
+		return &category.ID, false, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, false, err
+	}
+
+	if name == UnassignedCategoryName {
+		return nil, false, nil
+	}
+
+	var maxOrder int
+	db.Model(&models.DocumentCategory{}).
+		Where("subject_id = ? AND type = ? AND order_index < 999", subjectID, docType).
+		Select("COALESCE(MAX(order_index), -1)").
+		Scan(&maxOrder)
+
+	category = models.DocumentCategory{
+		SubjectID:  subjectID,
+		Type:       docType,
+		NameCS:     name,
+		NameEN:     "",
+		OrderIndex: maxOrder + 1,
+		CreatedBy:  userID,
+	}
+	if err := db.Create(&category).Error; err != nil {
+		return nil, false, err
+	}
+
+	cache[key] = category.ID
+	return &category.ID, true, nil
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// pageBreak separates pages and slides in extracted text
+	pageBreak = "\f"
+
+	// maxHitPages caps the page numbers listed on a document search hit
+	maxHitPages = 10
+)
+
+// pageVectorUpdate computes the search_vector of a document's pages
+const pageVectorUpdate = `
+	UPDATE document_pages SET search_vector = to_tsvector(@config, left(content_text, @maxLength))
+	WHERE document_id = @id`
+
+// SplitPages splits extracted text at its page breaks. Text without page
+// breaks is a single page. Empty pages are kept so page numbers stay correct.
+func SplitPages(text string) []string {
+	pages := strings.Split(text, pageBreak)
+	for i, page := range pages {
+		pages[i] = strings.TrimSpace(page)
+	}
+	// Trailing breaks do not start another page
+	for len(pages) > 1 && pages[len(pages)-1] == "" {
+		pages = pages[:len(pages)-1]
+	}
+	return pages
+}
+
+// ReplaceDocumentPages stores the pages of a document's extracted text in
+// place of earlier ones. Empty pages are not stored.
+func ReplaceDocumentPages(tx *gorm.DB, documentID uuid.UUID, text string) error {
+	if err := tx.Where("document_id = ?", documentID).Delete(&models.DocumentPage{}).Error; err != nil {
+		return err
+	}
+
+	var pages []models.DocumentPage
+	for i, content := range SplitPages(text) {
+		if content == "" {
+			continue
+		}
+		pages = append(pages, models.DocumentPage{
+			DocumentID:  documentID,
+			PageNumber:  i + 1,
+			ContentText: content,
+		})
+	}
+	if len(pages) == 0 {
+		return nil
+	}
+	if err := tx.CreateInBatches(&pages, 200).Error; err != nil {
+		return err
+	}
+
+	return tx.Exec(pageVectorUpdate, map[string]interface{}{
+		"config":    textSearchConfig,
+		"maxLength": maxIndexedTextLength,
+		"id":        documentID,
+	}).Error
+}
+
+// DocumentPageHit is a page matching a search inside a document
+type DocumentPageHit struct {
+	PageNumber int    `json:"page_number"`
+	Snippet    string `json:"snippet"`
+}
+
+// DocumentPageService searches the pages of extracted documents. Queries
+// are rewritten with the search vocabulary like document searches, and every
+// term must match on the same page.
+type DocumentPageService struct {
+	db         *gorm.DB
+	vocabulary *queryVocabulary
+}
+
+func NewDocumentPageService(db *gorm.DB) *DocumentPageService {
+	return &DocumentPageService{db: db, vocabulary: newQueryVocabulary(db)}
+}
+
+// Search returns the pages of a document matching all query terms in page
+// order, with highlighted snippets
+func (s *DocumentPageService) Search(documentID uuid.UUID, query string, exactMatch bool, limit, offset int) ([]DocumentPageHit, int64, error) {
+	hits := []DocumentPageHit{}
+	tsQuery := s.vocabulary.tsQuery(query, exactMatch)
+	if tsQuery == "" {
+		return hits, 0, nil
+	}
+
+	matches := s.db.Model(&models.DocumentPage{}).
+		Where("document_id = ? AND search_vector @@ to_tsquery(?, ?)", documentID, textSearchConfig, tsQuery).
+		Session(&gorm.Session{})
+
+	var total int64
+	if err := matches.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if total == 0 {
+		return hits, 0, nil
+	}
+
+	err := matches.
+		Select("page_number, ts_headline(?, content_text, to_tsquery(?, ?), ?) AS snippet",
+			textSearchConfig, textSearchConfig, tsQuery, headlineOptions).
+		Order("page_number").
+		Limit(limit).
+		Offset(offset).
+		Scan(&hits).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return hits, total, nil
+}
+
+// AddPageNumbers sets "pages" on document search hits to the first pages
+// matching all query terms. Hits matching only across pages or on their
+// title get an empty list.
+func (s *DocumentPageService) AddPageNumbers(hits []interface{}, query string, exactMatch bool) error {
+	tsQuery := s.vocabulary.tsQuery(query, exactMatch)
+	byID := make(map[string]map[string]interface{}, len(hits))
+	ids := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		fields, ok := hit.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id, _ := fields["id"].(string)
+		if _, err := uuid.Parse(id); err != nil {
+			continue
+		}
+		fields["pages"] = []int{}
+		byID[id] = fields
+		ids = append(ids, id)
+	}
+	if tsQuery == "" || len(ids) == 0 {
+		return nil
+	}
+
+	var rows []struct {
+		DocumentID string
+		PageNumber int
+	}
+	err := s.db.Model(&models.DocumentPage{}).
+		Select("document_id, page_number").
+		Where("document_id IN ? AND search_vector @@ to_tsquery(?, ?)", ids, textSearchConfig, tsQuery).
+		Order("document_id, page_number").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		fields, ok := byID[row.DocumentID]
+		if !ok {
+			continue
+		}
+		if pages := fields["pages"].([]int); len(pages) < maxHitPages {
+			fields["pages"] = append(pages, row.PageNumber)
+		}
+	}
+	return nil
+}
@@ -151,6 +151,48 @@ func (s *EmailService) SendPasswordResetEmail(to, token, language string) error
 	return s.SendEmail(to, subject, body)
 }
 
+// SendSavedSearchEmail sends a summary of documents that matched the user's saved searches
+func (s *EmailService) SendSavedSearchEmail(to string, matches []SavedSearchMatch, language string) error {
+	if language != "cs" {
+		language = "en"
+	}
+
+	// Determine subject based on language
+	var subject string
+	if language == "cs" {
+		subject = "Nové dokumenty k uloženým hledáním - Entoo2"
+	} else {
+		subject = "New Documents for Your Saved Searches - Entoo2"
+	}
+
+	type documentLink struct {
+		SearchName   string
+		DocumentName string
+		SubjectCode  string
+		URL          string
+	}
+	documents := make([]documentLink, len(matches))
+	for i, match := range matches {
+		documents[i] = documentLink{
+			SearchName:   match.SearchName,
+			DocumentName: match.DocumentName,
+			SubjectCode:  match.SubjectCode,
+			URL:          fmt.Sprintf("%s/subjects/%s", s.appURL, match.SubjectID),
+		}
+	}
+
+	// Load and render template
+	body, err := s.renderTemplate(fmt.Sprintf("saved_search_%s.html", language), map[string]interface{}{
+		"Documents": documents,
+		"AppURL":    s.appURL,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render email template: %w", err)
+	}
+
+	return s.SendEmail(to, subject, body)
+}
+
 // renderTemplate loads and renders an email template
 func (s *EmailService) renderTemplate(templateName string, data map[string]interface{}) (string, error) {
 	templatePath := filepath.Join(s.templatesPath, templateName)
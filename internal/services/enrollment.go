@@ -0,0 +1,265 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const dashboardLimit = 10
+
+var (
+	// ErrNoCurrentTerm is returned when no term is given and none is running today
+	ErrNoCurrentTerm = errors.New("no term is running today, a term must be given")
+	// ErrAlreadyEnrolled is returned when a user is already enrolled in a
+	// subject in the term
+	ErrAlreadyEnrolled = errors.New("already enrolled in this subject in this term")
+	// ErrEnrollmentNotFound is returned when a user is not enrolled in a subject
+	ErrEnrollmentNotFound = errors.New("not enrolled in this subject")
+)
+
+// CurrentTerm returns the term whose dates include today, or nil when none
+// does or terms have no dates
+func CurrentTerm(db *gorm.DB) (*models.Term, error) {
+	today := time.Now().Format("2006-01-02")
+	var term models.Term
+	err := db.Preload("AcademicYear").
+		Where("starts_on <= ? AND (ends_on IS NULL OR ends_on >= ?)", today, today).
+		Order("starts_on DESC").
+		First(&term).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &term, nil
+}
+
+// DashboardDocument is a recent document of an enrolled subject
+type DashboardDocument struct {
+	ID           uuid.UUID `json:"id"`
+	SubjectID    uuid.UUID `json:"subject_id"`
+	SubjectCode  string    `json:"subject_code"`
+	OriginalName string    `json:"original_name"`
+	Type         string    `json:"type"`
+	MimeType     string    `json:"mime_type"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// DashboardQuestion is a question without answers in an enrolled subject
+type DashboardQuestion struct {
+	ID          uuid.UUID `json:"id"`
+	SubjectID   uuid.UUID `json:"subject_id"`
+	SubjectCode string    `json:"subject_code"`
+	Content     string    `json:"content"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// DashboardExamDate is an upcoming exam of an enrolled subject
+type DashboardExamDate struct {
+	ID          uuid.UUID `json:"id"`
+	SubjectID   uuid.UUID `json:"subject_id"`
+	SubjectCode string    `json:"subject_code"`
+	SubjectName string    `json:"subject_name"`
+	StartsAt    time.Time `json:"starts_at"`
+	Location    string    `json:"location"`
+	Note        string    `json:"note"`
+}
+
+// Dashboard is the personal overview of a user's enrolled subjects
+type Dashboard struct {
+	// Term is the term shown, nil when it covers all enrollments
+	Term                *models.Term        `json:"term"`
+	Enrollments         []models.Enrollment `json:"enrollments"`
+	LatestDocuments     []DashboardDocument `json:"latest_documents"`
+	UnansweredQuestions []DashboardQuestion `json:"unanswered_questions"`
+	UpcomingExams       []DashboardExamDate `json:"upcoming_exams"`
+}
+
+type EnrollmentService struct {
+	db *gorm.DB
+}
+
+func NewEnrollmentService(db *gorm.DB) *EnrollmentService {
+	return &EnrollmentService{db: db}
+}
+
+// resolveTerm returns the given term, or the current term when termID is nil
+func (s *EnrollmentService) resolveTerm(termID *uuid.UUID) (*models.Term, error) {
+	if termID == nil {
+		term, err := CurrentTerm(s.db)
+		if err != nil {
+			return nil, err
+		}
+		if term == nil {
+			return nil, ErrNoCurrentTerm
+		}
+		return term, nil
+	}
+
+	var term models.Term
+	if err := s.db.Preload("AcademicYear").First(&term, "id = ?", *termID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTermNotFound
+		}
+		return nil, err
+	}
+	return &term, nil
+}
+
+// Enroll enrolls a user in a subject for a term, by default the current one
+func (s *EnrollmentService) Enroll(userID, subjectID uuid.UUID, termID *uuid.UUID) (*models.Enrollment, error) {
+	var subject models.Subject
+	if err := s.db.First(&subject, "id = ?", subjectID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSubjectNotFound
+		}
+		return nil, err
+	}
+	term, err := s.resolveTerm(termID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The unique index on user, subject and term turns a repeated or
+	// concurrent enrollment into a no-op
+	enrollment := models.Enrollment{UserID: userID, SubjectID: subjectID, TermID: term.ID}
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&enrollment)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrAlreadyEnrolled
+	}
+	enrollment.Subject = &subject
+	enrollment.Term = term
+	return &enrollment, nil
+}
+
+// Unenroll removes a user's enrollment in a subject for a term, or in every
+// term when termID is nil
+func (s *EnrollmentService) Unenroll(userID, subjectID uuid.UUID, termID *uuid.UUID) error {
+	query := s.db.Where("user_id = ? AND subject_id = ?", userID, subjectID)
+	if termID != nil {
+		query = query.Where("term_id = ?", *termID)
+	}
+	result := query.Delete(&models.Enrollment{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrEnrollmentNotFound
+	}
+	return nil
+}
+
+// enrollments lists a user's enrollments, newest term first
+func (s *EnrollmentService) enrollments(userID uuid.UUID, scope func(*gorm.DB) *gorm.DB) ([]models.Enrollment, error) {
+	enrollments := []models.Enrollment{}
+	query := s.db.Preload("Subject.Semester").Preload("Term.AcademicYear").
+		Joins("JOIN terms ON terms.id = enrollments.term_id").
+		Joins("JOIN academic_years ON academic_years.id = terms.academic_year_id").
+		Joins("JOIN subjects ON subjects.id = enrollments.subject_id").
+		Where("enrollments.user_id = ?", userID)
+	if scope != nil {
+		query = scope(query)
+	}
+	err := query.Order("academic_years.start_year DESC, terms.kind ASC, subjects.code ASC").
+		Find(&enrollments).Error
+	return enrollments, err
+}
+
+// MySubjects lists the subjects a user is enrolled in, optionally only in an
+// academic year or kind of term
+func (s *EnrollmentService) MySubjects(userID uuid.UUID, filter TermFilter) ([]models.Enrollment, error) {
+	return s.enrollments(userID, func(q *gorm.DB) *gorm.DB {
+		if filter.AcademicYearID != nil {
+			q = q.Where("terms.academic_year_id = ?", *filter.AcademicYearID)
+		}
+		if filter.Kind != "" {
+			q = q.Where("terms.kind = ?", filter.Kind)
+		}
+		return q
+	})
+}
+
+// Dashboard returns the enrolled subjects of a user in a term with their
+// latest documents, unanswered questions and upcoming exams. Without a term
+// the current one is used, and when no term is running, all enrollments.
+func (s *EnrollmentService) Dashboard(userID uuid.UUID, termID *uuid.UUID) (*Dashboard, error) {
+	dashboard := &Dashboard{
+		LatestDocuments:     []DashboardDocument{},
+		UnansweredQuestions: []DashboardQuestion{},
+		UpcomingExams:       []DashboardExamDate{},
+	}
+
+	term, err := s.resolveTerm(termID)
+	if err != nil && !(termID == nil && errors.Is(err, ErrNoCurrentTerm)) {
+		return nil, err
+	}
+	dashboard.Term = term
+
+	dashboard.Enrollments, err = s.enrollments(userID, func(q *gorm.DB) *gorm.DB {
+		if term != nil {
+			q = q.Where("enrollments.term_id = ?", term.ID)
+		}
+		return q
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(dashboard.Enrollments) == 0 {
+		return dashboard, nil
+	}
+
+	seen := make(map[uuid.UUID]bool, len(dashboard.Enrollments))
+	var subjectIDs []uuid.UUID
+	for _, enrollment := range dashboard.Enrollments {
+		if !seen[enrollment.SubjectID] {
+			seen[enrollment.SubjectID] = true
+			subjectIDs = append(subjectIDs, enrollment.SubjectID)
+		}
+	}
+
+	if err := s.db.Table("documents d").
+		Select("d.id, d.subject_id, s.code AS subject_code, d.original_name, d.type, d.mime_type, d.created_at").
+		Joins("JOIN subjects s ON s.id = d.subject_id").
+		Where("d.subject_id IN ? AND d.deleted_at IS NULL AND d.answer_id IS NULL", subjectIDs).
+		Order("d.created_at DESC").
+		Limit(dashboardLimit).
+		Scan(&dashboard.LatestDocuments).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Table("questions q").
+		Select("q.id, q.subject_id, s.code AS subject_code, q.content, q.created_at").
+		Joins("JOIN subjects s ON s.id = q.subject_id").
+		Where("q.subject_id IN ?", subjectIDs).
+		Where("NOT EXISTS (SELECT 1 FROM answers a WHERE a.question_id = q.id)").
+		Order("q.created_at DESC").
+		Limit(dashboardLimit).
+		Scan(&dashboard.UnansweredQuestions).Error; err != nil {
+		return nil, err
+	}
+
+	exams := s.db.Table("exam_dates e").
+		Select("e.id, o.subject_id, s.code AS subject_code, s.name_cs AS subject_name, e.starts_at, e.location, e.note").
+		Joins("JOIN subject_offerings o ON o.id = e.offering_id").
+		Joins("JOIN subjects s ON s.id = o.subject_id").
+		Where("o.subject_id IN ? AND e.starts_at >= ?", subjectIDs, time.Now())
+	if term != nil {
+		exams = exams.Where("o.term_id = ?", term.ID)
+	}
+	if err := exams.Order("e.starts_at").
+		Limit(dashboardLimit).
+		Scan(&dashboard.UpcomingExams).Error; err != nil {
+		return nil, err
+	}
+
+	return dashboard, nil
+}
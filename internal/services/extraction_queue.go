@@ -0,0 +1,367 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/P3chys/entoo2-api/internal/config"
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	extractionPollInterval = 5 * time.Second
+	extractionBaseBackoff  = 30 * time.Second
+	extractionMaxBackoff   = time.Hour
+)
+
+// ExtractionQueue is a persistent job queue that extracts document text in the background
+type ExtractionQueue struct {
+	db          *gorm.DB
+	storage     *StorageService
+	extractor   TextExtractor
+	outbox      *SearchOutbox
+	workers     int
+	maxAttempts int
+	timeout     time.Duration
+	wake        chan struct{}
+}
+
+func NewExtractionQueue(db *gorm.DB, cfg *config.Config, storage *StorageService, extractor TextExtractor, outbox *SearchOutbox) *ExtractionQueue {
+	timeout, err := time.ParseDuration(cfg.TikaTimeout)
+	if err != nil || timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+
+	workers := cfg.ExtractionWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	maxAttempts := cfg.ExtractionMaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+
+	return &ExtractionQueue{
+		db:          db,
+		storage:     storage,
+		extractor:   extractor,
+		outbox:      outbox,
+		workers:     workers,
+		maxAttempts: maxAttempts,
+		timeout:     timeout,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Enqueue schedules text extraction for a document. It is meant to run in the
+// same transaction that creates the document. Documents whose type cannot be
+// extracted are marked as unsupported instead.
+func (q *ExtractionQueue) Enqueue(tx *gorm.DB, document *models.Document) error {
+	if !IsTextExtractable(document.MimeType) {
+		document.ExtractionStatus = models.ExtractionStatusUnsupported
+		return tx.Model(&models.Document{}).Where("id = ?", document.ID).
+			Update("extraction_status", models.ExtractionStatusUnsupported).Error
+	}
+
+	now := time.Now()
+	document.ExtractionStatus = models.ExtractionStatusPending
+	job := models.ExtractionJob{
+		DocumentID: document.ID,
+		Status:     models.ExtractionJobQueued,
+		NextRunAt:  now,
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "document_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"status":      models.ExtractionJobQueued,
+			"attempts":    0,
+			"next_run_at": now,
+			"locked_at":   nil,
+			"last_error":  "",
+			"updated_at":  now,
+		}),
+	}).Create(&job).Error
+}
+
+// Notify wakes up an idle worker, e.g. after a transaction with Enqueue committed
+func (q *ExtractionQueue) Notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start launches the worker pool. Workers stop when ctx is cancelled.
+func (q *ExtractionQueue) Start(ctx context.Context) {
+	if queued, err := q.enqueueMissing(); err != nil {
+		log.Printf("Failed to enqueue pending documents for extraction: %v", err)
+	} else if queued > 0 {
+		log.Printf("Queued %d pending documents for text extraction", queued)
+	}
+
+	for i := 0; i < q.workers; i++ {
+		go q.worker(ctx)
+	}
+}
+
+// enqueueMissing creates jobs for pending documents that have none, e.g. rows
+// written by the importer or created before the queue existed
+func (q *ExtractionQueue) enqueueMissing() (int64, error) {
+	result := q.db.Exec(`
+		INSERT INTO extraction_jobs (id, document_id, status, attempts, next_run_at, created_at, updated_at)
+		SELECT gen_random_uuid(), d.id, ?, 0, NOW(), NOW(), NOW()
+		FROM documents d
+		WHERE d.extraction_status = ? AND d.deleted_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM extraction_jobs j WHERE j.document_id = d.id)
+	`, models.ExtractionJobQueued, models.ExtractionStatusPending)
+	return result.RowsAffected, result.Error
+}
+
+func (q *ExtractionQueue) worker(ctx context.Context) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		processed, err := q.processNext(ctx)
+		if err != nil {
+			log.Printf("Extraction worker error: %v", err)
+		}
+		if processed {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-time.After(extractionPollInterval):
+		}
+	}
+}
+
+// claimNext locks the next due job. Running jobs whose lock is older than
+// twice the timeout are assumed to belong to a crashed worker and are retaken.
+func (q *ExtractionQueue) claimNext() (*models.ExtractionJob, error) {
+	now := time.Now()
+	var job models.ExtractionJob
+	err := q.db.Raw(`
+		UPDATE extraction_jobs SET status = ?, attempts = attempts + 1, locked_at = ?, updated_at = ?
+		WHERE id = (
+			SELECT id FROM extraction_jobs
+			WHERE (status = ? AND next_run_at <= ?) OR (status = ? AND locked_at < ?)
+			ORDER BY next_run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *
+	`, models.ExtractionJobRunning, now, now,
+		models.ExtractionJobQueued, now, models.ExtractionJobRunning, now.Add(-2*q.timeout)).
+		Scan(&job).Error
+	if err != nil {
+		return nil, err
+	}
+	if job.ID == uuid.Nil {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+func (q *ExtractionQueue) processNext(ctx context.Context) (bool, error) {
+	job, err := q.claimNext()
+	if err != nil || job == nil {
+		return false, err
+	}
+
+	var document models.Document
+	if err := q.db.Unscoped().First(&document, "id = ?", job.DocumentID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return true, q.finishJob(job, models.ExtractionJobDone, "document no longer exists")
+		}
+		return true, q.requeue(job, err)
+	}
+
+	if !IsTextExtractable(document.MimeType) {
+		return true, q.complete(job, &document, models.ExtractionStatusUnsupported, "", ErrUnsupportedFormat.Error())
+	}
+
+	text, err := q.extract(ctx, document)
+	switch {
+	case err == nil:
+		return true, q.complete(job, &document, models.ExtractionStatusDone, text, "")
+	case errors.Is(err, ErrUnsupportedFormat):
+		return true, q.complete(job, &document, models.ExtractionStatusUnsupported, "", err.Error())
+	case ctx.Err() != nil:
+		// Shutting down: put the job back without counting the attempt
+		return true, q.db.Model(&models.ExtractionJob{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+			"status":      models.ExtractionJobQueued,
+			"attempts":    gorm.Expr("attempts - 1"),
+			"next_run_at": time.Now(),
+			"locked_at":   nil,
+		}).Error
+	case job.Attempts >= q.maxAttempts:
+		if err := q.db.Model(&models.Document{}).Unscoped().Where("id = ?", document.ID).
+			Update("extraction_status", models.ExtractionStatusFailed).Error; err != nil {
+			return true, err
+		}
+		return true, q.finishJob(job, models.ExtractionJobFailed, err.Error())
+	default:
+		return true, q.requeue(job, err)
+	}
+}
+
+func (q *ExtractionQueue) extract(ctx context.Context, document models.Document) (string, error) {
+	if q.storage == nil {
+		return "", errors.New("storage service unavailable")
+	}
+
+	obj, err := q.storage.DownloadFile(document.MinIOPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to download file: %w", err)
+	}
+	defer obj.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, q.timeout)
+	defer cancel()
+
+	text, err := q.extractor.ExtractTextContext(ctx, obj, document.MimeType)
+	if err != nil {
+		return "", err
+	}
+
+	// PostgreSQL text columns cannot store NUL bytes
+	return strings.ReplaceAll(text, "\x00", ""), nil
+}
+
+// complete stores the extraction result and its pages and schedules re-indexing of the document
+func (q *ExtractionQueue) complete(job *models.ExtractionJob, document *models.Document, status models.ExtractionStatus, text, message string) error {
+	updates := map[string]interface{}{"extraction_status": status}
+	if status == models.ExtractionStatusDone {
+		updates["content_text"] = text
+	}
+
+	reindex := status == models.ExtractionStatusDone && !document.DeletedAt.Valid && q.outbox != nil
+	err := q.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Document{}).Unscoped().Where("id = ?", document.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		if status == models.ExtractionStatusDone {
+			if err := ReplaceDocumentPages(tx, document.ID, text); err != nil {
+				return err
+			}
+		}
+		if !reindex {
+			return nil
+		}
+		return q.outbox.Enqueue(tx, models.SearchEntityDocument, document.ID, models.SearchOperationIndex)
+	})
+	if err != nil {
+		return err
+	}
+	if reindex {
+		q.outbox.Notify()
+	}
+
+	return q.finishJob(job, models.ExtractionJobDone, message)
+}
+
+func (q *ExtractionQueue) finishJob(job *models.ExtractionJob, status models.ExtractionJobStatus, message string) error {
+	return q.db.Model(&models.ExtractionJob{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+		"status":     status,
+		"locked_at":  nil,
+		"last_error": message,
+	}).Error
+}
+
+// requeue schedules another attempt with exponential backoff
+func (q *ExtractionQueue) requeue(job *models.ExtractionJob, cause error) error {
+	backoff := extractionBaseBackoff << uint(job.Attempts-1)
+	if backoff <= 0 || backoff > extractionMaxBackoff {
+		backoff = extractionMaxBackoff
+	}
+
+	return q.db.Model(&models.ExtractionJob{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+		"status":      models.ExtractionJobQueued,
+		"next_run_at": time.Now().Add(backoff),
+		"locked_at":   nil,
+		"last_error":  cause.Error(),
+	}).Error
+}
+
+// ListJobs returns extraction jobs, optionally filtered by status
+func (q *ExtractionQueue) ListJobs(status string, limit, offset int) ([]models.ExtractionJob, int64, error) {
+	query := q.db.Model(&models.ExtractionJob{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var jobs []models.ExtractionJob
+	err := query.Preload("Document", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped().Select("id", "subject_id", "original_name", "mime_type", "file_size", "extraction_status", "deleted_at")
+	}).Order("updated_at DESC").Limit(limit).Offset(offset).Find(&jobs).Error
+	return jobs, total, err
+}
+
+// RetryJob resets a finished or failed job so it runs again
+func (q *ExtractionQueue) RetryJob(jobID uuid.UUID) error {
+	var job models.ExtractionJob
+	if err := q.db.First(&job, "id = ?", jobID).Error; err != nil {
+		return err
+	}
+	if job.Status == models.ExtractionJobRunning {
+		return errors.New("job is currently running")
+	}
+
+	err := q.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&job).Updates(map[string]interface{}{
+			"status":      models.ExtractionJobQueued,
+			"attempts":    0,
+			"next_run_at": time.Now(),
+			"last_error":  "",
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Document{}).Where("id = ?", job.DocumentID).
+			Update("extraction_status", models.ExtractionStatusPending).Error
+	})
+	if err == nil {
+		q.Notify()
+	}
+	return err
+}
+
+// RetryFailed re-queues all failed jobs and returns how many were reset
+func (q *ExtractionQueue) RetryFailed() (int64, error) {
+	var affected int64
+	err := q.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Document{}).
+			Where("id IN (?)", tx.Model(&models.ExtractionJob{}).Select("document_id").Where("status = ?", models.ExtractionJobFailed)).
+			Update("extraction_status", models.ExtractionStatusPending).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&models.ExtractionJob{}).Where("status = ?", models.ExtractionJobFailed).Updates(map[string]interface{}{
+			"status":      models.ExtractionJobQueued,
+			"attempts":    0,
+			"next_run_at": time.Now(),
+			"last_error":  "",
+		})
+		affected = result.RowsAffected
+		return result.Error
+	})
+	if err == nil && affected > 0 {
+		q.Notify()
+	}
+	return affected, err
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrJobLocked is returned when another job holds one of the requested locks
+	ErrJobLocked = errors.New("locked by another running job")
+
+	errJobInterrupted = errors.New("interrupted by a server restart")
+)
+
+type JobService struct {
+	db *gorm.DB
+}
+
+func NewJobService(db *gorm.DB) *JobService {
+	return &JobService{
+		db: db,
+	}
+}
+
+func (s *JobService) CreateJob(jobType models.JobType, createdBy uuid.UUID, subjectID *uuid.UUID) (*models.Job, error) {
+	job := models.Job{
+		Type:      jobType,
+		Status:    models.JobStatusPending,
+		CreatedBy: createdBy,
+		SubjectID: subjectID,
+		Result:    models.JobResult("{}"),
+	}
+
+	if err := s.db.Create(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *JobService) GetJob(jobID uuid.UUID) (*models.Job, error) {
+	var job models.Job
+	if err := s.db.First(&job, "id = ?", jobID).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// StartJob marks a job as running and records how many items it will process
+func (s *JobService) StartJob(jobID uuid.UUID, total int) error {
+	return s.db.Model(&models.Job{}).Where("id = ?", jobID).Updates(map[string]interface{}{
+		"status": models.JobStatusRunning,
+		"total":  total,
+	}).Error
+}
+
+// UpdateProgress stores the current processed/failed counters of a running job
+func (s *JobService) UpdateProgress(jobID uuid.UUID, processed, failed int) error {
+	return s.db.Model(&models.Job{}).Where("id = ?", jobID).Updates(map[string]interface{}{
+		"processed": processed,
+		"failed":    failed,
+	}).Error
+}
+
+// CompleteJob marks a job as finished and stores its result as JSON
+func (s *JobService) CompleteJob(jobID uuid.UUID, result interface{}) error {
+	return s.finishJob(jobID, models.JobStatusCompleted, result, "")
+}
+
+// FailJob marks a job as failed with the given error message
+func (s *JobService) FailJob(jobID uuid.UUID, result interface{}, jobErr error) error {
+	return s.finishJob(jobID, models.JobStatusFailed, result, jobErr.Error())
+}
+
+func (s *JobService) finishJob(jobID uuid.UUID, status models.JobStatus, result interface{}, errMsg string) error {
+	resultJSON := models.JobResult("{}")
+	if result != nil {
+		bytes, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		resultJSON = models.JobResult(bytes)
+	}
+
+	now := time.Now()
+	return s.db.Model(&models.Job{}).Where("id = ?", jobID).Updates(map[string]interface{}{
+		"status":      status,
+		"result":      resultJSON,
+		"error":       errMsg,
+		"finished_at": &now,
+	}).Error
+}
+
+// advisoryKey derives the advisory lock key of a job or of a resource a job
+// works on from its ID
+func advisoryKey(id uuid.UUID) int64 {
+	return int64(binary.BigEndian.Uint64(id[:8]))
+}
+
+// JobLock is a database session holding session-level advisory locks while a
+// background job runs. Postgres releases them when the job ends or when the
+// process holding the session dies.
+type JobLock struct {
+	conn *sql.Conn
+}
+
+// TryLock takes the advisory locks of the given IDs on a dedicated session.
+// It fails with ErrJobLocked, holding nothing, when any of them is taken.
+func (s *JobService) TryLock(ids ...uuid.UUID) (*JobLock, error) {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sqlDB.Conn(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	lock := &JobLock{conn: conn}
+	if err := lock.add(ids...); err != nil {
+		lock.Release()
+		return nil, err
+	}
+	return lock, nil
+}
+
+func (l *JobLock) add(ids ...uuid.UUID) error {
+	for _, id := range ids {
+		var locked bool
+		if err := l.conn.QueryRowContext(context.Background(), "SELECT pg_try_advisory_lock($1)", advisoryKey(id)).Scan(&locked); err != nil {
+			return err
+		}
+		if !locked {
+			return ErrJobLocked
+		}
+	}
+	return nil
+}
+
+// Release drops the locks and returns the session to the pool
+func (l *JobLock) Release() {
+	_, _ = l.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock_all()")
+	_ = l.conn.Close()
+}
+
+// Run runs a pending job in the background while holding its advisory lock,
+// along with lock when given, so FailInterruptedJobs can tell it is alive. A
+// panic fails the job instead of leaving it running. cleanup, when given, is
+// called once whether run ran, was skipped or could not be started.
+func (s *JobService) Run(job *models.Job, lock *JobLock, run func(), cleanup func()) error {
+	if cleanup == nil {
+		cleanup = func() {}
+	}
+	if lock == nil {
+		var err error
+		if lock, err = s.TryLock(job.ID); err != nil {
+			cleanup()
+			return err
+		}
+	} else if err := lock.add(job.ID); err != nil {
+		lock.Release()
+		cleanup()
+		return err
+	}
+
+	go func() {
+		defer lock.Release()
+		defer cleanup()
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("Job %s panicked: %v", job.ID, r)
+				if err := s.FailJob(job.ID, nil, fmt.Errorf("job crashed: %v", r)); err != nil {
+					log.Printf("Failed to fail job %s: %v", job.ID, err)
+				}
+			}
+		}()
+
+		// A restart on another instance may have failed the job before it was locked
+		current, err := s.GetJob(job.ID)
+		if err != nil {
+			log.Printf("Not running job %s: %v", job.ID, err)
+			if err := s.FailJob(job.ID, nil, fmt.Errorf("failed to load job: %w", err)); err != nil {
+				log.Printf("Failed to fail job %s: %v", job.ID, err)
+			}
+			return
+		}
+		if current.Status != models.JobStatusPending {
+			log.Printf("Not running job %s: it is no longer pending", job.ID)
+			return
+		}
+		run()
+	}()
+	return nil
+}
+
+// FailInterruptedJobs marks pending and running jobs whose advisory lock is
+// free as failed: the process that ran them is gone. It is meant to run at
+// startup and returns how many jobs it failed.
+func (s *JobService) FailInterruptedJobs() (int, error) {
+	var jobs []models.Job
+	if err := s.db.Where("status IN ?", []models.JobStatus{models.JobStatusPending, models.JobStatusRunning}).
+		Find(&jobs).Error; err != nil {
+		return 0, err
+	}
+
+	failed := 0
+	for _, job := range jobs {
+		lock, err := s.TryLock(job.ID)
+		if errors.Is(err, ErrJobLocked) {
+			continue
+		}
+		if err != nil {
+			return failed, err
+		}
+		err = s.FailJob(job.ID, nil, errJobInterrupted)
+		lock.Release()
+		if err != nil {
+			return failed, err
+		}
+		failed++
+	}
+	return failed, nil
+}
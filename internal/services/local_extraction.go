@@ -0,0 +1,682 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+const (
+	maxLocalExtractionSize = 100 * 1024 * 1024 // largest input the local extractor buffers
+	maxArchivePartSize     = 64 * 1024 * 1024  // largest decompressed part read from OOXML/PDF
+)
+
+// LocalTextExtractor is a pure-Go extractor for the formats uploaded most
+// often: plain text/CSV, DOCX, PPTX, XLSX and simple PDF text streams. It is
+// used when Tika is not reachable.
+type LocalTextExtractor struct{}
+
+func NewLocalTextExtractor() *LocalTextExtractor {
+	return &LocalTextExtractor{}
+}
+
+func (e *LocalTextExtractor) ExtractTextContext(ctx context.Context, reader io.Reader, mimeType string) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(reader, maxLocalExtractionSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxLocalExtractionSize {
+		return "", errors.New("file too large for local text extraction")
+	}
+
+	var text string
+	switch mimeType {
+	case "application/vnd.openxmlformats-officedocument.wordprocessingml.document":
+		text, err = extractDOCX(data)
+	case "application/vnd.openxmlformats-officedocument.presentationml.presentation":
+		text, err = extractPPTX(data)
+	case "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":
+		text, err = extractXLSX(data)
+	case "application/pdf":
+		text, err = extractPDF(ctx, data)
+	case "application/vnd.ms-excel":
+		// Binary XLS is not supported, but CSV files are often sent with this type
+		if !looksLikeText(data) {
+			return "", ErrUnsupportedFormat
+		}
+		text = decodePlainText(data)
+	default:
+		if !strings.HasPrefix(mimeType, "text/") && mimeType != "application/csv" {
+			return "", ErrUnsupportedFormat
+		}
+		text = decodePlainText(data)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(text), nil
+}
+
+// decodePlainText returns data as UTF-8. Files that are not valid UTF-8 are
+// assumed to be Windows-1250, the usual legacy encoding of Czech text files.
+func decodePlainText(data []byte) string {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	decoded, err := charmap.Windows1250.NewDecoder().Bytes(data)
+	if err != nil {
+		return strings.ToValidUTF8(string(data), "")
+	}
+	return string(decoded)
+}
+
+// looksLikeText reports whether the first kilobytes contain no binary control bytes
+func looksLikeText(data []byte) bool {
+	sample := data
+	if len(sample) > 8192 {
+		sample = sample[:8192]
+	}
+	for _, b := range sample {
+		if b == 0 || (b < 0x20 && b != '\n' && b != '\r' && b != '\t') {
+			return false
+		}
+	}
+	return true
+}
+
+// OOXML (DOCX, PPTX, XLSX)
+
+func openOOXML(data []byte) (*zip.Reader, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid office document: %w", err)
+	}
+	return zr, nil
+}
+
+func readZipPart(zr *zip.Reader, name string) ([]byte, error) {
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(io.LimitReader(rc, maxArchivePartSize))
+	}
+	return nil, fmt.Errorf("missing part %s", name)
+}
+
+// numberedParts returns archive entries like prefix1.xml, prefix2.xml in numeric order
+func numberedParts(zr *zip.Reader, prefix string) []string {
+	type part struct {
+		name  string
+		index int
+	}
+	var parts []part
+	for _, f := range zr.File {
+		if !strings.HasPrefix(f.Name, prefix) || path.Ext(f.Name) != ".xml" {
+			continue
+		}
+		number := strings.TrimSuffix(strings.TrimPrefix(f.Name, prefix), ".xml")
+		index, err := strconv.Atoi(number)
+		if err != nil {
+			continue
+		}
+		parts = append(parts, part{name: f.Name, index: index})
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].index < parts[j].index })
+
+	names := make([]string, len(parts))
+	for i, p := range parts {
+		names[i] = p.name
+	}
+	return names
+}
+
+// xmlText collects character data of textElement elements, inserting a
+// newline after each paragraphElement and a tab for tab elements
+func xmlText(data []byte, textElement, paragraphElement string) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var b strings.Builder
+	inText := false
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return b.String(), err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case textElement:
+				inText = true
+			case "tab":
+				b.WriteByte('\t')
+			case "br", "cr":
+				b.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case textElement:
+				inText = false
+			case paragraphElement:
+				b.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				b.Write(t)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+func extractDOCX(data []byte) (string, error) {
+	zr, err := openOOXML(data)
+	if err != nil {
+		return "", err
+	}
+	part, err := readZipPart(zr, "word/document.xml")
+	if err != nil {
+		return "", err
+	}
+	return xmlText(part, "t", "p")
+}
+
+func extractPPTX(data []byte) (string, error) {
+	zr, err := openOOXML(data)
+	if err != nil {
+		return "", err
+	}
+
+	var slides []string
+	for _, name := range numberedParts(zr, "ppt/slides/slide") {
+		part, err := readZipPart(zr, name)
+		if err != nil {
+			return "", err
+		}
+		text, err := xmlText(part, "t", "p")
+		if err != nil {
+			return "", err
+		}
+		slides = append(slides, strings.TrimSpace(text))
+	}
+	return strings.Join(slides, "\n"+pageBreak+"\n"), nil
+}
+
+func extractXLSX(data []byte) (string, error) {
+	zr, err := openOOXML(data)
+	if err != nil {
+		return "", err
+	}
+
+	// Shared strings are optional; workbooks with only numbers do not have them
+	var sharedStrings []string
+	if part, err := readZipPart(zr, "xl/sharedStrings.xml"); err == nil {
+		sharedStrings, err = parseSharedStrings(part)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	var sheets []string
+	for _, name := range numberedParts(zr, "xl/worksheets/sheet") {
+		part, err := readZipPart(zr, name)
+		if err != nil {
+			return "", err
+		}
+		text, err := parseWorksheet(part, sharedStrings)
+		if err != nil {
+			return "", err
+		}
+		sheets = append(sheets, strings.TrimSpace(text))
+	}
+	return strings.Join(sheets, "\n\n"), nil
+}
+
+func parseSharedStrings(data []byte) ([]string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var result []string
+	var current strings.Builder
+	inItem, inText := false, false
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				inItem = true
+				current.Reset()
+			case "t":
+				inText = inItem
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "si":
+				inItem = false
+				result = append(result, current.String())
+			case "t":
+				inText = false
+			}
+		case xml.CharData:
+			if inText {
+				current.Write(t)
+			}
+		}
+	}
+	return result, nil
+}
+
+// parseWorksheet renders a sheet as tab-separated rows
+func parseWorksheet(data []byte, sharedStrings []string) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var b strings.Builder
+	var row []string
+	var cellType string
+	var value strings.Builder
+	inValue := false
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return b.String(), err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "row":
+				row = row[:0]
+			case "c":
+				cellType = ""
+				value.Reset()
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "t" {
+						cellType = attr.Value
+					}
+				}
+			case "v", "t":
+				inValue = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "v", "t":
+				inValue = false
+			case "c":
+				cell := value.String()
+				if cellType == "s" {
+					if index, err := strconv.Atoi(cell); err == nil && index >= 0 && index < len(sharedStrings) {
+						cell = sharedStrings[index]
+					}
+				}
+				row = append(row, cell)
+			case "row":
+				line := strings.TrimRight(strings.Join(row, "\t"), "\t")
+				if line != "" {
+					b.WriteString(line)
+					b.WriteByte('\n')
+				}
+			}
+		case xml.CharData:
+			if inValue {
+				value.Write(t)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// PDF
+
+var (
+	pdfStreamKeyword    = []byte("stream")
+	pdfEndStreamKeyword = []byte("endstream")
+)
+
+// extractPDF reads text-showing operators from the content streams of a PDF.
+// Only simple fonts are handled; text drawn with CID fonts or stored in
+// encrypted files is not recovered.
+func extractPDF(ctx context.Context, data []byte) (string, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF")) {
+		return "", errors.New("invalid PDF file")
+	}
+
+	var b strings.Builder
+	offset := 0
+	for {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+
+		idx := bytes.Index(data[offset:], pdfStreamKeyword)
+		if idx < 0 {
+			break
+		}
+		start := offset + idx
+		offset = start + len(pdfStreamKeyword)
+
+		// Skip "endstream" matches and keywords that are not followed by EOL
+		if start >= 3 && bytes.Equal(data[start-3:start], []byte("end")) {
+			continue
+		}
+		bodyStart := offset
+		if bodyStart < len(data) && data[bodyStart] == '\r' {
+			bodyStart++
+		}
+		if bodyStart >= len(data) || data[bodyStart] != '\n' {
+			continue
+		}
+		bodyStart++
+
+		end := bytes.Index(data[bodyStart:], pdfEndStreamKeyword)
+		if end < 0 {
+			break
+		}
+		body := data[bodyStart : bodyStart+end]
+		offset = bodyStart + end + len(pdfEndStreamKeyword)
+
+		dict := pdfStreamDictionary(data, start)
+		if !isPDFContentStream(dict) {
+			continue
+		}
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			inflated, ok := inflate(body)
+			if !ok {
+				continue
+			}
+			body = inflated
+		}
+
+		text := parsePDFContent(body)
+		if strings.TrimSpace(text) != "" {
+			b.WriteString(text)
+			b.WriteString("\n\f")
+		}
+	}
+
+	return normalizePDFText(b.String()), nil
+}
+
+// pdfStreamDictionary returns the dictionary that precedes a stream keyword
+func pdfStreamDictionary(data []byte, streamStart int) []byte {
+	from := streamStart - 2048
+	if from < 0 {
+		from = 0
+	}
+	window := data[from:streamStart]
+	if objStart := bytes.LastIndex(window, []byte(" obj")); objStart >= 0 {
+		window = window[objStart:]
+	}
+	return window
+}
+
+func isPDFContentStream(dict []byte) bool {
+	for _, marker := range [][]byte{
+		[]byte("/Image"), []byte("/FontFile"), []byte("/Length1"), []byte("/XRef"),
+		[]byte("/ObjStm"), []byte("/Metadata"), []byte("/EmbeddedFile"), []byte("/ICCBased"),
+		[]byte("/DCTDecode"), []byte("/JPXDecode"), []byte("/CCITTFaxDecode"), []byte("/JBIG2Decode"),
+	} {
+		if bytes.Contains(dict, marker) {
+			return false
+		}
+	}
+	return true
+}
+
+func inflate(body []byte) ([]byte, bool) {
+	zr, err := zlib.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, false
+	}
+	defer zr.Close()
+	// Truncated streams still yield useful text, so partial output is kept
+	out, _ := io.ReadAll(io.LimitReader(zr, maxArchivePartSize))
+	return out, len(out) > 0
+}
+
+// parsePDFContent interprets the text operators of a content stream
+func parsePDFContent(content []byte) string {
+	var b strings.Builder
+	var pending []string // strings waiting for their operator
+	var numbers []float64
+	inArray := false
+
+	flush := func() {
+		for _, s := range pending {
+			b.WriteString(s)
+		}
+		pending = pending[:0]
+	}
+
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			s, next := readPDFLiteralString(content, i)
+			pending = append(pending, decodePDFString(s))
+			i = next
+		case c == '<' && i+1 < len(content) && content[i+1] != '<':
+			s, next := readPDFHexString(content, i)
+			pending = append(pending, decodePDFString(s))
+			i = next
+		case c == '[':
+			inArray = true
+			pending = pending[:0]
+			i++
+		case c == ']':
+			inArray = false
+			i++
+		case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(content) && (content[j] == '.' || (content[j] >= '0' && content[j] <= '9')) {
+				j++
+			}
+			if n, err := strconv.ParseFloat(string(content[i:j]), 64); err == nil {
+				// Large negative kerning inside TJ arrays separates words
+				if inArray && n < -200 {
+					pending = append(pending, " ")
+				}
+				numbers = append(numbers, n)
+			}
+			i = j
+		case isPDFOperatorByte(c):
+			j := i + 1
+			for j < len(content) && isPDFOperatorByte(content[j]) {
+				j++
+			}
+			switch string(content[i:j]) {
+			case "Tj", "TJ":
+				flush()
+			case "'", "\"":
+				b.WriteByte('\n')
+				flush()
+			case "T*":
+				b.WriteByte('\n')
+			case "Td", "TD":
+				if len(numbers) >= 2 && numbers[len(numbers)-1] != 0 {
+					b.WriteByte('\n')
+				} else {
+					b.WriteByte(' ')
+				}
+			case "Tm":
+				b.WriteByte('\n')
+			case "ET":
+				b.WriteByte('\n')
+			}
+			pending = pending[:0]
+			numbers = numbers[:0]
+			i = j
+		default:
+			i++
+		}
+	}
+	return b.String()
+}
+
+func isPDFOperatorByte(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '*' || c == '\'' || c == '"'
+}
+
+func readPDFLiteralString(content []byte, start int) ([]byte, int) {
+	var out []byte
+	depth := 0
+	i := start
+	for i < len(content) {
+		c := content[i]
+		switch {
+		case c == '\\' && i+1 < len(content):
+			i++
+			e := content[i]
+			switch e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				// Line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					value := 0
+					k := 0
+					for k < 3 && i < len(content) && content[i] >= '0' && content[i] <= '7' {
+						value = value*8 + int(content[i]-'0')
+						i++
+						k++
+					}
+					out = append(out, byte(value))
+					continue
+				}
+				out = append(out, e)
+			}
+			i++
+		case c == '(':
+			if depth > 0 {
+				out = append(out, c)
+			}
+			depth++
+			i++
+		case c == ')':
+			depth--
+			i++
+			if depth == 0 {
+				return out, i
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+			i++
+		}
+	}
+	return out, i
+}
+
+func readPDFHexString(content []byte, start int) ([]byte, int) {
+	end := bytes.IndexByte(content[start:], '>')
+	if end < 0 {
+		return nil, len(content)
+	}
+	hex := content[start+1 : start+end]
+	var digits []byte
+	for _, h := range hex {
+		if (h >= '0' && h <= '9') || (h >= 'a' && h <= 'f') || (h >= 'A' && h <= 'F') {
+			digits = append(digits, h)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		v, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		out[i] = byte(v)
+	}
+	return out, start + end + 1
+}
+
+// decodePDFString decodes UTF-16BE strings (with BOM) and treats everything
+// else as WinAnsi; glyph IDs of CID fonts come out as control bytes and are dropped
+func decodePDFString(s []byte) string {
+	if len(s) >= 2 && s[0] == 0xfe && s[1] == 0xff {
+		units := make([]uint16, 0, len(s)/2)
+		for i := 2; i+1 < len(s); i += 2 {
+			units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+
+	decoded, err := charmap.Windows1252.NewDecoder().Bytes(s)
+	if err != nil {
+		return ""
+	}
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\n' && r != '\t' {
+			return -1
+		}
+		return r
+	}, string(decoded))
+}
+
+// normalizePDFText collapses runs of spaces and blank lines
+func normalizePDFText(text string) string {
+	lines := strings.Split(text, "\n")
+	out := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		if line == "\f" {
+			out = append(out, line)
+			blank = false
+			continue
+		}
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" {
+			if !blank {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
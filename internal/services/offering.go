@@ -0,0 +1,466 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrAcademicYearNotFound is returned for an academic year ID that does not exist
+	ErrAcademicYearNotFound = errors.New("academic year not found")
+	// ErrAcademicYearExists is returned when an academic year starting in the
+	// same calendar year already exists
+	ErrAcademicYearExists = errors.New("academic year already exists")
+	// ErrAcademicYearInUse is returned when deleting a year that still has
+	// offerings or enrollments
+	ErrAcademicYearInUse = errors.New("academic year has subject offerings or enrollments")
+	// ErrTermNotFound is returned for a term ID that does not exist
+	ErrTermNotFound = errors.New("term not found")
+	// ErrSubjectNotFound is returned for a subject ID that does not exist
+	ErrSubjectNotFound = errors.New("subject not found")
+	// ErrOfferingNotFound is returned for an offering ID that does not exist
+	// or does not belong to the expected subject
+	ErrOfferingNotFound = errors.New("subject offering not found")
+	// ErrOfferingExists is returned when a subject is already offered in a term
+	ErrOfferingExists = errors.New("subject is already offered in this term")
+	// ErrExamDateNotFound is returned for an exam date ID that does not exist
+	ErrExamDateNotFound = errors.New("exam date not found")
+	// ErrInvalidTermFilter is returned for a malformed year or term filter
+	ErrInvalidTermFilter = errors.New("invalid academic year or term")
+)
+
+// AcademicYearName returns the conventional name of the academic year
+// starting in startYear, e.g. "2024/2025"
+func AcademicYearName(startYear int) string {
+	return fmt.Sprintf("%d/%d", startYear, startYear+1)
+}
+
+// IsValidTermKind reports whether kind is winter or summer
+func IsValidTermKind(kind models.TermKind) bool {
+	return kind == models.TermWinter || kind == models.TermSummer
+}
+
+// TermFilter restricts lists and searches to the offerings of an academic
+// year, of a term kind in any year, or of one term when both are set. The
+// zero value does not filter.
+type TermFilter struct {
+	AcademicYearID *uuid.UUID
+	Kind           models.TermKind
+}
+
+// ParseTermFilter reads the academic_year_id and term query parameters
+func ParseTermFilter(academicYearID, term string) (TermFilter, error) {
+	var filter TermFilter
+	if academicYearID != "" {
+		id, err := uuid.Parse(academicYearID)
+		if err != nil {
+			return filter, fmt.Errorf("%w: invalid academic_year_id", ErrInvalidTermFilter)
+		}
+		filter.AcademicYearID = &id
+	}
+	if term != "" {
+		filter.Kind = models.TermKind(term)
+		if !IsValidTermKind(filter.Kind) {
+			return filter, fmt.Errorf("%w: term must be winter or summer", ErrInvalidTermFilter)
+		}
+	}
+	return filter, nil
+}
+
+// IsZero reports whether the filter matches everything
+func (f TermFilter) IsZero() bool {
+	return f.AcademicYearID == nil && f.Kind == ""
+}
+
+// OfferingIDs returns a subquery selecting the IDs of the matching offerings
+func (f TermFilter) OfferingIDs(db *gorm.DB) *gorm.DB {
+	return f.offerings(db, "subject_offerings.id")
+}
+
+// SubjectIDs returns a subquery selecting the IDs of the subjects offered in
+// a matching term
+func (f TermFilter) SubjectIDs(db *gorm.DB) *gorm.DB {
+	return f.offerings(db, "subject_offerings.subject_id")
+}
+
+func (f TermFilter) offerings(db *gorm.DB, column string) *gorm.DB {
+	query := db.Table("subject_offerings").
+		Select(column).
+		Joins("JOIN terms ON terms.id = subject_offerings.term_id")
+	if f.AcademicYearID != nil {
+		query = query.Where("terms.academic_year_id = ?", *f.AcademicYearID)
+	}
+	if f.Kind != "" {
+		query = query.Where("terms.kind = ?", f.Kind)
+	}
+	return query
+}
+
+// ValidateDocumentOffering checks that an offering exists and belongs to subjectID
+func ValidateDocumentOffering(db *gorm.DB, subjectID, offeringID uuid.UUID) error {
+	var count int64
+	if err := db.Model(&models.SubjectOffering{}).
+		Where("id = ? AND subject_id = ?", offeringID, subjectID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrOfferingNotFound
+	}
+	return nil
+}
+
+// TermInput holds the optional dates of a term
+type TermInput struct {
+	StartsOn *time.Time
+	EndsOn   *time.Time
+}
+
+type OfferingService struct {
+	db     *gorm.DB
+	outbox *SearchOutbox
+}
+
+func NewOfferingService(db *gorm.DB, outbox *SearchOutbox) *OfferingService {
+	return &OfferingService{db: db, outbox: outbox}
+}
+
+// preloadTerms loads terms with winter before summer
+func preloadTerms(db *gorm.DB) *gorm.DB {
+	return db.Order("kind DESC")
+}
+
+// ListYears returns all academic years, newest first, with their terms
+func (s *OfferingService) ListYears() ([]models.AcademicYear, error) {
+	years := []models.AcademicYear{}
+	err := s.db.Preload("Terms", preloadTerms).Order("start_year DESC").Find(&years).Error
+	return years, err
+}
+
+// GetYear returns an academic year with its terms
+func (s *OfferingService) GetYear(yearID uuid.UUID) (*models.AcademicYear, error) {
+	var year models.AcademicYear
+	if err := s.db.Preload("Terms", preloadTerms).First(&year, "id = ?", yearID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAcademicYearNotFound
+		}
+		return nil, err
+	}
+	return &year, nil
+}
+
+// CreateYear adds the academic year starting in startYear together with its
+// winter and summer terms. terms may give the dates of either term.
+func (s *OfferingService) CreateYear(startYear int, terms map[models.TermKind]TermInput) (*models.AcademicYear, error) {
+	year := models.AcademicYear{Name: AcademicYearName(startYear), StartYear: startYear}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.AcademicYear{}).Where("start_year = ?", startYear).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrAcademicYearExists
+		}
+		if err := tx.Create(&year).Error; err != nil {
+			return err
+		}
+		for _, kind := range []models.TermKind{models.TermWinter, models.TermSummer} {
+			term := models.Term{
+				AcademicYearID: year.ID,
+				Kind:           kind,
+				StartsOn:       terms[kind].StartsOn,
+				EndsOn:         terms[kind].EndsOn,
+			}
+			if err := tx.Create(&term).Error; err != nil {
+				return err
+			}
+			year.Terms = append(year.Terms, term)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &year, nil
+}
+
+// UpdateTerm sets the dates of a term
+func (s *OfferingService) UpdateTerm(termID uuid.UUID, input TermInput) (*models.Term, error) {
+	var term models.Term
+	if err := s.db.First(&term, "id = ?", termID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTermNotFound
+		}
+		return nil, err
+	}
+	term.StartsOn = input.StartsOn
+	term.EndsOn = input.EndsOn
+	if err := s.db.Model(&term).Select("starts_on", "ends_on").Updates(&term).Error; err != nil {
+		return nil, err
+	}
+	return &term, nil
+}
+
+// DeleteYear removes an academic year and its terms. Years with offerings
+// or enrollments are kept, so documents and students never lose their term.
+func (s *OfferingService) DeleteYear(yearID uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var year models.AcademicYear
+		if err := tx.First(&year, "id = ?", yearID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrAcademicYearNotFound
+			}
+			return err
+		}
+		var offerings int64
+		filter := TermFilter{AcademicYearID: &yearID}
+		if err := filter.OfferingIDs(tx).Count(&offerings).Error; err != nil {
+			return err
+		}
+		if offerings > 0 {
+			return ErrAcademicYearInUse
+		}
+		var enrollments int64
+		if err := tx.Model(&models.Enrollment{}).
+			Where("term_id IN (SELECT id FROM terms WHERE academic_year_id = ?)", yearID).
+			Count(&enrollments).Error; err != nil {
+			return err
+		}
+		if enrollments > 0 {
+			return ErrAcademicYearInUse
+		}
+		if err := tx.Where("academic_year_id = ?", yearID).Delete(&models.Term{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&year).Error
+	})
+}
+
+// ListOfferings returns the offerings of a subject, newest term first, with
+// their terms and teachers
+func (s *OfferingService) ListOfferings(subjectID uuid.UUID) ([]models.SubjectOffering, error) {
+	offerings := []models.SubjectOffering{}
+	err := s.db.Preload("Term.AcademicYear").Preload("Teachers.Teacher").Preload("ExamDates", preloadExamDates).
+		Joins("JOIN terms ON terms.id = subject_offerings.term_id").
+		Joins("JOIN academic_years ON academic_years.id = terms.academic_year_id").
+		Where("subject_offerings.subject_id = ?", subjectID).
+		Order("academic_years.start_year DESC, terms.kind ASC").
+		Find(&offerings).Error
+	return offerings, err
+}
+
+// getOffering loads an offering with its term and teachers
+func (s *OfferingService) getOffering(db *gorm.DB, offeringID uuid.UUID) (*models.SubjectOffering, error) {
+	var offering models.SubjectOffering
+	if err := db.Preload("Term.AcademicYear").Preload("Teachers.Teacher").Preload("ExamDates", preloadExamDates).
+		First(&offering, "id = ?", offeringID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOfferingNotFound
+		}
+		return nil, err
+	}
+	return &offering, nil
+}
+
+// CreateOffering offers a subject in a term with the given teachers
+func (s *OfferingService) CreateOffering(subjectID, termID uuid.UUID, note string, teachers []SubjectTeacherInput) (*models.SubjectOffering, error) {
+	var offeringID uuid.UUID
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Subject{}).Where("id = ?", subjectID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrSubjectNotFound
+		}
+		if err := tx.Model(&models.Term{}).Where("id = ?", termID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrTermNotFound
+		}
+		if err := tx.Model(&models.SubjectOffering{}).
+			Where("subject_id = ? AND term_id = ?", subjectID, termID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrOfferingExists
+		}
+
+		offering := models.SubjectOffering{SubjectID: subjectID, TermID: termID, Note: note}
+		if err := tx.Create(&offering).Error; err != nil {
+			return err
+		}
+		offeringID = offering.ID
+		return syncOfferingTeachers(tx, offering.ID, teachers)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.getOffering(s.db, offeringID)
+}
+
+// UpdateOffering changes the note of an offering and, when teachers is not
+// nil, replaces its teachers
+func (s *OfferingService) UpdateOffering(offeringID uuid.UUID, note *string, teachers *[]SubjectTeacherInput) (*models.SubjectOffering, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		offering, err := s.getOffering(tx, offeringID)
+		if err != nil {
+			return err
+		}
+		if note != nil {
+			if err := tx.Model(offering).Update("note", *note).Error; err != nil {
+				return err
+			}
+		}
+		if teachers != nil {
+			return syncOfferingTeachers(tx, offering.ID, *teachers)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.getOffering(s.db, offeringID)
+}
+
+// DeleteOffering removes an offering and its teachers. Its documents stay
+// with the subject without a term and are reindexed.
+func (s *OfferingService) DeleteOffering(offeringID uuid.UUID) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.SubjectOffering{}, "id = ?", offeringID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOfferingNotFound
+		}
+		if err := tx.Where("offering_id = ?", offeringID).Delete(&models.OfferingTeacher{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("offering_id = ?", offeringID).Delete(&models.ExamDate{}).Error; err != nil {
+			return err
+		}
+
+		var documentIDs []uuid.UUID
+		if err := tx.Model(&models.Document{}).Where("offering_id = ?", offeringID).Pluck("id", &documentIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.Document{}).
+			Where("offering_id = ?", offeringID).
+			Update("offering_id", nil).Error; err != nil {
+			return err
+		}
+		for _, docID := range documentIDs {
+			if err := s.outbox.Enqueue(tx, models.SearchEntityDocument, docID, models.SearchOperationIndex); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.outbox.Notify()
+	return nil
+}
+
+func preloadExamDates(db *gorm.DB) *gorm.DB {
+	return db.Order("starts_at")
+}
+
+// ExamDateInput describes an exam of an offering
+type ExamDateInput struct {
+	StartsAt time.Time
+	Location string
+	Note     string
+}
+
+// CreateExamDate adds an exam to an offering
+func (s *OfferingService) CreateExamDate(offeringID uuid.UUID, input ExamDateInput) (*models.ExamDate, error) {
+	var count int64
+	if err := s.db.Model(&models.SubjectOffering{}).Where("id = ?", offeringID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrOfferingNotFound
+	}
+
+	exam := models.ExamDate{
+		OfferingID: offeringID,
+		StartsAt:   input.StartsAt,
+		Location:   input.Location,
+		Note:       input.Note,
+	}
+	if err := s.db.Create(&exam).Error; err != nil {
+		return nil, err
+	}
+	return &exam, nil
+}
+
+// DeleteExamDate removes an exam
+func (s *OfferingService) DeleteExamDate(examID uuid.UUID) error {
+	result := s.db.Delete(&models.ExamDate{}, "id = ?", examID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrExamDateNotFound
+	}
+	return nil
+}
+
+// syncOfferingTeachers replaces the teachers of an offering. Teachers are
+// named by ID or by name like the teachers of a subject.
+func syncOfferingTeachers(tx *gorm.DB, offeringID uuid.UUID, inputs []SubjectTeacherInput) error {
+	if err := tx.Where("offering_id = ?", offeringID).Delete(&models.OfferingTeacher{}).Error; err != nil {
+		return err
+	}
+
+	seen := make(map[uuid.UUID]bool, len(inputs))
+	for _, input := range inputs {
+		var teacher *models.Teacher
+		if input.TeacherID != nil {
+			teacher = &models.Teacher{}
+			if err := tx.First(teacher, "id = ?", *input.TeacherID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return fmt.Errorf("%w: %s", ErrTeacherNotFound, input.TeacherID)
+				}
+				return err
+			}
+		} else {
+			var err error
+			if teacher, err = findOrCreateTeacher(tx, input.Name); err != nil {
+				return err
+			}
+		}
+		if seen[teacher.ID] {
+			return fmt.Errorf("%w: teacher %q is listed twice", ErrInvalidSubjectTeachers, teacher.Name)
+		}
+		seen[teacher.ID] = true
+
+		role := input.Role
+		if role == "" {
+			role = models.TeacherRoleLecturer
+		}
+		if !IsValidTeacherRole(role) {
+			return fmt.Errorf("%w: unknown role %q", ErrInvalidSubjectTeachers, role)
+		}
+		link := models.OfferingTeacher{
+			OfferingID: offeringID,
+			TeacherID:  teacher.ID,
+			Role:       role,
+			TopicCS:    input.TopicCS,
+		}
+		if err := tx.Create(&link).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
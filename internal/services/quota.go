@@ -0,0 +1,268 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/P3chys/entoo2-api/internal/config"
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type QuotaScope string
+
+const (
+	QuotaScopeUser    QuotaScope = "user"
+	QuotaScopeSubject QuotaScope = "subject"
+)
+
+// QuotaExceededError is returned when an upload would exceed a storage quota
+type QuotaExceededError struct {
+	Scope QuotaScope
+	Used  int64
+	Limit int64
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s storage quota exceeded (%d of %d bytes used)", e.Scope, e.Used, e.Limit)
+}
+
+// StorageUsage summarises how much storage an owner uses against its quota
+type StorageUsage struct {
+	UsedBytes      int64  `json:"used_bytes"`
+	QuotaBytes     int64  `json:"quota_bytes"` // 0 means unlimited
+	RemainingBytes *int64 `json:"remaining_bytes,omitempty"`
+	DocumentCount  int64  `json:"document_count"`
+}
+
+type UserStorageRow struct {
+	UserID        uuid.UUID `json:"user_id"`
+	Email         string    `json:"email"`
+	DisplayName   string    `json:"display_name"`
+	UsedBytes     int64     `json:"used_bytes"`
+	DocumentCount int64     `json:"document_count"`
+	QuotaBytes    int64     `json:"quota_bytes"`
+}
+
+type SubjectStorageRow struct {
+	SubjectID     uuid.UUID `json:"subject_id"`
+	Code          string    `json:"code"`
+	NameCS        string    `json:"name_cs"`
+	UsedBytes     int64     `json:"used_bytes"`
+	DocumentCount int64     `json:"document_count"`
+}
+
+type MimeTypeStorageRow struct {
+	MimeType      string `json:"mime_type"`
+	UsedBytes     int64  `json:"used_bytes"`
+	DocumentCount int64  `json:"document_count"`
+}
+
+// StorageReport lists the heaviest storage consumers
+type StorageReport struct {
+	TotalBytes     int64                `json:"total_bytes"`
+	TotalDocuments int64                `json:"total_documents"`
+	Users          []UserStorageRow     `json:"users"`
+	Subjects       []SubjectStorageRow  `json:"subjects"`
+	MimeTypes      []MimeTypeStorageRow `json:"mime_types"`
+}
+
+type QuotaService struct {
+	db                  *gorm.DB
+	defaultUserQuota    int64
+	defaultSubjectQuota int64
+}
+
+func NewQuotaService(db *gorm.DB, cfg *config.Config) *QuotaService {
+	return &QuotaService{
+		db:                  db,
+		defaultUserQuota:    cfg.UserStorageQuota,
+		defaultSubjectQuota: cfg.SubjectStorageQuota,
+	}
+}
+
+// Documents in the trash still occupy the bucket until purged, so usage is
+// computed over all rows including soft-deleted ones. Documents carried over
+// by reference share the file of their source and are not counted.
+func (s *QuotaService) usage(column string, id uuid.UUID) (int64, int64, error) {
+	return usage(s.db, column, id)
+}
+
+func usage(db *gorm.DB, column string, id uuid.UUID) (int64, int64, error) {
+	var result struct {
+		UsedBytes     int64
+		DocumentCount int64
+	}
+	err := db.Unscoped().Model(&models.Document{}).
+		Select("COALESCE(SUM(file_size), 0) as used_bytes, COUNT(*) as document_count").
+		Where(column+" = ? AND source_document_id IS NULL", id).
+		Scan(&result).Error
+	return result.UsedBytes, result.DocumentCount, err
+}
+
+// UserQuota returns the effective quota of a user in bytes (0 means unlimited)
+func (s *QuotaService) UserQuota(userID uuid.UUID) (int64, error) {
+	return s.userQuota(s.db, userID)
+}
+
+func (s *QuotaService) userQuota(db *gorm.DB, userID uuid.UUID) (int64, error) {
+	var user models.User
+	if err := db.Select("id", "storage_quota").First(&user, "id = ?", userID).Error; err != nil {
+		return 0, err
+	}
+	if user.StorageQuota != nil {
+		return *user.StorageQuota, nil
+	}
+	return s.defaultUserQuota, nil
+}
+
+func (s *QuotaService) GetUserUsage(userID uuid.UUID) (*StorageUsage, error) {
+	used, count, err := s.usage("uploaded_by", userID)
+	if err != nil {
+		return nil, err
+	}
+	quota, err := s.UserQuota(userID)
+	if err != nil {
+		return nil, err
+	}
+	return newStorageUsage(used, count, quota), nil
+}
+
+func (s *QuotaService) GetSubjectUsage(subjectID uuid.UUID) (*StorageUsage, error) {
+	used, count, err := s.usage("subject_id", subjectID)
+	if err != nil {
+		return nil, err
+	}
+	return newStorageUsage(used, count, s.defaultSubjectQuota), nil
+}
+
+func newStorageUsage(used, count, quota int64) *StorageUsage {
+	usage := &StorageUsage{
+		UsedBytes:     used,
+		QuotaBytes:    quota,
+		DocumentCount: count,
+	}
+	if quota > 0 {
+		remaining := quota - used
+		if remaining < 0 {
+			remaining = 0
+		}
+		usage.RemainingBytes = &remaining
+	}
+	return usage
+}
+
+// CheckUpload returns a *QuotaExceededError if storing size more bytes would
+// exceed the uploader's or the subject's quota. It is a cheap early check
+// before the file is stored; CheckUploadTx decides.
+func (s *QuotaService) CheckUpload(userID, subjectID uuid.UUID, size int64) error {
+	return s.checkUpload(s.db, userID, subjectID, size)
+}
+
+// CheckUploadTx checks the quotas inside the transaction that inserts the
+// document. It locks the uploader's and the subject's quota until the
+// transaction ends, so concurrent uploads cannot all pass on the same usage.
+func (s *QuotaService) CheckUploadTx(tx *gorm.DB, userID, subjectID uuid.UUID, size int64) error {
+	// Always user before subject, so two uploads cannot wait on each other
+	for _, key := range []string{"quota:user:" + userID.String(), "quota:subject:" + subjectID.String()} {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtextextended(?, 0))", key).Error; err != nil {
+			return err
+		}
+	}
+	return s.checkUpload(tx, userID, subjectID, size)
+}
+
+func (s *QuotaService) checkUpload(db *gorm.DB, userID, subjectID uuid.UUID, size int64) error {
+	used, _, err := usage(db, "uploaded_by", userID)
+	if err != nil {
+		return err
+	}
+	limit, err := s.userQuota(db, userID)
+	if err != nil {
+		return err
+	}
+	if limit > 0 && used+size > limit {
+		return &QuotaExceededError{Scope: QuotaScopeUser, Used: used, Limit: limit}
+	}
+
+	used, _, err = usage(db, "subject_id", subjectID)
+	if err != nil {
+		return err
+	}
+	if limit := s.defaultSubjectQuota; limit > 0 && used+size > limit {
+		return &QuotaExceededError{Scope: QuotaScopeSubject, Used: used, Limit: limit}
+	}
+
+	return nil
+}
+
+// SetUserQuota sets or clears (nil) a per-user quota override
+func (s *QuotaService) SetUserQuota(userID uuid.UUID, quota *int64) error {
+	result := s.db.Model(&models.User{}).Where("id = ?", userID).Update("storage_quota", quota)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetStorageReport returns the top consumers by uploader, subject and MIME type
+func (s *QuotaService) GetStorageReport(limit int) (*StorageReport, error) {
+	report := &StorageReport{
+		Users:     []UserStorageRow{},
+		Subjects:  []SubjectStorageRow{},
+		MimeTypes: []MimeTypeStorageRow{},
+	}
+
+	var totals struct {
+		TotalBytes     int64
+		TotalDocuments int64
+	}
+	if err := s.db.Unscoped().Model(&models.Document{}).
+		Select("COALESCE(SUM(file_size), 0) as total_bytes, COUNT(*) as total_documents").
+		Where("source_document_id IS NULL").
+		Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+	report.TotalBytes = totals.TotalBytes
+	report.TotalDocuments = totals.TotalDocuments
+
+	if err := s.db.Table("documents").
+		Select(`users.id as user_id, users.email, users.display_name,
+			SUM(documents.file_size) as used_bytes, COUNT(documents.id) as document_count,
+			COALESCE(users.storage_quota, ?) as quota_bytes`, s.defaultUserQuota).
+		Joins("JOIN users ON users.id = documents.uploaded_by").
+		Where("documents.source_document_id IS NULL").
+		Group("users.id").
+		Order("used_bytes DESC").
+		Limit(limit).
+		Scan(&report.Users).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Table("documents").
+		Select(`subjects.id as subject_id, subjects.code, subjects.name_cs,
+			SUM(documents.file_size) as used_bytes, COUNT(documents.id) as document_count`).
+		Joins("JOIN subjects ON subjects.id = documents.subject_id").
+		Where("documents.source_document_id IS NULL").
+		Group("subjects.id").
+		Order("used_bytes DESC").
+		Limit(limit).
+		Scan(&report.Subjects).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Table("documents").
+		Select("mime_type, SUM(file_size) as used_bytes, COUNT(*) as document_count").
+		Where("source_document_id IS NULL").
+		Group("mime_type").
+		Order("used_bytes DESC").
+		Limit(limit).
+		Scan(&report.MimeTypes).Error; err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
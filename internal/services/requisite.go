@@ -0,0 +1,386 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidRequisites is returned for requisites naming the subject
+	// itself, an unknown subject or a subject twice
+	ErrInvalidRequisites = errors.New("invalid subject requisites")
+	// ErrRequisiteCycle is returned when requisites would make a subject
+	// depend on itself through prerequisites
+	ErrRequisiteCycle = errors.New("subject requisites form a cycle")
+	// ErrInvalidStudyPlanStatus is returned for a status other than passed
+	// or planned
+	ErrInvalidStudyPlanStatus = errors.New("invalid study plan status")
+)
+
+// RequisiteInput names a subject required by another subject
+type RequisiteInput struct {
+	SubjectID uuid.UUID
+	Kind      models.RequisiteKind
+}
+
+// IsValidRequisiteKind reports whether kind is one of the known requisite kinds
+func IsValidRequisiteKind(kind models.RequisiteKind) bool {
+	return kind == models.RequisitePrerequisite || kind == models.RequisiteCorequisite
+}
+
+// SyncSubjectRequisites replaces the requisites of a subject. Subjects that
+// are corequisites of each other are fine, but a cycle containing a
+// prerequisite is refused: such subjects could never be taken.
+func SyncSubjectRequisites(tx *gorm.DB, subjectID uuid.UUID, inputs []RequisiteInput) ([]models.SubjectRequisite, error) {
+	requisites := make([]models.SubjectRequisite, 0, len(inputs))
+	seen := make(map[uuid.UUID]bool, len(inputs))
+	for _, input := range inputs {
+		kind := input.Kind
+		if kind == "" {
+			kind = models.RequisitePrerequisite
+		}
+		if !IsValidRequisiteKind(kind) {
+			return nil, fmt.Errorf("%w: unknown kind %q", ErrInvalidRequisites, kind)
+		}
+		if input.SubjectID == subjectID {
+			return nil, fmt.Errorf("%w: a subject cannot require itself", ErrInvalidRequisites)
+		}
+		if seen[input.SubjectID] {
+			return nil, fmt.Errorf("%w: subject %s is listed twice", ErrInvalidRequisites, input.SubjectID)
+		}
+		seen[input.SubjectID] = true
+		requisites = append(requisites, models.SubjectRequisite{
+			SubjectID:         subjectID,
+			RequiredSubjectID: input.SubjectID,
+			Kind:              kind,
+		})
+	}
+
+	if len(requisites) > 0 {
+		ids := make([]uuid.UUID, 0, len(seen))
+		for id := range seen {
+			ids = append(ids, id)
+		}
+		var count int64
+		if err := tx.Model(&models.Subject{}).Where("id IN ?", ids).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if int(count) != len(ids) {
+			return nil, fmt.Errorf("%w: unknown subject", ErrInvalidRequisites)
+		}
+		// Serialize requisite changes, so two transactions cannot each add
+		// half of a cycle that neither of them sees
+		if err := tx.Exec("LOCK TABLE subject_requisites IN SHARE ROW EXCLUSIVE MODE").Error; err != nil {
+			return nil, err
+		}
+		if err := checkRequisiteCycle(tx, subjectID, requisites); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Where("subject_id = ?", subjectID).Delete(&models.SubjectRequisite{}).Error; err != nil {
+		return nil, err
+	}
+	if len(requisites) > 0 {
+		if err := tx.Create(&requisites).Error; err != nil {
+			return nil, err
+		}
+	}
+	return requisites, nil
+}
+
+// checkRequisiteCycle looks for a path from the new requisites back to the
+// subject. The rest of the graph has no cycle, so any new one passes through
+// the subject; it is refused when one of its edges is a prerequisite.
+func checkRequisiteCycle(tx *gorm.DB, subjectID uuid.UUID, requisites []models.SubjectRequisite) error {
+	var edges []models.SubjectRequisite
+	if err := tx.Where("subject_id <> ?", subjectID).Find(&edges).Error; err != nil {
+		return err
+	}
+	graph := make(map[uuid.UUID][]models.SubjectRequisite)
+	for _, edge := range edges {
+		graph[edge.SubjectID] = append(graph[edge.SubjectID], edge)
+	}
+
+	// A state is a subject and whether the path so far has a prerequisite
+	type state struct {
+		subject      uuid.UUID
+		prerequisite bool
+	}
+	for _, start := range requisites {
+		first := state{start.RequiredSubjectID, start.Kind == models.RequisitePrerequisite}
+		previous := map[state]*state{first: nil}
+		queue := []state{first}
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			if current.subject == subjectID {
+				if !current.prerequisite {
+					continue
+				}
+				var path []uuid.UUID
+				for s := &current; s != nil; s = previous[*s] {
+					path = append(path, s.subject)
+				}
+				path = append(path, subjectID)
+				return requisiteCycleError(tx, path)
+			}
+			for _, edge := range graph[current.subject] {
+				next := state{edge.RequiredSubjectID, current.prerequisite || edge.Kind == models.RequisitePrerequisite}
+				if _, visited := previous[next]; visited {
+					continue
+				}
+				from := current
+				previous[next] = &from
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
+
+// requisiteCycleError names the subjects of a cycle by code. path is in
+// reverse order and starts and ends with the subject.
+func requisiteCycleError(tx *gorm.DB, path []uuid.UUID) error {
+	var subjects []models.Subject
+	tx.Select("id", "code").Where("id IN ?", path).Find(&subjects)
+	codes := make(map[uuid.UUID]string, len(subjects))
+	for _, subject := range subjects {
+		codes[subject.ID] = subject.Code
+	}
+
+	names := make([]string, len(path))
+	for i := range path {
+		id := path[len(path)-1-i]
+		names[i] = codes[id]
+		if names[i] == "" {
+			names[i] = id.String()
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrRequisiteCycle, strings.Join(names, " -> "))
+}
+
+// RequisiteNode is a subject in a requisite graph. Depth is the number of
+// requisite steps from the subject the graph was built for.
+type RequisiteNode struct {
+	ID      uuid.UUID `json:"id"`
+	Code    string    `json:"code"`
+	NameCS  string    `json:"name_cs"`
+	Credits int       `json:"credits"`
+	Depth   int       `json:"depth"`
+}
+
+// RequisiteEdge says that a subject requires another
+type RequisiteEdge struct {
+	SubjectID         uuid.UUID            `json:"subject_id"`
+	RequiredSubjectID uuid.UUID            `json:"required_subject_id"`
+	Kind              models.RequisiteKind `json:"kind"`
+}
+
+// RequisiteGraph is everything a subject requires, directly or transitively
+type RequisiteGraph struct {
+	SubjectID uuid.UUID       `json:"subject_id"`
+	Nodes     []RequisiteNode `json:"nodes"`
+	Edges     []RequisiteEdge `json:"edges"`
+}
+
+type RequisiteService struct {
+	db *gorm.DB
+}
+
+func NewRequisiteService(db *gorm.DB) *RequisiteService {
+	return &RequisiteService{db: db}
+}
+
+// Graph returns the transitive requisites of a subject, level by level
+func (s *RequisiteService) Graph(subjectID uuid.UUID) (*RequisiteGraph, error) {
+	var root models.Subject
+	if err := s.db.First(&root, "id = ?", subjectID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSubjectNotFound
+		}
+		return nil, err
+	}
+
+	graph := &RequisiteGraph{
+		SubjectID: subjectID,
+		Nodes:     []RequisiteNode{{ID: root.ID, Code: root.Code, NameCS: root.NameCS, Credits: root.Credits}},
+		Edges:     []RequisiteEdge{},
+	}
+	depths := map[uuid.UUID]int{subjectID: 0}
+	frontier := []uuid.UUID{subjectID}
+	for depth := 1; len(frontier) > 0; depth++ {
+		var requisites []models.SubjectRequisite
+		if err := s.db.Preload("RequiredSubject").
+			Where("subject_id IN ?", frontier).
+			Order("kind, created_at").
+			Find(&requisites).Error; err != nil {
+			return nil, err
+		}
+
+		frontier = nil
+		for _, requisite := range requisites {
+			graph.Edges = append(graph.Edges, RequisiteEdge{
+				SubjectID:         requisite.SubjectID,
+				RequiredSubjectID: requisite.RequiredSubjectID,
+				Kind:              requisite.Kind,
+			})
+			if _, ok := depths[requisite.RequiredSubjectID]; ok || requisite.RequiredSubject == nil {
+				continue
+			}
+			required := requisite.RequiredSubject
+			depths[required.ID] = depth
+			graph.Nodes = append(graph.Nodes, RequisiteNode{
+				ID:      required.ID,
+				Code:    required.Code,
+				NameCS:  required.NameCS,
+				Credits: required.Credits,
+				Depth:   depth,
+			})
+			frontier = append(frontier, required.ID)
+		}
+	}
+	return graph, nil
+}
+
+// StudyPlanWarning reports a requisite of a planned subject that is not met
+type StudyPlanWarning struct {
+	SubjectID         uuid.UUID            `json:"subject_id"`
+	SubjectCode       string               `json:"subject_code"`
+	RequiredSubjectID uuid.UUID            `json:"required_subject_id"`
+	RequiredCode      string               `json:"required_subject_code"`
+	Kind              models.RequisiteKind `json:"kind"`
+	// RequiredStatus is the status of the required subject in the plan:
+	// "planned", or "missing" when it is not in the plan
+	RequiredStatus string `json:"required_status"`
+	Message        string `json:"message"`
+}
+
+// StudyPlan is a user's passed and planned subjects with the unmet
+// requisites of the planned ones
+type StudyPlan struct {
+	Entries        []models.StudyPlanEntry `json:"entries"`
+	PassedCredits  int                     `json:"passed_credits"`
+	PlannedCredits int                     `json:"planned_credits"`
+	Warnings       []StudyPlanWarning      `json:"warnings"`
+}
+
+// StudyPlan returns the study plan of a user
+func (s *RequisiteService) StudyPlan(userID uuid.UUID) (*StudyPlan, error) {
+	var entries []models.StudyPlanEntry
+	if err := s.db.Preload("Subject").
+		Joins("JOIN subjects ON subjects.id = study_plan_entries.subject_id").
+		Where("study_plan_entries.user_id = ?", userID).
+		Order("study_plan_entries.status, subjects.code").
+		Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	plan := &StudyPlan{Entries: entries, Warnings: []StudyPlanWarning{}}
+	status := make(map[uuid.UUID]models.StudyPlanStatus, len(entries))
+	var planned []uuid.UUID
+	for _, entry := range entries {
+		status[entry.SubjectID] = entry.Status
+		credits := 0
+		if entry.Subject != nil {
+			credits = entry.Subject.Credits
+		}
+		if entry.Status == models.StudyPlanPassed {
+			plan.PassedCredits += credits
+		} else {
+			plan.PlannedCredits += credits
+			planned = append(planned, entry.SubjectID)
+		}
+	}
+	if len(planned) == 0 {
+		return plan, nil
+	}
+
+	var requisites []models.SubjectRequisite
+	if err := s.db.Preload("RequiredSubject").
+		Where("subject_id IN ?", planned).
+		Order("created_at").
+		Find(&requisites).Error; err != nil {
+		return nil, err
+	}
+	codes := make(map[uuid.UUID]string, len(entries))
+	for _, entry := range entries {
+		if entry.Subject != nil {
+			codes[entry.SubjectID] = entry.Subject.Code
+		}
+	}
+
+	for _, requisite := range requisites {
+		requiredStatus, inPlan := status[requisite.RequiredSubjectID]
+		if requiredStatus == models.StudyPlanPassed {
+			continue
+		}
+		// A corequisite may be taken together with the subject
+		if requisite.Kind == models.RequisiteCorequisite && inPlan {
+			continue
+		}
+
+		warning := StudyPlanWarning{
+			SubjectID:         requisite.SubjectID,
+			SubjectCode:       codes[requisite.SubjectID],
+			RequiredSubjectID: requisite.RequiredSubjectID,
+			Kind:              requisite.Kind,
+			RequiredStatus:    "missing",
+		}
+		if requisite.RequiredSubject != nil {
+			warning.RequiredCode = requisite.RequiredSubject.Code
+		}
+		switch {
+		case inPlan:
+			warning.RequiredStatus = string(requiredStatus)
+			warning.Message = fmt.Sprintf("%s must be passed before %s", warning.RequiredCode, warning.SubjectCode)
+		case requisite.Kind == models.RequisiteCorequisite:
+			warning.Message = fmt.Sprintf("%s must be passed or taken together with %s", warning.RequiredCode, warning.SubjectCode)
+		default:
+			warning.Message = fmt.Sprintf("%s requires %s, which is not in the plan", warning.SubjectCode, warning.RequiredCode)
+		}
+		plan.Warnings = append(plan.Warnings, warning)
+	}
+	return plan, nil
+}
+
+// SetStudyPlanEntry marks a subject as passed or planned for a user
+func (s *RequisiteService) SetStudyPlanEntry(userID, subjectID uuid.UUID, status models.StudyPlanStatus) (*models.StudyPlanEntry, error) {
+	if status != models.StudyPlanPassed && status != models.StudyPlanPlanned {
+		return nil, ErrInvalidStudyPlanStatus
+	}
+	var count int64
+	if err := s.db.Model(&models.Subject{}).Where("id = ?", subjectID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrSubjectNotFound
+	}
+
+	// Upsert so concurrent requests for the same subject never hit the unique
+	// index; RETURNING loads the ID and timestamps of an existing entry
+	entry := models.StudyPlanEntry{UserID: userID, SubjectID: subjectID, Status: status}
+	if err := s.db.Clauses(
+		clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "subject_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"status", "updated_at"}),
+		},
+		clause.Returning{},
+	).Create(&entry).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// RemoveStudyPlanEntry removes a subject from a user's study plan. It
+// reports whether the subject was in the plan.
+func (s *RequisiteService) RemoveStudyPlanEntry(userID, subjectID uuid.UUID) (bool, error) {
+	result := s.db.Where("user_id = ? AND subject_id = ?", userID, subjectID).Delete(&models.StudyPlanEntry{})
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/P3chys/entoo2-api/internal/config"
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// savedSearchOverlap re-checks documents uploaded shortly before the last
+	// run, because their text is only indexed once extraction has finished
+	savedSearchOverlap = time.Hour
+	// savedSearchMaxHits caps the matches taken from a single run of a search
+	savedSearchMaxHits = 100
+)
+
+// SavedSearchMatch is a document that newly matched a saved search
+type SavedSearchMatch struct {
+	SearchName   string
+	DocumentID   string
+	DocumentName string
+	SubjectID    string
+	SubjectCode  string
+}
+
+type SavedSearchService struct {
+	db       *gorm.DB
+	search   SearchService
+	email    *EmailService
+	interval time.Duration
+}
+
+func NewSavedSearchService(db *gorm.DB, cfg *config.Config, search SearchService, email *EmailService) *SavedSearchService {
+	interval, err := time.ParseDuration(cfg.SavedSearchInterval)
+	if err != nil || interval <= 0 {
+		log.Printf("Invalid SAVED_SEARCH_INTERVAL %q, using 15m", cfg.SavedSearchInterval)
+		interval = 15 * time.Minute
+	}
+
+	return &SavedSearchService{
+		db:       db,
+		search:   search,
+		email:    email,
+		interval: interval,
+	}
+}
+
+// SavedSearchOptions converts a saved search into document search options
+func SavedSearchOptions(saved models.SavedSearch) DocumentSearchOptions {
+	opts := DocumentSearchOptions{
+		Query:      saved.Query,
+		Type:       saved.Type,
+		MimeType:   saved.MimeType,
+		ExactMatch: saved.ExactMatch,
+	}
+	if saved.SubjectID != nil {
+		opts.SubjectID = saved.SubjectID.String()
+	}
+	if saved.SemesterID != nil {
+		opts.SemesterID = saved.SemesterID.String()
+	}
+	if saved.CategoryID != nil {
+		opts.CategoryID = saved.CategoryID.String()
+	}
+	return opts
+}
+
+// StartScheduler runs all saved searches periodically until ctx is cancelled
+func (s *SavedSearchService) StartScheduler(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if created, err := s.RunAll(); err != nil {
+			log.Printf("Saved search run failed: %v", err)
+		} else if created > 0 {
+			log.Printf("Saved searches created %d notifications", created)
+		}
+	}
+}
+
+// RunAll runs every saved search for documents created since its last run,
+// stores a notification per new match and emails each user a summary.
+// It returns the number of notifications created.
+func (s *SavedSearchService) RunAll() (int, error) {
+	emailMatches := make(map[uuid.UUID][]SavedSearchMatch)
+	created := 0
+
+	// FindInBatches pages by primary key and needs that order to see every row
+	var batch []models.SavedSearch
+	err := s.db.FindInBatches(&batch, 100, func(tx *gorm.DB, _ int) error {
+		for _, saved := range batch {
+			matches, err := s.run(saved, time.Now())
+			if err != nil {
+				log.Printf("Saved search %s failed: %v", saved.ID, err)
+				continue
+			}
+			created += len(matches)
+			if saved.EmailEnabled && len(matches) > 0 {
+				emailMatches[saved.UserID] = append(emailMatches[saved.UserID], matches...)
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return created, err
+	}
+
+	for userID, matches := range emailMatches {
+		if err := s.sendEmail(userID, matches); err != nil {
+			log.Printf("Failed to send saved search email to user %s: %v", userID, err)
+		}
+	}
+	return created, nil
+}
+
+// run executes one saved search and records notifications for new matches
+func (s *SavedSearchService) run(saved models.SavedSearch, now time.Time) ([]SavedSearchMatch, error) {
+	from := saved.LastRunAt.Add(-savedSearchOverlap)
+	opts := SavedSearchOptions(saved)
+	opts.CreatedFrom = &from
+	opts.Sort = SearchSortDateDesc
+	opts.Limit = savedSearchMaxHits
+
+	result, err := s.search.Search(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []SavedSearchMatch
+	for _, raw := range result.Hits {
+		hit, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		// Users are not notified about their own uploads
+		if hitString(hit, "uploaded_by") == saved.UserID.String() {
+			continue
+		}
+		documentID, err := uuid.Parse(hitString(hit, "id"))
+		if err != nil {
+			continue
+		}
+		subjectID, err := uuid.Parse(hitString(hit, "subject_id"))
+		if err != nil {
+			continue
+		}
+
+		notification := models.Notification{
+			UserID:        saved.UserID,
+			Type:          models.NotificationSavedSearchMatch,
+			Title:         hitString(hit, "original_name"),
+			SavedSearchID: &saved.ID,
+			DocumentID:    &documentID,
+			SubjectID:     &subjectID,
+		}
+		res := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&notification)
+		if res.Error != nil {
+			return matches, res.Error
+		}
+		if res.RowsAffected == 0 {
+			// Already notified in an earlier run
+			continue
+		}
+
+		matches = append(matches, SavedSearchMatch{
+			SearchName:   saved.Name,
+			DocumentID:   documentID.String(),
+			DocumentName: hitString(hit, "original_name"),
+			SubjectID:    subjectID.String(),
+			SubjectCode:  hitString(hit, "subject_code"),
+		})
+	}
+
+	if err := s.db.Model(&models.SavedSearch{}).Where("id = ?", saved.ID).
+		UpdateColumn("last_run_at", now).Error; err != nil {
+		return matches, err
+	}
+	return matches, nil
+}
+
+func (s *SavedSearchService) sendEmail(userID uuid.UUID, matches []SavedSearchMatch) error {
+	if s.email == nil {
+		return nil
+	}
+
+	var user models.User
+	if err := s.db.Select("email", "language").First(&user, "id = ?", userID).Error; err != nil {
+		return err
+	}
+	return s.email.SendSavedSearchEmail(user.Email, matches, user.Language)
+}
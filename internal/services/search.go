@@ -1,202 +1,500 @@
 package services
 
 import (
+	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/P3chys/entoo2-api/internal/config"
 	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/google/uuid"
 	"github.com/meilisearch/meilisearch-go"
+	"gorm.io/gorm"
 )
 
-type SearchService struct {
+const (
+	documentsIndex = "documents"
+	subjectsIndex  = "subjects"
+)
+
+// Attributes that can be used in filters of the documents and subjects indexes
+var (
+	documentFilterableAttributes = []string{
+		"subject_id", "semester_id", "category_id", "type", "mime_type",
+		"mime_family", "uploaded_by", "created_at_ts", "file_size",
+		"academic_year_id", "term",
+	}
+	subjectFilterableAttributes = []string{"id", "semester_id", "code"}
+)
+
+// SearchService indexes documents, subjects, questions and comments and
+// searches them. Implementations are chosen with SEARCH_BACKEND.
+type SearchService interface {
+	IndexDocument(doc models.Document) error
+	IndexDocumentsByID(ids []uuid.UUID) error
+	DeleteDocument(docID string) error
+	Search(opts DocumentSearchOptions) (*SearchResult, error)
+	GetDocumentCount() (int64, error)
+
+	IndexSubject(subject models.Subject) error
+	IndexSubjects(subjects []models.Subject) error
+	IndexSubjectsByID(ids []uuid.UUID) error
+	DeleteSubject(subjectID string) error
+	SearchSubjects(opts DocumentSearchOptions) (*SearchResult, error)
+
+	IndexQuestion(questionID uuid.UUID) error
+	IndexQuestionsByID(ids []uuid.UUID) error
+	DeleteQuestion(questionID string) error
+	SearchQuestions(opts DocumentSearchOptions) (*SearchResult, error)
+
+	IndexComment(commentID uuid.UUID) error
+	IndexCommentsByID(ids []uuid.UUID) error
+	DeleteComment(commentID string) error
+	SearchComments(opts DocumentSearchOptions) (*SearchResult, error)
+
+	SearchAll(searchType string, opts DocumentSearchOptions) (map[string]interface{}, error)
+	Suggest(query string, limit int) (*Suggestions, error)
+
+	// ApplyVocabulary replaces the synonyms and stop words used by all searches
+	ApplyVocabulary(vocabulary SearchVocabulary) error
+}
+
+// Search backends selectable with SEARCH_BACKEND
+const (
+	SearchBackendMeilisearch = "meilisearch"
+	SearchBackendPostgres    = "postgres"
+)
+
+// NewSearchService creates the search backend configured in cfg
+func NewSearchService(cfg *config.Config, db *gorm.DB) SearchService {
+	switch cfg.SearchBackend {
+	case SearchBackendPostgres:
+		return NewPostgresSearchService(db)
+	case SearchBackendMeilisearch:
+	default:
+		log.Printf("Unknown SEARCH_BACKEND %q, using %s", cfg.SearchBackend, SearchBackendMeilisearch)
+	}
+	return NewMeiliSearchService(cfg, db)
+}
+
+type MeiliSearchService struct {
+	db     *gorm.DB
 	client *meilisearch.Client
 	index  string
 }
 
-func NewSearchService(cfg *config.Config) *SearchService {
+func NewMeiliSearchService(cfg *config.Config, db *gorm.DB) *MeiliSearchService {
 	client := meilisearch.NewClient(meilisearch.ClientConfig{
 		Host:   cfg.MeiliURL,
 		APIKey: cfg.MeiliAPIKey,
 	})
 
-	// Ensure documents index exists (best effort)
-	if _, indexErr := client.GetIndex("documents"); indexErr != nil {
+	// Ensure the indexes exist and carry their settings (best effort)
+	for _, kind := range SearchIndexKinds {
+		ensureIndex(client, kind.UID, kind.settings())
+	}
+
+	return &MeiliSearchService{
+		db:     db,
+		client: client,
+		index:  documentsIndex,
+	}
+}
+
+// ensureIndex creates the index when it is missing and applies its settings (best effort)
+func ensureIndex(client *meilisearch.Client, uid string, settings *meilisearch.Settings) {
+	if _, indexErr := client.GetIndex(uid); indexErr != nil {
 		if _, createErr := client.CreateIndex(&meilisearch.IndexConfig{
-			Uid:        "documents",
+			Uid:        uid,
 			PrimaryKey: "id",
 		}); createErr != nil {
-			log.Printf("Failed to create meilisearch documents index: %v", createErr)
+			log.Printf("Failed to create meilisearch %s index: %v", uid, createErr)
 		}
 	}
 
-	// Configure documents index settings
-	docIndex := client.Index("documents")
-
-	// Configure filterable attributes
-	_, err := docIndex.UpdateFilterableAttributes(&[]string{"subject_id", "mime_type", "category"})
-	if err != nil {
-		log.Printf("Failed to update filterable attributes: %v", err)
+	if _, err := client.Index(uid).UpdateSettings(settings); err != nil {
+		log.Printf("Failed to update %s index settings: %v", uid, err)
 	}
+}
 
-	// Configure sortable attributes
-	_, err = docIndex.UpdateSortableAttributes(&[]string{"created_at", "file_size"})
-	if err != nil {
-		log.Printf("Failed to update sortable attributes: %v", err)
+// ApplyVocabulary replaces the synonyms and stop words of every index and
+// waits until Meilisearch has applied them
+func (s *MeiliSearchService) ApplyVocabulary(vocabulary SearchVocabulary) error {
+	synonyms := vocabulary.Synonyms
+	if synonyms == nil {
+		synonyms = map[string][]string{}
+	}
+	stopWords := vocabulary.StopWords
+	if stopWords == nil {
+		stopWords = []string{}
 	}
 
-	// Configure searchable attributes with priorities
-	_, err = docIndex.UpdateSearchableAttributes(&[]string{
-		"original_name",  // Highest priority
-		"content_text",   // Second priority
-		"filename",       // Third priority
-	})
-	if err != nil {
-		log.Printf("Failed to update searchable attributes: %v", err)
+	for _, kind := range SearchIndexKinds {
+		index := s.client.Index(kind.UID)
+		if err := s.waitForTask(index.UpdateSynonyms(&synonyms)); err != nil {
+			return fmt.Errorf("failed to update %s synonyms: %w", kind.UID, err)
+		}
+		if err := s.waitForTask(index.UpdateStopWords(&stopWords)); err != nil {
+			return fmt.Errorf("failed to update %s stop words: %w", kind.UID, err)
+		}
 	}
+	return nil
+}
 
-	// Configure ranking rules for better relevance
-	_, err = docIndex.UpdateRankingRules(&[]string{
-		"words",           // Number of matched words
-		"typo",            // Typo tolerance
-		"proximity",       // Proximity of matched words
-		"attribute",       // Order of searchable attributes
-		"sort",            // Custom sorting
-		"exactness",       // Exact matches first
-	})
-	if err != nil {
-		log.Printf("Failed to update ranking rules: %v", err)
+func documentIndexSettings() *meilisearch.Settings {
+	return &meilisearch.Settings{
+		FilterableAttributes: documentFilterableAttributes,
+		SortableAttributes:   []string{"created_at_ts", "file_size"},
+		SearchableAttributes: []string{
+			"original_name", // Highest priority
+			"content_text",  // Second priority
+			"subject_code",  // Third priority
+			"subject_name",
+			"category_name_cs",
+			"category_name_en",
+		},
+		// Ranking rules for better relevance
+		RankingRules: []string{
+			"words",     // Number of matched words
+			"typo",      // Typo tolerance
+			"proximity", // Proximity of matched words
+			"attribute", // Order of searchable attributes
+			"sort",      // Custom sorting
+			"exactness", // Exact matches first
+		},
+		// Typo tolerance is enabled by default in Meilisearch with reasonable settings
+		// Default: 1 typo for words >= 5 chars, 2 typos for words >= 9 chars
 	}
+}
 
-	// Typo tolerance is enabled by default in Meilisearch with reasonable settings
-	// Default: 1 typo for words >= 5 chars, 2 typos for words >= 9 chars
+func subjectIndexSettings() *meilisearch.Settings {
+	return &meilisearch.Settings{
+		FilterableAttributes: subjectFilterableAttributes,
+		SortableAttributes:   []string{"name_cs", "name_en", "created_at", "credits"},
+		SearchableAttributes: []string{
+			"code",           // Highest priority (exact course codes)
+			"name_en",        // Second priority
+			"name_cs",        // Third priority
+			"description_en", // Fourth priority
+			"description_cs", // Fifth priority
+		},
+		RankingRules: []string{
+			"words",
+			"typo",
+			"proximity",
+			"attribute",
+			"sort",
+			"exactness",
+		},
+	}
+}
 
-	// Ensure subjects index exists (best effort)
-	if _, indexErr := client.GetIndex("subjects"); indexErr != nil {
-		if _, createErr := client.CreateIndex(&meilisearch.IndexConfig{
-			Uid:        "subjects",
-			PrimaryKey: "id",
-		}); createErr != nil {
-			log.Printf("Failed to create meilisearch subjects index: %v", createErr)
-		}
+// MimeFamily groups MIME types into the coarse families shown as search facets
+func MimeFamily(mimeType string) string {
+	switch {
+	case mimeType == "application/pdf":
+		return "pdf"
+	case mimeType == "application/msword",
+		mimeType == "application/vnd.openxmlformats-officedocument.wordprocessingml.document":
+		return "document"
+	case mimeType == "application/vnd.ms-powerpoint",
+		mimeType == "application/vnd.openxmlformats-officedocument.presentationml.presentation":
+		return "presentation"
+	case mimeType == "application/vnd.ms-excel",
+		mimeType == "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		mimeType == "text/csv", mimeType == "application/csv":
+		return "spreadsheet"
+	case strings.HasPrefix(mimeType, "image/"):
+		return "image"
+	case strings.HasPrefix(mimeType, "text/"):
+		return "text"
+	case mimeType == "application/zip", mimeType == "application/x-zip-compressed":
+		return "archive"
+	default:
+		return "other"
 	}
+}
 
-	// Configure subjects index settings
-	subIndex := client.Index("subjects")
+// IndexDocument (re)indexes a document. The document is reloaded with the
+// relations its search record needs, so callers can pass it as stored.
+func (s *MeiliSearchService) IndexDocument(doc models.Document) error {
+	return s.IndexDocumentsByID([]uuid.UUID{doc.ID})
+}
 
-	// Configure filterable attributes for subjects
-	_, err = subIndex.UpdateFilterableAttributes(&[]string{"semester_id", "code"})
+// IndexDocumentsByID loads the given documents and replaces their search
+// records. Documents that are trashed or gone lose their record.
+func (s *MeiliSearchService) IndexDocumentsByID(ids []uuid.UUID) error {
+	records, err := s.documentRecords(ids)
 	if err != nil {
-		log.Printf("Failed to update subjects filterable attributes: %v", err)
+		return err
 	}
+	found := make([]string, len(records))
+	for i, record := range records {
+		found[i] = record.ID
+	}
+	return s.replaceRecords(s.index, ids, records, found)
+}
 
-	// Configure sortable attributes for subjects
-	_, err = subIndex.UpdateSortableAttributes(&[]string{"name_cs", "name_en", "created_at", "credits"})
-	if err != nil {
-		log.Printf("Failed to update subjects sortable attributes: %v", err)
+// replaceRecords adds records to an index and deletes the records of the
+// requested IDs that have none, e.g. rows deleted or trashed after their
+// index change was queued. found lists the IDs of records.
+func (s *MeiliSearchService) replaceRecords(uid string, ids []uuid.UUID, records interface{}, found []string) error {
+	if len(found) > 0 {
+		// Meilisearch accepts a list of documents
+		if err := s.waitForTask(s.client.Index(uid).AddDocuments(records)); err != nil {
+			return err
+		}
 	}
 
-	// Configure searchable attributes for subjects with priorities
-	_, err = subIndex.UpdateSearchableAttributes(&[]string{
-		"code",           // Highest priority (exact course codes)
-		"name_en",        // Second priority
-		"name_cs",        // Third priority
-		"description_en", // Fourth priority
-		"description_cs", // Fifth priority
-	})
-	if err != nil {
-		log.Printf("Failed to update subjects searchable attributes: %v", err)
+	present := make(map[string]bool, len(found))
+	for _, id := range found {
+		present[id] = true
+	}
+	var missing []string
+	for _, id := range ids {
+		if !present[id.String()] {
+			missing = append(missing, id.String())
+		}
 	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return s.waitForTask(s.client.Index(uid).DeleteDocuments(missing))
+}
 
-	// Configure ranking rules for subjects
-	_, err = subIndex.UpdateRankingRules(&[]string{
-		"words",
-		"typo",
-		"proximity",
-		"attribute",
-		"sort",
-		"exactness",
-	})
-	if err != nil {
-		log.Printf("Failed to update subjects ranking rules: %v", err)
+// documentRecords loads the search records of the given documents
+func (s *MeiliSearchService) documentRecords(ids []uuid.UUID) ([]SearchDocument, error) {
+	if len(ids) == 0 {
+		return nil, nil
 	}
 
-	// Note: Typo tolerance enabled by default for better search experience
-	// Course codes prioritized via searchable attributes ranking
+	var docs []models.Document
+	if err := s.db.Preload("Subject.Semester").Preload("Uploader").Preload("Category").Preload("Offering.Term").
+		Where("id IN ?", ids).Find(&docs).Error; err != nil {
+		return nil, err
+	}
 
-	return &SearchService{
-		client: client,
-		index:  "documents",
+	records := make([]SearchDocument, len(docs))
+	for i, doc := range docs {
+		records[i] = NewSearchDocument(doc)
 	}
+	return records, nil
 }
 
-func (s *SearchService) IndexDocument(doc models.Document) error {
-	// Meilisearch accepts a list of documents
-	_, err := s.client.Index(s.index).AddDocuments([]models.Document{doc})
-	return err
+func (s *MeiliSearchService) DeleteDocument(docID string) error {
+	return s.waitForTask(s.client.Index(s.index).DeleteDocument(docID))
 }
 
-func (s *SearchService) DeleteDocument(docID string) error {
-	_, err := s.client.Index(s.index).DeleteDocument(docID)
-	return err
+// Sort orders accepted by Search
+const (
+	SearchSortRelevance = "relevance"
+	SearchSortDateDesc  = "date_desc"
+	SearchSortDateAsc   = "date_asc"
+	SearchSortSizeDesc  = "size_desc"
+	SearchSortSizeAsc   = "size_asc"
+)
+
+// DocumentFacets are the attributes whose value counts are returned with document results
+var DocumentFacets = []string{"subject_id", "type", "mime_family"}
+
+// DocumentSearchOptions holds the query, filters and paging of a document search.
+// Empty fields do not filter.
+type DocumentSearchOptions struct {
+	Query       string
+	SubjectID   string
+	SemesterID  string
+	// AcademicYearID and Term (winter or summer) select the offerings the
+	// documents belong to and the subjects are offered in
+	AcademicYearID string
+	Term           string
+	MimeType    string
+	Type        string
+	CategoryID  string
+	UploadedBy  string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	MinSize     int64
+	MaxSize     int64
+	Sort        string
+	Limit       int
+	Offset      int
+	ExactMatch  bool
+}
+
+// termFilter returns the academic year and term filter of the options.
+// Handlers validate both values, so malformed ones are ignored here.
+func (opts DocumentSearchOptions) termFilter() TermFilter {
+	filter, _ := ParseTermFilter(opts.AcademicYearID, opts.Term)
+	return filter
 }
 
-func (s *SearchService) Search(query string, subjectID string, mimeType string, exactMatch bool) (*meilisearch.SearchResponse, error) {
+// SearchResult is a page of hits with the total number of matches and facet counts
+type SearchResult struct {
+	Hits   []interface{}               `json:"hits"`
+	Total  int64                       `json:"total"`
+	Offset int                         `json:"offset"`
+	Limit  int                         `json:"limit"`
+	Facets map[string]map[string]int64 `json:"facets,omitempty"`
+}
+
+func documentSearchFilter(opts DocumentSearchOptions) (string, error) {
+	filter := NewSearchFilter(documentFilterableAttributes)
+	if opts.SubjectID != "" {
+		filter.Eq("subject_id", opts.SubjectID)
+	}
+	if opts.SemesterID != "" {
+		filter.Eq("semester_id", opts.SemesterID)
+	}
+	if opts.AcademicYearID != "" {
+		filter.Eq("academic_year_id", opts.AcademicYearID)
+	}
+	if opts.Term != "" {
+		filter.Eq("term", opts.Term)
+	}
+	if opts.MimeType != "" {
+		filter.Eq("mime_type", opts.MimeType)
+	}
+	if opts.Type != "" {
+		filter.Eq("type", opts.Type)
+	}
+	if opts.CategoryID != "" {
+		filter.Eq("category_id", opts.CategoryID)
+	}
+	if opts.UploadedBy != "" {
+		filter.Eq("uploaded_by", opts.UploadedBy)
+	}
+	if opts.CreatedFrom != nil {
+		filter.Gte("created_at_ts", opts.CreatedFrom.Unix())
+	}
+	if opts.CreatedTo != nil {
+		filter.Lte("created_at_ts", opts.CreatedTo.Unix())
+	}
+	if opts.MinSize > 0 {
+		filter.Gte("file_size", opts.MinSize)
+	}
+	if opts.MaxSize > 0 {
+		filter.Lte("file_size", opts.MaxSize)
+	}
+	return filter.Build()
+}
+
+func documentSearchSort(sort string) []string {
+	switch sort {
+	case SearchSortDateDesc:
+		return []string{"created_at_ts:desc"}
+	case SearchSortDateAsc:
+		return []string{"created_at_ts:asc"}
+	case SearchSortSizeDesc:
+		return []string{"file_size:desc"}
+	case SearchSortSizeAsc:
+		return []string{"file_size:asc"}
+	default:
+		return nil
+	}
+}
+
+func (s *MeiliSearchService) Search(opts DocumentSearchOptions) (*SearchResult, error) {
 	request := &meilisearch.SearchRequest{
-		Limit:                    50,
+		Limit:                    int64(opts.Limit),
+		Offset:                   int64(opts.Offset),
 		AttributesToHighlight:    []string{"content_text", "original_name"},
 		HighlightPreTag:          "<mark>",
 		HighlightPostTag:         "</mark>",
 		AttributesToCrop:         []string{"content_text"},
 		CropLength:               200,
 		ShowMatchesPosition:      true,
+		Facets:                   DocumentFacets,
+		Sort:                     documentSearchSort(opts.Sort),
 	}
 
-	// Build filter conditions
-	var filters []string
-	if subjectID != "" {
-		filters = append(filters, "subject_id = "+subjectID)
-	}
-	if mimeType != "" {
-		filters = append(filters, "mime_type = "+mimeType)
+	filter, err := documentSearchFilter(opts)
+	if err != nil {
+		return nil, err
 	}
-
-	if len(filters) > 0 {
-		filterStr := filters[0]
-		for i := 1; i < len(filters); i++ {
-			filterStr += " AND " + filters[i]
-		}
-		request.Filter = filterStr
+	if filter != "" {
+		request.Filter = filter
 	}
 
 	// Disable fuzzy matching for exact searches
-	if exactMatch {
+	if opts.ExactMatch {
 		request.MatchingStrategy = "all"
 	}
 
-	return s.client.Index(s.index).Search(query, request)
+	resp, err := s.client.Index(s.index).Search(opts.Query, request)
+	if err != nil {
+		return nil, err
+	}
+	return newSearchResult(resp, opts.Limit, opts.Offset), nil
 }
 
-func (s *SearchService) IndexSubject(subject models.Subject) error {
-	_, err := s.client.Index("subjects").AddDocuments([]models.Subject{subject})
-	return err
+func newSearchResult(resp *meilisearch.SearchResponse, limit, offset int) *SearchResult {
+	result := &SearchResult{
+		Hits:   resp.Hits,
+		Total:  resp.EstimatedTotalHits,
+		Offset: offset,
+		Limit:  limit,
+	}
+	if result.Hits == nil {
+		result.Hits = []interface{}{}
+	}
+
+	// FacetDistribution is decoded as nested generic maps
+	if distribution, ok := resp.FacetDistribution.(map[string]interface{}); ok {
+		result.Facets = make(map[string]map[string]int64, len(distribution))
+		for facet, values := range distribution {
+			counts := make(map[string]int64)
+			if valueMap, ok := values.(map[string]interface{}); ok {
+				for value, count := range valueMap {
+					if n, ok := count.(float64); ok {
+						counts[value] = int64(n)
+					}
+				}
+			}
+			result.Facets[facet] = counts
+		}
+	}
+	return result
 }
 
-func (s *SearchService) IndexSubjects(subjects []models.Subject) error {
+func (s *MeiliSearchService) IndexSubject(subject models.Subject) error {
+	return s.waitForTask(s.client.Index(subjectsIndex).AddDocuments([]models.Subject{subject}))
+}
+
+func (s *MeiliSearchService) IndexSubjects(subjects []models.Subject) error {
 	if len(subjects) == 0 {
 		return nil
 	}
-	_, err := s.client.Index("subjects").AddDocuments(subjects)
-	return err
+	return s.waitForTask(s.client.Index(subjectsIndex).AddDocuments(subjects))
 }
 
-func (s *SearchService) DeleteSubject(subjectID string) error {
-	_, err := s.client.Index("subjects").DeleteDocument(subjectID)
-	return err
+// IndexSubjectsByID loads the given subjects and replaces their search
+// records. Subjects that are gone lose their record.
+func (s *MeiliSearchService) IndexSubjectsByID(ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	var subjects []models.Subject
+	if err := s.db.Where("id IN ?", ids).Find(&subjects).Error; err != nil {
+		return err
+	}
+	found := make([]string, len(subjects))
+	for i, subject := range subjects {
+		found[i] = subject.ID.String()
+	}
+	return s.replaceRecords(subjectsIndex, ids, subjects, found)
+}
+
+func (s *MeiliSearchService) DeleteSubject(subjectID string) error {
+	return s.waitForTask(s.client.Index(subjectsIndex).DeleteDocument(subjectID))
 }
 
-func (s *SearchService) SearchSubjects(query string, semesterID string, exactMatch bool) (*meilisearch.SearchResponse, error) {
+// SearchSubjects searches subjects. Only the query, semester, academic
+// year, term and paging options apply.
+func (s *MeiliSearchService) SearchSubjects(opts DocumentSearchOptions) (*SearchResult, error) {
 	request := &meilisearch.SearchRequest{
-		Limit:                    100,
+		Limit:                    int64(opts.Limit),
+		Offset:                   int64(opts.Offset),
 		AttributesToHighlight:    []string{"name_cs", "name_en", "description_cs", "description_en", "code"},
 		HighlightPreTag:          "<mark>",
 		HighlightPostTag:         "</mark>",
@@ -205,54 +503,100 @@ func (s *SearchService) SearchSubjects(query string, semesterID string, exactMat
 		ShowMatchesPosition:      true,
 	}
 
-	if semesterID != "" {
-		request.Filter = "semester_id = " + semesterID
+	filter := NewSearchFilter(subjectFilterableAttributes)
+	if opts.SemesterID != "" {
+		filter.Eq("semester_id", opts.SemesterID)
+	}
+	// Offerings are not part of the subject records, so the term is
+	// resolved to the IDs of the subjects offered in it
+	if termFilter := opts.termFilter(); !termFilter.IsZero() {
+		var subjectIDs []string
+		if err := termFilter.SubjectIDs(s.db).Distinct().Pluck("subject_offerings.subject_id", &subjectIDs).Error; err != nil {
+			return nil, err
+		}
+		if len(subjectIDs) == 0 {
+			return emptySearchResult(opts.Limit, opts.Offset), nil
+		}
+		filter.In("id", subjectIDs)
+	}
+	filterStr, err := filter.Build()
+	if err != nil {
+		return nil, err
+	}
+	if filterStr != "" {
+		request.Filter = filterStr
 	}
 
 	// Disable fuzzy matching for exact searches
-	if exactMatch {
+	if opts.ExactMatch {
 		request.MatchingStrategy = "all"
 	}
 
-	return s.client.Index("subjects").Search(query, request)
+	resp, err := s.client.Index(subjectsIndex).Search(opts.Query, request)
+	if err != nil {
+		return nil, err
+	}
+	return newSearchResult(resp, opts.Limit, opts.Offset), nil
+}
+
+func (s *MeiliSearchService) SearchAll(searchType string, opts DocumentSearchOptions) (map[string]interface{}, error) {
+	return searchAll(s, searchType, opts)
 }
 
-// SearchAll searches both documents and subjects and combines results
-func (s *SearchService) SearchAll(query string, searchType string, subjectID string, mimeType string, exactMatch bool) (map[string]interface{}, error) {
-	result := make(map[string]interface{})
+// searchAll searches documents, subjects, questions and comments and combines results.
+// Subjects are filtered by semester, academic year and term only, questions
+// and comments by subject, semester and date; the other filters apply to documents.
+func searchAll(s SearchService, searchType string, opts DocumentSearchOptions) (map[string]interface{}, error) {
+	result := map[string]interface{}{
+		"offset": opts.Offset,
+		"limit":  opts.Limit,
+	}
 
 	// Search documents if type is "all" or "documents"
 	if searchType == "" || searchType == "all" || searchType == "documents" {
-		docResults, err := s.Search(query, subjectID, mimeType, exactMatch)
+		docResults, err := s.Search(opts)
 		if err != nil {
 			return nil, err
 		}
 		result["documents"] = docResults.Hits
-		result["documents_count"] = docResults.EstimatedTotalHits
+		result["documents_count"] = docResults.Total
+		result["facets"] = docResults.Facets
 	}
 
 	// Search subjects if type is "all" or "subjects"
 	if searchType == "" || searchType == "all" || searchType == "subjects" {
-		subjectResults, err := s.SearchSubjects(query, "", exactMatch)
+		subjectResults, err := s.SearchSubjects(opts)
 		if err != nil {
 			return nil, err
 		}
 		result["subjects"] = subjectResults.Hits
-		result["subjects_count"] = subjectResults.EstimatedTotalHits
+		result["subjects_count"] = subjectResults.Total
 	}
 
-	return result, nil
-}
+	// Search questions (with answers) if type is "all" or "questions"
+	if searchType == "" || searchType == "all" || searchType == "questions" {
+		questionResults, err := s.SearchQuestions(opts)
+		if err != nil {
+			return nil, err
+		}
+		result["questions"] = questionResults.Hits
+		result["questions_count"] = questionResults.Total
+	}
 
-func (s *SearchService) IndexDocuments(docs []models.Document) error {
-	if len(docs) == 0 {
-		return nil
+	// Search comments if type is "all" or "comments"
+	if searchType == "" || searchType == "all" || searchType == "comments" {
+		commentResults, err := s.SearchComments(opts)
+		if err != nil {
+			return nil, err
+		}
+		result["comments"] = commentResults.Hits
+		result["comments_count"] = commentResults.Total
 	}
-	_, err := s.client.Index(s.index).AddDocuments(docs)
-	return err
+
+	return result, nil
 }
 
-func (s *SearchService) GetDocumentCount() (int64, error) {
+func (s *MeiliSearchService) GetDocumentCount() (int64, error) {
 	stats, err := s.client.Index(s.index).GetStats()
 	if err != nil {
 		return 0, err
@@ -0,0 +1,381 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/P3chys/entoo2-api/internal/config"
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// searchAnalyticsInterval is how often today's statistics are recomputed
+	searchAnalyticsInterval = time.Hour
+	// minSearchLogRetention keeps the logs of the days that are still re-aggregated
+	minSearchLogRetention = 48 * time.Hour
+	maxLoggedQueryLength  = 200
+)
+
+var (
+	loggedEmailPattern  = regexp.MustCompile(`[^\s@]+@[^\s@]+`)
+	loggedNumberPattern = regexp.MustCompile(`\d{6,}`)
+)
+
+// AnonymizeSearchQuery normalizes a query for the search log and masks
+// e-mail addresses and long numbers such as student or phone numbers
+func AnonymizeSearchQuery(query string) string {
+	query = NormalizeSuggestQuery(query)
+	query = loggedEmailPattern.ReplaceAllString(query, "<email>")
+	query = loggedNumberPattern.ReplaceAllString(query, "<number>")
+	if len([]rune(query)) > maxLoggedQueryLength {
+		query = string([]rune(query)[:maxLoggedQueryLength])
+	}
+	return query
+}
+
+// SearchLogFilters are the filters stored with a logged search. The uploader
+// filter is only recorded as used, not by whom.
+type SearchLogFilters struct {
+	Type           string `json:"type,omitempty"`
+	MimeType       string `json:"mime_type,omitempty"`
+	SemesterID     string `json:"semester_id,omitempty"`
+	AcademicYearID string `json:"academic_year_id,omitempty"`
+	Term           string `json:"term,omitempty"`
+	CategoryID     string `json:"category_id,omitempty"`
+	ByUploader     bool   `json:"by_uploader,omitempty"`
+	DateFrom       string `json:"date_from,omitempty"`
+	DateTo         string `json:"date_to,omitempty"`
+	MinSize        int64  `json:"min_size,omitempty"`
+	MaxSize        int64  `json:"max_size,omitempty"`
+	Sort           string `json:"sort,omitempty"`
+	ExactMatch     bool   `json:"exact,omitempty"`
+}
+
+func searchLogFilters(opts DocumentSearchOptions) SearchLogFilters {
+	filters := SearchLogFilters{
+		Type:           opts.Type,
+		MimeType:       opts.MimeType,
+		SemesterID:     opts.SemesterID,
+		AcademicYearID: opts.AcademicYearID,
+		Term:           opts.Term,
+		CategoryID:     opts.CategoryID,
+		ByUploader:     opts.UploadedBy != "",
+		MinSize:        opts.MinSize,
+		MaxSize:        opts.MaxSize,
+		ExactMatch:     opts.ExactMatch,
+	}
+	if opts.Sort != SearchSortRelevance {
+		filters.Sort = opts.Sort
+	}
+	if opts.CreatedFrom != nil {
+		filters.DateFrom = opts.CreatedFrom.Format("2006-01-02")
+	}
+	if opts.CreatedTo != nil {
+		filters.DateTo = opts.CreatedTo.Format("2006-01-02")
+	}
+	return filters
+}
+
+// searchResultCount sums the totals of all scopes of a SearchAll result
+func searchResultCount(result map[string]interface{}) int64 {
+	var count int64
+	for _, key := range []string{"documents_count", "subjects_count", "questions_count", "comments_count"} {
+		if total, ok := result[key].(int64); ok {
+			count += total
+		}
+	}
+	return count
+}
+
+// SearchQueryStat is a query with its search, zero-result and click counts
+type SearchQueryStat struct {
+	Query            string  `json:"query"`
+	Searches         int64   `json:"searches"`
+	ZeroResults      int64   `json:"zero_results"`
+	Clicks           int64   `json:"clicks"`
+	ClickThroughRate float64 `json:"click_through_rate"`
+}
+
+// SearchSubjectStat covers the searches filtered to one subject
+type SearchSubjectStat struct {
+	SubjectID        uuid.UUID `json:"subject_id"`
+	Code             string    `json:"code"`
+	NameCS           string    `json:"name_cs"`
+	Searches         int64     `json:"searches"`
+	ZeroResults      int64     `json:"zero_results"`
+	Clicks           int64     `json:"clicks"`
+	ClickThroughRate float64   `json:"click_through_rate"`
+}
+
+// SearchAnalyticsReport summarizes the searches of a date range
+type SearchAnalyticsReport struct {
+	From              string              `json:"from"`
+	To                string              `json:"to"`
+	Searches          int64               `json:"searches"`
+	ZeroResults       int64               `json:"zero_results"`
+	Clicks            int64               `json:"clicks"`
+	ClickThroughRate  float64             `json:"click_through_rate"`
+	TopQueries        []SearchQueryStat   `json:"top_queries"`
+	ZeroResultQueries []SearchQueryStat   `json:"zero_result_queries"`
+	Subjects          []SearchSubjectStat `json:"subjects"`
+}
+
+// SearchAnalyticsReportOptions selects the days (inclusive, UTC) and
+// optionally the subject filter a report covers
+type SearchAnalyticsReportOptions struct {
+	From      time.Time
+	To        time.Time
+	SubjectID *uuid.UUID
+	Limit     int
+}
+
+// SearchAnalyticsService logs searches and the results clicked from them,
+// aggregates the logs per day and builds reports from the aggregates
+type SearchAnalyticsService struct {
+	db        *gorm.DB
+	retention time.Duration
+}
+
+func NewSearchAnalyticsService(db *gorm.DB, cfg *config.Config) *SearchAnalyticsService {
+	retention, err := time.ParseDuration(cfg.SearchLogRetention)
+	if err != nil || retention < minSearchLogRetention {
+		log.Printf("Invalid SEARCH_LOG_RETENTION %q, using 2160h", cfg.SearchLogRetention)
+		retention = 90 * 24 * time.Hour
+	}
+
+	return &SearchAnalyticsService{
+		db:        db,
+		retention: retention,
+	}
+}
+
+// Record logs a search and returns the ID clients send back with a click
+func (s *SearchAnalyticsService) Record(scope string, opts DocumentSearchOptions, result map[string]interface{}) (uuid.UUID, error) {
+	if scope == "" {
+		scope = "all"
+	}
+	filters, err := json.Marshal(searchLogFilters(opts))
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	entry := models.SearchQueryLog{
+		Query:       AnonymizeSearchQuery(opts.Query),
+		Scope:       scope,
+		Filters:     string(filters),
+		ResultCount: searchResultCount(result),
+	}
+	if opts.SubjectID != "" {
+		if subjectID, err := uuid.Parse(opts.SubjectID); err == nil {
+			entry.SubjectID = &subjectID
+		}
+	}
+
+	if err := s.db.Create(&entry).Error; err != nil {
+		return uuid.Nil, err
+	}
+	return entry.ID, nil
+}
+
+// RecordClick stores the result opened from a logged search. Only the first
+// click counts; later ones are ignored.
+func (s *SearchAnalyticsService) RecordClick(searchID uuid.UUID, resultType string, resultID uuid.UUID, position int) error {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"clicked_type": resultType,
+		"clicked_id":   resultID,
+		"clicked_at":   now,
+	}
+	if position > 0 {
+		updates["click_position"] = position
+	}
+
+	result := s.db.Model(&models.SearchQueryLog{}).
+		Where("id = ? AND clicked_at IS NULL", searchID).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		if err := s.db.Model(&models.SearchQueryLog{}).Where("id = ?", searchID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+	}
+	return nil
+}
+
+// StartScheduler aggregates the logs and purges old ones periodically until ctx is cancelled
+func (s *SearchAnalyticsService) StartScheduler(ctx context.Context) {
+	ticker := time.NewTicker(searchAnalyticsInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.AggregatePending(); err != nil {
+			log.Printf("Search analytics aggregation failed: %v", err)
+		} else if purged, err := s.PurgeLogs(); err != nil {
+			log.Printf("Search log purge failed: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d search log entries", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// AggregatePending recomputes every day from the last aggregated one, which
+// may have been incomplete, through today
+func (s *SearchAnalyticsService) AggregatePending() error {
+	var last struct {
+		Day *time.Time
+	}
+	if err := s.db.Model(&models.SearchQueryDailyStat{}).Select("MAX(day) as day").Scan(&last).Error; err != nil {
+		return err
+	}
+	if last.Day == nil {
+		if err := s.db.Model(&models.SearchQueryLog{}).Select("MIN(created_at) as day").Scan(&last).Error; err != nil {
+			return err
+		}
+	}
+	if last.Day == nil {
+		return nil
+	}
+
+	today := utcDay(time.Now())
+	for day := utcDay(*last.Day); !day.After(today); day = day.AddDate(0, 0, 1) {
+		if err := s.Aggregate(day); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Aggregate replaces the statistics of one UTC day with counts from the logs
+func (s *SearchAnalyticsService) Aggregate(day time.Time) error {
+	start := utcDay(day)
+	end := start.AddDate(0, 0, 1)
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("day = ?", start).Delete(&models.SearchQueryDailyStat{}).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`
+			INSERT INTO search_query_daily_stats (day, query, subject_id, searches, zero_results, clicks)
+			SELECT ?::date, query, subject_id, COUNT(*),
+				COUNT(*) FILTER (WHERE result_count = 0), COUNT(clicked_at)
+			FROM search_query_logs
+			WHERE created_at >= ? AND created_at < ?
+			GROUP BY query, subject_id
+		`, start, start, end).Error
+	})
+}
+
+// PurgeLogs deletes logged searches older than the retention period. Their
+// daily statistics are kept.
+func (s *SearchAnalyticsService) PurgeLogs() (int64, error) {
+	result := s.db.Where("created_at < ?", utcDay(time.Now().Add(-s.retention))).Delete(&models.SearchQueryLog{})
+	return result.RowsAffected, result.Error
+}
+
+func utcDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// clickThroughRate is the share of searches followed by a click
+func clickThroughRate(clicks, searches int64) float64 {
+	if searches == 0 {
+		return 0
+	}
+	return float64(clicks) / float64(searches)
+}
+
+// GetReport returns the top queries, the most frequent queries without
+// results and the click-through rate of searches filtered to each subject
+func (s *SearchAnalyticsService) GetReport(opts SearchAnalyticsReportOptions) (*SearchAnalyticsReport, error) {
+	from, to := utcDay(opts.From), utcDay(opts.To)
+	report := &SearchAnalyticsReport{
+		From:              from.Format("2006-01-02"),
+		To:                to.Format("2006-01-02"),
+		TopQueries:        []SearchQueryStat{},
+		ZeroResultQueries: []SearchQueryStat{},
+		Subjects:          []SearchSubjectStat{},
+	}
+
+	stats := func() *gorm.DB {
+		query := s.db.Table("search_query_daily_stats").Where("day BETWEEN ? AND ?", from, to)
+		if opts.SubjectID != nil {
+			query = query.Where("subject_id = ?", *opts.SubjectID)
+		}
+		return query
+	}
+	const sums = "SUM(searches) as searches, SUM(zero_results) as zero_results, SUM(clicks) as clicks"
+
+	var totals struct {
+		Searches    int64
+		ZeroResults int64
+		Clicks      int64
+	}
+	if err := stats().
+		Select("COALESCE(SUM(searches), 0) as searches, COALESCE(SUM(zero_results), 0) as zero_results, COALESCE(SUM(clicks), 0) as clicks").
+		Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+	report.Searches = totals.Searches
+	report.ZeroResults = totals.ZeroResults
+	report.Clicks = totals.Clicks
+	report.ClickThroughRate = clickThroughRate(totals.Clicks, totals.Searches)
+
+	if err := stats().
+		Select("query, " + sums).
+		Group("query").
+		Order("searches DESC, query").
+		Limit(opts.Limit).
+		Scan(&report.TopQueries).Error; err != nil {
+		return nil, err
+	}
+
+	if err := stats().
+		Select("query, " + sums).
+		Group("query").
+		Having("SUM(zero_results) > 0").
+		Order("zero_results DESC, query").
+		Limit(opts.Limit).
+		Scan(&report.ZeroResultQueries).Error; err != nil {
+		return nil, err
+	}
+
+	if err := stats().
+		Select("subjects.id as subject_id, subjects.code, subjects.name_cs, " + sums).
+		Joins("JOIN subjects ON subjects.id = search_query_daily_stats.subject_id").
+		Group("subjects.id").
+		Order("searches DESC").
+		Limit(opts.Limit).
+		Scan(&report.Subjects).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range report.TopQueries {
+		report.TopQueries[i].ClickThroughRate = clickThroughRate(report.TopQueries[i].Clicks, report.TopQueries[i].Searches)
+	}
+	for i := range report.ZeroResultQueries {
+		report.ZeroResultQueries[i].ClickThroughRate = clickThroughRate(report.ZeroResultQueries[i].Clicks, report.ZeroResultQueries[i].Searches)
+	}
+	for i := range report.Subjects {
+		report.Subjects[i].ClickThroughRate = clickThroughRate(report.Subjects[i].Clicks, report.Subjects[i].Searches)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,337 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/P3chys/entoo2-api/internal/config"
+	"github.com/P3chys/entoo2-api/internal/database"
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// The search suite runs against every backend that is configured:
+// TEST_DATABASE_URL enables it with the PostgreSQL backend and TEST_MEILI_URL
+// (with TEST_MEILI_API_KEY) adds Meilisearch. Both need a disposable
+// database, Meilisearch also a disposable instance.
+
+type searchFixture struct {
+	word        string
+	subjectWord string
+	semesterA   models.Semester
+	semesterB   models.Semester
+	subjectA    models.Subject
+	subjectB    models.Subject
+	category    models.DocumentCategory
+	lectureA    models.Document
+	seminarA    models.Document
+	lectureB    models.Document
+}
+
+func TestSearchBackends(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := database.Connect(dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if err := database.RunMigrations(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	backends := map[string]SearchService{
+		SearchBackendPostgres: NewPostgresSearchService(db),
+	}
+	if url := os.Getenv("TEST_MEILI_URL"); url != "" {
+		backends[SearchBackendMeilisearch] = NewMeiliSearchService(&config.Config{
+			MeiliURL:    url,
+			MeiliAPIKey: os.Getenv("TEST_MEILI_API_KEY"),
+		}, db)
+	}
+
+	for name, search := range backends {
+		t.Run(name, func(t *testing.T) {
+			fixture := createSearchFixture(t, db, search)
+			runSearchSuite(t, db, search, fixture)
+		})
+	}
+}
+
+func runSearchSuite(t *testing.T, db *gorm.DB, search SearchService, f *searchFixture) {
+	t.Run("query", func(t *testing.T) {
+		result := mustSearch(t, search, DocumentSearchOptions{Query: f.word})
+		assertHits(t, result, f.lectureA.ID, f.seminarA.ID, f.lectureB.ID)
+	})
+
+	t.Run("semester filter", func(t *testing.T) {
+		result := mustSearch(t, search, DocumentSearchOptions{Query: f.word, SemesterID: f.semesterA.ID.String()})
+		assertHits(t, result, f.lectureA.ID, f.seminarA.ID)
+	})
+
+	t.Run("subject filter", func(t *testing.T) {
+		result := mustSearch(t, search, DocumentSearchOptions{Query: f.word, SubjectID: f.subjectB.ID.String()})
+		assertHits(t, result, f.lectureB.ID)
+	})
+
+	t.Run("category filter", func(t *testing.T) {
+		result := mustSearch(t, search, DocumentSearchOptions{Query: f.word, CategoryID: f.category.ID.String()})
+		assertHits(t, result, f.lectureA.ID)
+	})
+
+	t.Run("category name", func(t *testing.T) {
+		result := mustSearch(t, search, DocumentSearchOptions{Query: f.category.NameEN})
+		assertHits(t, result, f.lectureA.ID)
+	})
+
+	t.Run("facets", func(t *testing.T) {
+		result := mustSearch(t, search, DocumentSearchOptions{Query: f.word, SemesterID: f.semesterA.ID.String()})
+		if got := result.Facets["subject_id"][f.subjectA.ID.String()]; got != 2 {
+			t.Errorf("subject_id facet = %d, want 2", got)
+		}
+		if got := result.Facets["type"]["lecture"]; got != 1 {
+			t.Errorf("type lecture facet = %d, want 1", got)
+		}
+		if got := result.Facets["type"]["seminar"]; got != 1 {
+			t.Errorf("type seminar facet = %d, want 1", got)
+		}
+		if got := result.Facets["mime_family"][MimeFamily("application/pdf")]; got != 1 {
+			t.Errorf("mime_family pdf facet = %d, want 1", got)
+		}
+	})
+
+	t.Run("highlighting", func(t *testing.T) {
+		result := mustSearch(t, search, DocumentSearchOptions{Query: f.word, SubjectID: f.subjectB.ID.String()})
+		if len(result.Hits) != 1 {
+			t.Fatalf("got %d hits, want 1", len(result.Hits))
+		}
+		name := formattedField(result.Hits[0], "original_name")
+		if !strings.Contains(name, "<mark>") {
+			t.Errorf("original_name highlight %q has no <mark>", name)
+		}
+	})
+
+	t.Run("subjects", func(t *testing.T) {
+		result, err := search.SearchSubjects(DocumentSearchOptions{Query: f.subjectWord, Limit: 20})
+		if err != nil {
+			t.Fatalf("search subjects: %v", err)
+		}
+		assertHits(t, result, f.subjectA.ID)
+	})
+
+	t.Run("suggest", func(t *testing.T) {
+		suggestions, err := search.Suggest(f.word[:6], 10)
+		if err != nil {
+			t.Fatalf("suggest: %v", err)
+		}
+		if len(suggestions.Documents) != 3 {
+			t.Errorf("got %d document suggestions, want 3", len(suggestions.Documents))
+		}
+
+		suggestions, err = search.Suggest(f.subjectWord[:6], 10)
+		if err != nil {
+			t.Fatalf("suggest: %v", err)
+		}
+		if len(suggestions.Subjects) != 1 || suggestions.Subjects[0].ID != f.subjectA.ID.String() {
+			t.Errorf("subject suggestions = %+v, want %s", suggestions.Subjects, f.subjectA.ID)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if err := search.DeleteDocument(f.lectureB.ID.String()); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		result := mustSearch(t, search, DocumentSearchOptions{Query: f.word})
+		assertHits(t, result, f.lectureA.ID, f.seminarA.ID)
+	})
+
+	// Trashing a document while its extraction finishes queues a delete and
+	// then an index; the index of the trashed row must not bring it back
+	t.Run("delete then index", func(t *testing.T) {
+		if err := db.Delete(&f.seminarA).Error; err != nil {
+			t.Fatalf("trash: %v", err)
+		}
+		outbox := NewSearchOutbox(db, search)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := outbox.Enqueue(tx, models.SearchEntityDocument, f.seminarA.ID, models.SearchOperationDelete); err != nil {
+				return err
+			}
+			return outbox.Enqueue(tx, models.SearchEntityDocument, f.seminarA.ID, models.SearchOperationIndex)
+		})
+		if err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+		drainOutbox(t, db, outbox, f.seminarA.ID)
+
+		result := mustSearch(t, search, DocumentSearchOptions{Query: f.word})
+		assertHits(t, result, f.lectureA.ID)
+	})
+}
+
+// drainOutbox dispatches outbox batches until no change of entityID is left
+func drainOutbox(t *testing.T, db *gorm.DB, outbox *SearchOutbox, entityID uuid.UUID) {
+	t.Helper()
+	for attempt := 0; attempt < 20; attempt++ {
+		if _, err := outbox.dispatchBatch(); err != nil {
+			t.Fatalf("dispatch: %v", err)
+		}
+		var pending int64
+		if err := db.Model(&models.SearchOutboxEntry{}).Where("entity_id = ?", entityID).Count(&pending).Error; err != nil {
+			t.Fatalf("count outbox entries: %v", err)
+		}
+		if pending == 0 {
+			return
+		}
+	}
+	t.Fatalf("outbox changes of %s were not dispatched", entityID)
+}
+
+// createSearchFixture stores two semesters with a subject each, a category
+// and three documents, indexes them and removes everything after the test.
+// All searchable words are random, so runs never see each other's data.
+func createSearchFixture(t *testing.T, db *gorm.DB, search SearchService) *searchFixture {
+	t.Helper()
+	f := &searchFixture{word: randomWord(), subjectWord: randomWord()}
+
+	user := models.User{Email: randomWord() + "@example.com", PasswordHash: "-", DisplayName: "Search Test"}
+	f.semesterA = models.Semester{NameCS: "Semestr A " + f.word}
+	f.semesterB = models.Semester{NameCS: "Semestr B " + f.word}
+	for _, record := range []interface{}{&user, &f.semesterA, &f.semesterB} {
+		if err := db.Create(record).Error; err != nil {
+			t.Fatalf("create fixture: %v", err)
+		}
+	}
+
+	f.subjectA = models.Subject{SemesterID: f.semesterA.ID, Code: randomCode(), NameCS: "Předmět " + f.subjectWord}
+	f.subjectB = models.Subject{SemesterID: f.semesterB.ID, Code: randomCode(), NameCS: "Předmět B"}
+	for _, subject := range []*models.Subject{&f.subjectA, &f.subjectB} {
+		if err := db.Omit("Teachers", "Requisites").Create(subject).Error; err != nil {
+			t.Fatalf("create subject: %v", err)
+		}
+	}
+
+	f.category = models.DocumentCategory{
+		SubjectID: f.subjectA.ID,
+		Type:      "lecture",
+		NameCS:    "Kategorie",
+		NameEN:    randomWord(),
+		CreatedBy: user.ID,
+	}
+	if err := db.Create(&f.category).Error; err != nil {
+		t.Fatalf("create category: %v", err)
+	}
+
+	newDocument := func(subjectID uuid.UUID, docType, name, mimeType string) models.Document {
+		return models.Document{
+			SubjectID:        subjectID,
+			UploadedBy:       user.ID,
+			Type:             docType,
+			Filename:         name,
+			OriginalName:     name,
+			FileSize:         1024,
+			MimeType:         mimeType,
+			MinIOPath:        "test/" + uuid.NewString(),
+			ContentText:      "Poznámky " + f.word,
+			ExtractionStatus: models.ExtractionStatusDone,
+		}
+	}
+	f.lectureA = newDocument(f.subjectA.ID, "lecture", f.word+" prednaska.pdf", "application/pdf")
+	f.lectureA.CategoryID = &f.category.ID
+	f.seminarA = newDocument(f.subjectA.ID, "seminar", f.word+" cviceni.txt", "text/plain")
+	f.lectureB = newDocument(f.subjectB.ID, "lecture", f.word+" skripta.txt", "text/plain")
+	documents := []*models.Document{&f.lectureA, &f.seminarA, &f.lectureB}
+	for _, doc := range documents {
+		if err := db.Create(doc).Error; err != nil {
+			t.Fatalf("create document: %v", err)
+		}
+	}
+
+	t.Cleanup(func() {
+		for _, doc := range documents {
+			search.DeleteDocument(doc.ID.String())
+		}
+		search.DeleteSubject(f.subjectA.ID.String())
+		search.DeleteSubject(f.subjectB.ID.String())
+		db.Unscoped().Where("subject_id IN ?", []uuid.UUID{f.subjectA.ID, f.subjectB.ID}).Delete(&models.Document{})
+		db.Delete(&f.category)
+		db.Select("Teachers", "Requisites").Delete(&f.subjectA)
+		db.Select("Teachers", "Requisites").Delete(&f.subjectB)
+		db.Delete(&f.semesterA)
+		db.Delete(&f.semesterB)
+		db.Delete(&user)
+	})
+
+	ids := []uuid.UUID{f.lectureA.ID, f.seminarA.ID, f.lectureB.ID}
+	if err := search.IndexDocumentsByID(ids); err != nil {
+		t.Fatalf("index documents: %v", err)
+	}
+	if err := search.IndexSubjectsByID([]uuid.UUID{f.subjectA.ID, f.subjectB.ID}); err != nil {
+		t.Fatalf("index subjects: %v", err)
+	}
+	return f
+}
+
+func mustSearch(t *testing.T, search SearchService, opts DocumentSearchOptions) *SearchResult {
+	t.Helper()
+	opts.Limit = 20
+	result, err := search.Search(opts)
+	if err != nil {
+		t.Fatalf("search %+v: %v", opts, err)
+	}
+	return result
+}
+
+// assertHits checks that the hits are exactly the given records, in any order
+func assertHits(t *testing.T, result *SearchResult, want ...uuid.UUID) {
+	t.Helper()
+	got := make(map[string]bool, len(result.Hits))
+	for _, raw := range result.Hits {
+		hit, ok := raw.(map[string]interface{})
+		if !ok {
+			t.Fatalf("hit has type %T", raw)
+		}
+		got[hitString(hit, "id")] = true
+	}
+	if len(got) != len(want) || result.Total != int64(len(want)) {
+		t.Errorf("got %d hits (total %d), want %d", len(got), result.Total, len(want))
+	}
+	for _, id := range want {
+		if !got[id.String()] {
+			t.Errorf("missing hit %s", id)
+		}
+	}
+}
+
+// formattedField returns a highlighted attribute. Meilisearch decodes
+// _formatted into a generic map; the PostgreSQL backend builds it directly.
+func formattedField(raw interface{}, field string) string {
+	hit, _ := raw.(map[string]interface{})
+	switch formatted := hit["_formatted"].(type) {
+	case map[string]interface{}:
+		return fmt.Sprint(formatted[field])
+	case map[string]string:
+		return formatted[field]
+	}
+	return ""
+}
+
+// randomWord returns a lowercase word unlikely to appear in any other data
+func randomWord() string {
+	var b strings.Builder
+	b.WriteString("zq")
+	for _, c := range strings.ReplaceAll(uuid.NewString(), "-", "")[:10] {
+		if c >= '0' && c <= '9' {
+			c = 'g' + (c - '0')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func randomCode() string {
+	return strings.ToUpper(randomWord()[2:8])
+}
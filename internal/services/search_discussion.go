@@ -0,0 +1,292 @@
+package services
+
+import (
+	"time"
+
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/google/uuid"
+	"github.com/meilisearch/meilisearch-go"
+	"gorm.io/gorm"
+)
+
+const (
+	questionsIndex = "questions"
+	commentsIndex  = "comments"
+)
+
+// Attributes that can be used in filters of the questions and comments indexes
+var discussionFilterableAttributes = []string{"subject_id", "semester_id", "author_id", "created_at_ts"}
+
+// SearchAnswer is an answer stored inside its question's search record
+type SearchAnswer struct {
+	ID         string `json:"id"`
+	Content    string `json:"content"`
+	AuthorID   string `json:"author_id"`
+	AuthorName string `json:"author_name"`
+}
+
+// SearchQuestion is the record stored in the questions index. Author fields
+// are left empty for anonymous questions.
+type SearchQuestion struct {
+	ID          string         `json:"id"`
+	SubjectID   string         `json:"subject_id"`
+	SubjectCode string         `json:"subject_code"`
+	SubjectName string         `json:"subject_name"`
+	SemesterID  string         `json:"semester_id"`
+	Content     string         `json:"content"`
+	IsAnonymous bool           `json:"is_anonymous"`
+	AuthorID    string         `json:"author_id,omitempty"`
+	AuthorName  string         `json:"author_name,omitempty"`
+	Answers     []SearchAnswer `json:"answers"`
+	AnswerCount int            `json:"answer_count"`
+	CreatedAt   string         `json:"created_at"`
+	CreatedAtTS int64          `json:"created_at_ts"`
+}
+
+// SearchComment is the record stored in the comments index. Author fields
+// are left empty for anonymous comments.
+type SearchComment struct {
+	ID          string `json:"id"`
+	SubjectID   string `json:"subject_id"`
+	SubjectCode string `json:"subject_code"`
+	SubjectName string `json:"subject_name"`
+	SemesterID  string `json:"semester_id"`
+	Content     string `json:"content"`
+	IsAnonymous bool   `json:"is_anonymous"`
+	AuthorID    string `json:"author_id,omitempty"`
+	AuthorName  string `json:"author_name,omitempty"`
+	CreatedAt   string `json:"created_at"`
+	CreatedAtTS int64  `json:"created_at_ts"`
+}
+
+// NewSearchQuestion converts a question with its Subject, User and Answers.User relations loaded
+func NewSearchQuestion(question models.Question) SearchQuestion {
+	record := SearchQuestion{
+		ID:          question.ID.String(),
+		SubjectID:   question.SubjectID.String(),
+		SubjectCode: question.Subject.Code,
+		SubjectName: question.Subject.NameCS,
+		SemesterID:  question.Subject.SemesterID.String(),
+		Content:     question.Content,
+		IsAnonymous: question.IsAnonymous,
+		Answers:     make([]SearchAnswer, 0, len(question.Answers)),
+		AnswerCount: len(question.Answers),
+		CreatedAt:   question.CreatedAt.UTC().Format(time.RFC3339),
+		CreatedAtTS: question.CreatedAt.Unix(),
+	}
+	if !question.IsAnonymous {
+		record.AuthorID = question.UserID.String()
+		record.AuthorName = question.User.DisplayName
+	}
+	for _, answer := range question.Answers {
+		record.Answers = append(record.Answers, SearchAnswer{
+			ID:         answer.ID.String(),
+			Content:    answer.Content,
+			AuthorID:   answer.UserID.String(),
+			AuthorName: answer.User.DisplayName,
+		})
+	}
+	return record
+}
+
+// NewSearchComment converts a comment with its User relation and subject loaded
+func NewSearchComment(comment models.Comment, subject models.Subject) SearchComment {
+	record := SearchComment{
+		ID:          comment.ID.String(),
+		SubjectID:   comment.SubjectID.String(),
+		SubjectCode: subject.Code,
+		SubjectName: subject.NameCS,
+		SemesterID:  subject.SemesterID.String(),
+		Content:     comment.Content,
+		IsAnonymous: comment.IsAnonymous,
+		CreatedAt:   comment.CreatedAt.UTC().Format(time.RFC3339),
+		CreatedAtTS: comment.CreatedAt.Unix(),
+	}
+	if !comment.IsAnonymous {
+		record.AuthorID = comment.UserID.String()
+		record.AuthorName = comment.User.DisplayName
+	}
+	return record
+}
+
+func questionIndexSettings() *meilisearch.Settings {
+	return &meilisearch.Settings{
+		FilterableAttributes: discussionFilterableAttributes,
+		SortableAttributes:   []string{"created_at_ts"},
+		SearchableAttributes: []string{"content", "answers.content", "subject_code", "subject_name"},
+	}
+}
+
+func commentIndexSettings() *meilisearch.Settings {
+	return &meilisearch.Settings{
+		FilterableAttributes: discussionFilterableAttributes,
+		SortableAttributes:   []string{"created_at_ts"},
+		SearchableAttributes: []string{"content", "subject_code", "subject_name"},
+	}
+}
+
+// IndexQuestion (re)indexes a question together with its answers
+func (s *MeiliSearchService) IndexQuestion(questionID uuid.UUID) error {
+	return s.IndexQuestionsByID([]uuid.UUID{questionID})
+}
+
+// IndexQuestionsByID loads the given questions and replaces their search
+// records. Questions that are gone lose their record.
+func (s *MeiliSearchService) IndexQuestionsByID(ids []uuid.UUID) error {
+	records, err := s.questionRecords(ids)
+	if err != nil {
+		return err
+	}
+	found := make([]string, len(records))
+	for i, record := range records {
+		found[i] = record.ID
+	}
+	return s.replaceRecords(questionsIndex, ids, records, found)
+}
+
+// questionRecords loads the search records of the given questions
+func (s *MeiliSearchService) questionRecords(ids []uuid.UUID) ([]SearchQuestion, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var questions []models.Question
+	if err := s.db.Preload("Subject").Preload("User").
+		Preload("Answers", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at asc")
+		}).
+		Preload("Answers.User").
+		Where("id IN ?", ids).Find(&questions).Error; err != nil {
+		return nil, err
+	}
+
+	records := make([]SearchQuestion, len(questions))
+	for i, question := range questions {
+		records[i] = NewSearchQuestion(question)
+	}
+	return records, nil
+}
+
+func (s *MeiliSearchService) DeleteQuestion(questionID string) error {
+	return s.waitForTask(s.client.Index(questionsIndex).DeleteDocument(questionID))
+}
+
+// IndexComment (re)indexes a comment
+func (s *MeiliSearchService) IndexComment(commentID uuid.UUID) error {
+	return s.IndexCommentsByID([]uuid.UUID{commentID})
+}
+
+// IndexCommentsByID loads the given comments and replaces their search
+// records. Comments that are gone lose their record.
+func (s *MeiliSearchService) IndexCommentsByID(ids []uuid.UUID) error {
+	records, err := s.commentRecords(ids)
+	if err != nil {
+		return err
+	}
+	found := make([]string, len(records))
+	for i, record := range records {
+		found[i] = record.ID
+	}
+	return s.replaceRecords(commentsIndex, ids, records, found)
+}
+
+// commentRecords loads the search records of the given comments
+func (s *MeiliSearchService) commentRecords(ids []uuid.UUID) ([]SearchComment, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var comments []models.Comment
+	if err := s.db.Preload("User").Where("id IN ?", ids).Find(&comments).Error; err != nil {
+		return nil, err
+	}
+	if len(comments) == 0 {
+		return nil, nil
+	}
+
+	// Comment has no Subject relation, so subjects are loaded separately
+	subjectIDs := make([]uuid.UUID, 0, len(comments))
+	for _, comment := range comments {
+		subjectIDs = append(subjectIDs, comment.SubjectID)
+	}
+	var subjects []models.Subject
+	if err := s.db.Where("id IN ?", subjectIDs).Find(&subjects).Error; err != nil {
+		return nil, err
+	}
+	subjectsByID := make(map[uuid.UUID]models.Subject, len(subjects))
+	for _, subject := range subjects {
+		subjectsByID[subject.ID] = subject
+	}
+
+	records := make([]SearchComment, len(comments))
+	for i, comment := range comments {
+		records[i] = NewSearchComment(comment, subjectsByID[comment.SubjectID])
+	}
+	return records, nil
+}
+
+func (s *MeiliSearchService) DeleteComment(commentID string) error {
+	return s.waitForTask(s.client.Index(commentsIndex).DeleteDocument(commentID))
+}
+
+// SearchQuestions searches questions and their answers. Only the query,
+// subject, semester, date range and paging options apply.
+func (s *MeiliSearchService) SearchQuestions(opts DocumentSearchOptions) (*SearchResult, error) {
+	return s.searchDiscussion(questionsIndex, []string{"content", "answers.content"}, opts)
+}
+
+// SearchComments searches subject comments. Only the query, subject,
+// semester, date range and paging options apply.
+func (s *MeiliSearchService) SearchComments(opts DocumentSearchOptions) (*SearchResult, error) {
+	return s.searchDiscussion(commentsIndex, []string{"content"}, opts)
+}
+
+func (s *MeiliSearchService) searchDiscussion(index string, highlight []string, opts DocumentSearchOptions) (*SearchResult, error) {
+	filter := NewSearchFilter(discussionFilterableAttributes)
+	if opts.SubjectID != "" {
+		filter.Eq("subject_id", opts.SubjectID)
+	}
+	if opts.SemesterID != "" {
+		filter.Eq("semester_id", opts.SemesterID)
+	}
+	if opts.CreatedFrom != nil {
+		filter.Gte("created_at_ts", opts.CreatedFrom.Unix())
+	}
+	if opts.CreatedTo != nil {
+		filter.Lte("created_at_ts", opts.CreatedTo.Unix())
+	}
+	filterStr, err := filter.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	request := &meilisearch.SearchRequest{
+		Limit:                 int64(opts.Limit),
+		Offset:                int64(opts.Offset),
+		AttributesToHighlight: highlight,
+		HighlightPreTag:       "<mark>",
+		HighlightPostTag:      "</mark>",
+		AttributesToCrop:      highlight,
+		CropLength:            200,
+	}
+	if filterStr != "" {
+		request.Filter = filterStr
+	}
+	switch opts.Sort {
+	case SearchSortDateDesc:
+		request.Sort = []string{"created_at_ts:desc"}
+	case SearchSortDateAsc:
+		request.Sort = []string{"created_at_ts:asc"}
+	}
+
+	// Disable fuzzy matching for exact searches
+	if opts.ExactMatch {
+		request.MatchingStrategy = "all"
+	}
+
+	resp, err := s.client.Index(index).Search(opts.Query, request)
+	if err != nil {
+		return nil, err
+	}
+	return newSearchResult(resp, opts.Limit, opts.Offset), nil
+}
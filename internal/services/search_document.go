@@ -0,0 +1,162 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/P3chys/entoo2-api/internal/models"
+)
+
+// SearchDocument is the record stored in the documents index. Its schema is
+// independent of models.Document so that storage paths and nested relations
+// never reach the search engine.
+type SearchDocument struct {
+	ID               string `json:"id"`
+	SubjectID        string `json:"subject_id"`
+	SubjectCode      string `json:"subject_code"`
+	SubjectName      string `json:"subject_name"`
+	SemesterID       string `json:"semester_id"`
+	SemesterName     string `json:"semester_name"`
+	OfferingID       string `json:"offering_id,omitempty"`
+	AcademicYearID   string `json:"academic_year_id,omitempty"`
+	Term             string `json:"term,omitempty"`
+	CategoryID       string `json:"category_id,omitempty"`
+	CategoryNameCS   string `json:"category_name_cs,omitempty"`
+	CategoryNameEN   string `json:"category_name_en,omitempty"`
+	Type             string `json:"type"`
+	OriginalName     string `json:"original_name"`
+	MimeType         string `json:"mime_type"`
+	MimeFamily       string `json:"mime_family"`
+	FileSize         int64  `json:"file_size"`
+	UploadedBy       string `json:"uploaded_by"`
+	UploaderName     string `json:"uploader_name"`
+	ExtractionStatus string `json:"extraction_status"`
+	CreatedAt        string `json:"created_at"`
+	CreatedAtTS      int64  `json:"created_at_ts"`
+	ContentText      string `json:"content_text"`
+}
+
+// NewSearchDocument converts a document with its Subject (and Semester),
+// Uploader, Category and Offering (and Term) relations loaded
+func NewSearchDocument(doc models.Document) SearchDocument {
+	record := SearchDocument{
+		ID:               doc.ID.String(),
+		SubjectID:        doc.SubjectID.String(),
+		SubjectCode:      doc.Subject.Code,
+		SubjectName:      doc.Subject.NameCS,
+		SemesterID:       doc.Subject.SemesterID.String(),
+		SemesterName:     doc.Subject.Semester.NameCS,
+		Type:             doc.Type,
+		OriginalName:     doc.OriginalName,
+		MimeType:         doc.MimeType,
+		MimeFamily:       MimeFamily(doc.MimeType),
+		FileSize:         doc.FileSize,
+		UploadedBy:       doc.UploadedBy.String(),
+		UploaderName:     doc.Uploader.DisplayName,
+		ExtractionStatus: string(doc.ExtractionStatus),
+		CreatedAt:        doc.CreatedAt.UTC().Format(time.RFC3339),
+		CreatedAtTS:      doc.CreatedAt.Unix(),
+		ContentText:      doc.ContentText,
+	}
+	if doc.CategoryID != nil {
+		record.CategoryID = doc.CategoryID.String()
+	}
+	if doc.OfferingID != nil {
+		record.OfferingID = doc.OfferingID.String()
+	}
+	if doc.Offering != nil && doc.Offering.Term != nil {
+		record.AcademicYearID = doc.Offering.Term.AcademicYearID.String()
+		record.Term = string(doc.Offering.Term.Kind)
+	}
+	if doc.Category != nil {
+		record.CategoryNameCS = doc.Category.NameCS
+		record.CategoryNameEN = doc.Category.NameEN
+	}
+	return record
+}
+
+// SearchFilter builds a Meilisearch filter expression from typed conditions.
+// Attributes are checked against the filterable attributes of the index and
+// string values are always quoted, so request input cannot alter the expression.
+type SearchFilter struct {
+	allowed map[string]bool
+	clauses []string
+	err     error
+}
+
+func NewSearchFilter(filterableAttributes []string) *SearchFilter {
+	allowed := make(map[string]bool, len(filterableAttributes))
+	for _, attr := range filterableAttributes {
+		allowed[attr] = true
+	}
+	return &SearchFilter{allowed: allowed}
+}
+
+// Eq adds `attr = "value"`
+func (f *SearchFilter) Eq(attr, value string) *SearchFilter {
+	if f.check(attr) {
+		f.clauses = append(f.clauses, attr+" = "+quoteFilterValue(value))
+	}
+	return f
+}
+
+// In adds `attr IN ["a", "b"]`; the list must not be empty
+func (f *SearchFilter) In(attr string, values []string) *SearchFilter {
+	if !f.check(attr) {
+		return f
+	}
+	if len(values) == 0 {
+		f.err = fmt.Errorf("empty value list for %q", attr)
+		return f
+	}
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = quoteFilterValue(value)
+	}
+	f.clauses = append(f.clauses, attr+" IN ["+strings.Join(quoted, ", ")+"]")
+	return f
+}
+
+// Gte adds `attr >= value`
+func (f *SearchFilter) Gte(attr string, value int64) *SearchFilter {
+	if f.check(attr) {
+		f.clauses = append(f.clauses, fmt.Sprintf("%s >= %d", attr, value))
+	}
+	return f
+}
+
+// Lte adds `attr <= value`
+func (f *SearchFilter) Lte(attr string, value int64) *SearchFilter {
+	if f.check(attr) {
+		f.clauses = append(f.clauses, fmt.Sprintf("%s <= %d", attr, value))
+	}
+	return f
+}
+
+// Build joins all conditions with AND. An empty string means no filter.
+func (f *SearchFilter) Build() (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+	return strings.Join(f.clauses, " AND "), nil
+}
+
+func (f *SearchFilter) check(attr string) bool {
+	if f.err != nil {
+		return false
+	}
+	if !f.allowed[attr] {
+		f.err = fmt.Errorf("attribute %q is not filterable", attr)
+		return false
+	}
+	return true
+}
+
+// quoteFilterValue quotes a string for a Meilisearch filter, escaping
+// backslashes and double quotes
+func quoteFilterValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return `"` + value + `"`
+}
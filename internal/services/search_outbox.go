@@ -0,0 +1,346 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	outboxPollInterval = 2 * time.Second
+	outboxBatchSize    = 100
+	outboxBaseBackoff  = 5 * time.Second
+	outboxMaxBackoff   = 30 * time.Minute
+
+	// outboxLockKey is the advisory lock held while claiming a batch, so two
+	// instances never claim the same entries
+	outboxLockKey = 4210037
+	// outboxClaimTimeout is how long a claimed batch may take before other
+	// dispatchers take it over
+	outboxClaimTimeout = 15 * time.Minute
+)
+
+// SearchOutboxStats describes the dispatch backlog. Lag is the age of the
+// oldest pending change; Dispatched and Failed count since startup.
+type SearchOutboxStats struct {
+	Pending         int64      `json:"pending"`
+	Retrying        int64      `json:"retrying"`
+	OldestPendingAt *time.Time `json:"oldest_pending_at"`
+	LagSeconds      float64    `json:"lag_seconds"`
+	Dispatched      int64      `json:"dispatched"`
+	Failed          int64      `json:"failed"`
+	LastDispatchAt  *time.Time `json:"last_dispatch_at"`
+	LastError       string     `json:"last_error,omitempty"`
+}
+
+// SearchOutbox keeps the search index consistent with the database. Changes
+// are recorded in the search_outbox_entries table together with the database
+// change and a background dispatcher applies them with retries.
+type SearchOutbox struct {
+	db     *gorm.DB
+	search SearchService
+	wake   chan struct{}
+
+	mu             sync.Mutex
+	dispatched     int64
+	failed         int64
+	lastDispatchAt time.Time
+	lastError      string
+}
+
+func NewSearchOutbox(db *gorm.DB, search SearchService) *SearchOutbox {
+	return &SearchOutbox{
+		db:     db,
+		search: search,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Enqueue records a search index change. It is meant to run in the same
+// transaction as the database change, followed by Notify after commit.
+func (o *SearchOutbox) Enqueue(tx *gorm.DB, entityType models.SearchEntityType, entityID uuid.UUID, operation models.SearchOperation) error {
+	return tx.Create(&models.SearchOutboxEntry{
+		EntityType:    entityType,
+		EntityID:      entityID,
+		Operation:     operation,
+		NextAttemptAt: time.Now(),
+	}).Error
+}
+
+// EnqueueSubjectContent records an index change for every document,
+// question and comment of a subject, whose search records carry the
+// subject's code, name and semester
+func (o *SearchOutbox) EnqueueSubjectContent(tx *gorm.DB, subjectID uuid.UUID) error {
+	if err := enqueueSelected(tx, models.SearchEntityDocument,
+		"SELECT id FROM documents WHERE subject_id = ? AND deleted_at IS NULL", subjectID); err != nil {
+		return err
+	}
+	if err := enqueueSelected(tx, models.SearchEntityQuestion,
+		"SELECT id FROM questions WHERE subject_id = ?", subjectID); err != nil {
+		return err
+	}
+	return enqueueSelected(tx, models.SearchEntityComment,
+		"SELECT id FROM comments WHERE subject_id = ?", subjectID)
+}
+
+// EnqueueCategoryDocuments records an index change for every document of a
+// category, whose search records carry the category names
+func (o *SearchOutbox) EnqueueCategoryDocuments(tx *gorm.DB, categoryID uuid.UUID) error {
+	return enqueueSelected(tx, models.SearchEntityDocument,
+		"SELECT id FROM documents WHERE category_id = ? AND deleted_at IS NULL", categoryID)
+}
+
+// enqueueSelected records an index change for each ID returned by query
+func enqueueSelected(tx *gorm.DB, entityType models.SearchEntityType, query string, args ...interface{}) error {
+	now := time.Now()
+	return tx.Exec(`
+		INSERT INTO search_outbox_entries (entity_type, entity_id, operation, attempts, next_attempt_at, created_at)
+		SELECT ?, q.id, ?, 0, ?, ? FROM (`+query+`) q`,
+		append([]interface{}{entityType, models.SearchOperationIndex, now, now}, args...)...).Error
+}
+
+// Notify wakes up the dispatcher, e.g. after a transaction with Enqueue committed
+func (o *SearchOutbox) Notify() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start launches the dispatcher. It stops when ctx is cancelled; pending
+// changes stay in the table for the next start.
+func (o *SearchOutbox) Start(ctx context.Context) {
+	go o.dispatcher(ctx)
+}
+
+func (o *SearchOutbox) dispatcher(ctx context.Context) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		processed, err := o.dispatchBatch()
+		if err != nil {
+			log.Printf("Search outbox dispatcher error: %v", err)
+		}
+		if processed > 0 && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-o.wake:
+		case <-time.After(outboxPollInterval):
+		}
+	}
+}
+
+// outboxChange is the latest pending change of one entity
+type outboxChange struct {
+	entityType models.SearchEntityType
+	entityID   uuid.UUID
+	operation  models.SearchOperation
+	lastID     int64
+	attempts   int
+}
+
+// dispatchBatch applies the due changes of up to outboxBatchSize entries and
+// returns how many entries it handled. The entries are claimed in a short
+// transaction, so no lock is held while the search backend is called.
+func (o *SearchOutbox) dispatchBatch() (int, error) {
+	entries, err := o.claimBatch()
+	if err != nil || len(entries) == 0 {
+		return 0, err
+	}
+
+	for _, change := range latestChanges(entries) {
+		if err := o.apply(change); err != nil {
+			o.recordResult(err)
+			if err := o.retryLater(change, err); err != nil {
+				return len(entries), err
+			}
+			continue
+		}
+		o.recordResult(nil)
+
+		// Entries added after this batch was claimed have higher IDs and stay
+		if err := o.db.Where("entity_type = ? AND entity_id = ? AND id <= ?",
+			change.entityType, change.entityID, change.lastID).
+			Delete(&models.SearchOutboxEntry{}).Error; err != nil {
+			return len(entries), err
+		}
+	}
+	return len(entries), nil
+}
+
+// claimBatch marks up to outboxBatchSize due entries as claimed and returns
+// them. Entities with a change claimed by another dispatcher are skipped, so
+// the changes of one entity are still applied in order; a claim that expires
+// because its dispatcher died is taken over.
+func (o *SearchOutbox) claimBatch() ([]models.SearchOutboxEntry, error) {
+	var entries []models.SearchOutboxEntry
+	err := o.db.Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", outboxLockKey).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			// Another instance is claiming
+			return nil
+		}
+
+		now := time.Now()
+		if err := tx.Where("next_attempt_at <= ?", now).
+			Where(`NOT EXISTS (SELECT 1 FROM search_outbox_entries c
+				WHERE c.entity_type = search_outbox_entries.entity_type
+				AND c.entity_id = search_outbox_entries.entity_id
+				AND c.claimed_until >= ?)`, now).
+			Order("id").Limit(outboxBatchSize).Find(&entries).Error; err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+
+		ids := make([]int64, len(entries))
+		for i, entry := range entries {
+			ids[i] = entry.ID
+		}
+		return tx.Model(&models.SearchOutboxEntry{}).Where("id IN ?", ids).
+			Update("claimed_until", now.Add(outboxClaimTimeout)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// latestChanges collapses entries to the last change per entity. Index
+// operations read the current row, so earlier changes need not be replayed.
+func latestChanges(entries []models.SearchOutboxEntry) []outboxChange {
+	type entityKey struct {
+		entityType models.SearchEntityType
+		entityID   uuid.UUID
+	}
+
+	var changes []outboxChange
+	positions := make(map[entityKey]int)
+	for _, entry := range entries {
+		key := entityKey{entry.EntityType, entry.EntityID}
+		i, ok := positions[key]
+		if !ok {
+			positions[key] = len(changes)
+			changes = append(changes, outboxChange{entityType: entry.EntityType, entityID: entry.EntityID})
+			i = len(changes) - 1
+		}
+		// Entries are ordered by ID, so the last one wins
+		changes[i].operation = entry.Operation
+		changes[i].lastID = entry.ID
+		if entry.Attempts > changes[i].attempts {
+			changes[i].attempts = entry.Attempts
+		}
+	}
+	return changes
+}
+
+// apply performs one change. Both operations are idempotent.
+func (o *SearchOutbox) apply(change outboxChange) error {
+	id := change.entityID
+	switch change.entityType {
+	case models.SearchEntityDocument:
+		if change.operation == models.SearchOperationDelete {
+			return o.search.DeleteDocument(id.String())
+		}
+		return o.search.IndexDocumentsByID([]uuid.UUID{id})
+	case models.SearchEntityQuestion:
+		if change.operation == models.SearchOperationDelete {
+			return o.search.DeleteQuestion(id.String())
+		}
+		return o.search.IndexQuestion(id)
+	case models.SearchEntityComment:
+		if change.operation == models.SearchOperationDelete {
+			return o.search.DeleteComment(id.String())
+		}
+		return o.search.IndexComment(id)
+	case models.SearchEntitySubject:
+		if change.operation == models.SearchOperationDelete {
+			return o.search.DeleteSubject(id.String())
+		}
+		return o.search.IndexSubjectsByID([]uuid.UUID{id})
+	}
+
+	log.Printf("Dropping search outbox change for unknown entity type %q", change.entityType)
+	return nil
+}
+
+// retryLater schedules the entity's entries for another attempt with exponential backoff
+func (o *SearchOutbox) retryLater(change outboxChange, cause error) error {
+	backoff := outboxBaseBackoff << uint(change.attempts)
+	if backoff <= 0 || backoff > outboxMaxBackoff {
+		backoff = outboxMaxBackoff
+	}
+	log.Printf("Search outbox: %s %s %s failed (attempt %d): %v",
+		change.operation, change.entityType, change.entityID, change.attempts+1, cause)
+
+	return o.db.Model(&models.SearchOutboxEntry{}).
+		Where("entity_type = ? AND entity_id = ? AND id <= ?", change.entityType, change.entityID, change.lastID).
+		Updates(map[string]interface{}{
+			"attempts":        change.attempts + 1,
+			"next_attempt_at": time.Now().Add(backoff),
+			"claimed_until":   nil,
+			"last_error":      cause.Error(),
+		}).Error
+}
+
+func (o *SearchOutbox) recordResult(err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err != nil {
+		o.failed++
+		o.lastError = err.Error()
+		return
+	}
+	o.dispatched++
+	o.lastDispatchAt = time.Now()
+}
+
+// Stats returns the backlog size and lag of the outbox
+func (o *SearchOutbox) Stats() (*SearchOutboxStats, error) {
+	var row struct {
+		Pending  int64
+		Retrying int64
+		Oldest   *time.Time
+	}
+	if err := o.db.Model(&models.SearchOutboxEntry{}).
+		Select("count(*) AS pending, count(*) FILTER (WHERE attempts > 0) AS retrying, min(created_at) AS oldest").
+		Scan(&row).Error; err != nil {
+		return nil, err
+	}
+
+	stats := &SearchOutboxStats{
+		Pending:         row.Pending,
+		Retrying:        row.Retrying,
+		OldestPendingAt: row.Oldest,
+	}
+	if row.Oldest != nil {
+		stats.LagSeconds = time.Since(*row.Oldest).Seconds()
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	stats.Dispatched = o.dispatched
+	stats.Failed = o.failed
+	stats.LastError = o.lastError
+	if !o.lastDispatchAt.IsZero() {
+		lastDispatchAt := o.lastDispatchAt
+		stats.LastDispatchAt = &lastDispatchAt
+	}
+	return stats, nil
+}
@@ -0,0 +1,762 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/google/uuid"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// textSearchConfig is the text search configuration created by the
+	// migrations: the simple dictionary behind unaccent, so "zkouska" also
+	// finds "zkouška". PostgreSQL has no Czech stemmer.
+	textSearchConfig = "entoo_cs"
+
+	// maxIndexedTextLength keeps extracted text below the tsvector size limit
+	maxIndexedTextLength = 300000
+
+	headlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=\" … \""
+	titleHeadline   = "StartSel=<mark>, StopSel=</mark>, HighlightAll=true"
+)
+
+// Statements computing the search_vector of each table, completed with a WHERE
+// clause on the alias t
+const (
+	documentVectorUpdate = `
+		UPDATE documents t SET search_vector =
+			setweight(to_tsvector(@config, coalesce(t.original_name, '')), 'A') ||
+			setweight(to_tsvector(@config, left(coalesce(t.content_text, ''), @maxLength)), 'B') ||
+			setweight(to_tsvector(@config,
+				coalesce((SELECT s.code || ' ' || s.name_cs FROM subjects s WHERE s.id = t.subject_id), '') || ' ' ||
+				coalesce((SELECT c.name_cs || ' ' || c.name_en FROM document_categories c WHERE c.id = t.category_id), '')
+			), 'C')`
+	subjectVectorUpdate = `
+		UPDATE subjects t SET search_vector =
+			setweight(to_tsvector(@config, coalesce(t.code, '') || ' ' || coalesce(t.name_cs, '')), 'A') ||
+			setweight(to_tsvector(@config, coalesce(t.description_cs, '')), 'B')`
+	questionVectorUpdate = `
+		UPDATE questions t SET search_vector =
+			setweight(to_tsvector(@config, t.content), 'A') ||
+			setweight(to_tsvector(@config, coalesce(
+				(SELECT string_agg(a.content, ' ') FROM answers a WHERE a.question_id = t.id), ''
+			)), 'B')`
+	commentVectorUpdate = `
+		UPDATE comments t SET search_vector = to_tsvector(@config, t.content)`
+)
+
+func vectorParams(ids []uuid.UUID) map[string]interface{} {
+	return map[string]interface{}{
+		"config":    textSearchConfig,
+		"maxLength": maxIndexedTextLength,
+		"ids":       ids,
+	}
+}
+
+// PostgresSearchService implements SearchService with PostgreSQL full-text
+// search on tsvector columns, for deployments without Meilisearch. Indexing
+// refreshes the search_vector column of the affected rows.
+type PostgresSearchService struct {
+	db *gorm.DB
+
+	// Synonyms and stop words are applied by rewriting the query
+	vocabulary *queryVocabulary
+}
+
+func NewPostgresSearchService(db *gorm.DB) *PostgresSearchService {
+	return &PostgresSearchService{db: db, vocabulary: newQueryVocabulary(db)}
+}
+
+// tsQueryTerms splits user input into search terms. Only letters and digits
+// are kept, so the terms can never form tsquery operators.
+func tsQueryTerms(query string) []string {
+	terms := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return terms
+}
+
+func (s *PostgresSearchService) tsQuery(query string, exactMatch bool) string {
+	return s.vocabulary.tsQuery(query, exactMatch)
+}
+
+func tsQueryTerm(term string, exactMatch bool) string {
+	if exactMatch {
+		return term
+	}
+	return term + ":*"
+}
+
+// ApplyVocabulary replaces the synonyms and stop words used to rewrite
+// queries in this process. Other instances pick up the stored vocabulary
+// within vocabularyRefreshInterval.
+func (s *PostgresSearchService) ApplyVocabulary(vocabulary SearchVocabulary) error {
+	s.vocabulary.set(vocabulary, "")
+	return nil
+}
+
+func emptySearchResult(limit, offset int) *SearchResult {
+	return &SearchResult{Hits: []interface{}{}, Limit: limit, Offset: offset}
+}
+
+// toHit converts a search record into the generic hit shape used by the
+// Meilisearch backend, with highlighted fields under _formatted
+func toHit(record interface{}, formatted map[string]string) (map[string]interface{}, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	var hit map[string]interface{}
+	if err := json.Unmarshal(data, &hit); err != nil {
+		return nil, err
+	}
+	if len(formatted) > 0 {
+		hit["_formatted"] = formatted
+	}
+	return hit, nil
+}
+
+// rankOrder orders by relevance, newest first for equal ranks
+func rankOrder(vectorColumn, createdColumn, tsq string) clause.OrderBy {
+	return clause.OrderBy{Expression: clause.Expr{
+		SQL:                "ts_rank(" + vectorColumn + ", to_tsquery(?, ?)) DESC, " + createdColumn + " DESC",
+		Vars:               []interface{}{textSearchConfig, tsq},
+		WithoutParentheses: true,
+	}}
+}
+
+// Documents
+
+func (s *PostgresSearchService) IndexDocument(doc models.Document) error {
+	return s.IndexDocumentsByID([]uuid.UUID{doc.ID})
+}
+
+func (s *PostgresSearchService) IndexDocumentsByID(ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.db.Exec(documentVectorUpdate+" WHERE t.id IN @ids", vectorParams(ids)).Error
+}
+
+// RefreshVectors recomputes the search vectors of all rows, or with
+// onlyMissing of the rows that have none, and returns the counts per table
+func (s *PostgresSearchService) RefreshVectors(onlyMissing bool) (map[string]int64, error) {
+	statements := []struct {
+		table  string
+		update string
+	}{
+		{"documents", documentVectorUpdate},
+		{"subjects", subjectVectorUpdate},
+		{"questions", questionVectorUpdate},
+		{"comments", commentVectorUpdate},
+	}
+
+	where := " WHERE true"
+	if onlyMissing {
+		where = " WHERE t.search_vector IS NULL"
+	}
+
+	counts := make(map[string]int64, len(statements))
+	for _, statement := range statements {
+		condition := where
+		if statement.table == "documents" {
+			condition += " AND t.deleted_at IS NULL"
+		}
+		result := s.db.Exec(statement.update+condition, vectorParams(nil))
+		if result.Error != nil {
+			return counts, fmt.Errorf("failed to refresh %s: %w", statement.table, result.Error)
+		}
+		counts[statement.table] = result.RowsAffected
+	}
+	return counts, nil
+}
+
+func (s *PostgresSearchService) DeleteDocument(docID string) error {
+	return s.db.Exec("UPDATE documents SET search_vector = NULL WHERE id = ?", docID).Error
+}
+
+func (s *PostgresSearchService) GetDocumentCount() (int64, error) {
+	var count int64
+	err := s.db.Model(&models.Document{}).Where("search_vector IS NOT NULL").Count(&count).Error
+	return count, err
+}
+
+// documentQuery returns the filtered documents matching tsq
+func (s *PostgresSearchService) documentQuery(tsq string, opts DocumentSearchOptions) *gorm.DB {
+	query := s.db.Table("documents d").
+		Joins("JOIN subjects s ON s.id = d.subject_id").
+		Where("d.deleted_at IS NULL").
+		Where("d.search_vector @@ to_tsquery(?, ?)", textSearchConfig, tsq)
+
+	if opts.SubjectID != "" {
+		query = query.Where("d.subject_id = ?", opts.SubjectID)
+	}
+	if opts.SemesterID != "" {
+		query = query.Where("s.semester_id = ?", opts.SemesterID)
+	}
+	if termFilter := opts.termFilter(); !termFilter.IsZero() {
+		query = query.Where("d.offering_id IN (?)", termFilter.OfferingIDs(s.db))
+	}
+	if opts.MimeType != "" {
+		query = query.Where("d.mime_type = ?", opts.MimeType)
+	}
+	if opts.Type != "" {
+		query = query.Where("d.type = ?", opts.Type)
+	}
+	if opts.CategoryID != "" {
+		query = query.Where("d.category_id = ?", opts.CategoryID)
+	}
+	if opts.UploadedBy != "" {
+		query = query.Where("d.uploaded_by = ?", opts.UploadedBy)
+	}
+	if opts.CreatedFrom != nil {
+		query = query.Where("d.created_at >= ?", *opts.CreatedFrom)
+	}
+	if opts.CreatedTo != nil {
+		query = query.Where("d.created_at <= ?", *opts.CreatedTo)
+	}
+	if opts.MinSize > 0 {
+		query = query.Where("d.file_size >= ?", opts.MinSize)
+	}
+	if opts.MaxSize > 0 {
+		query = query.Where("d.file_size <= ?", opts.MaxSize)
+	}
+	return query
+}
+
+func (s *PostgresSearchService) Search(opts DocumentSearchOptions) (*SearchResult, error) {
+	tsq := s.tsQuery(opts.Query, opts.ExactMatch)
+	if tsq == "" {
+		return emptySearchResult(opts.Limit, opts.Offset), nil
+	}
+
+	result := emptySearchResult(opts.Limit, opts.Offset)
+	if err := s.documentQuery(tsq, opts).Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+
+	query := s.documentQuery(tsq, opts).
+		Select(`d.id,
+			ts_headline(?, d.original_name, to_tsquery(?, ?), ?) AS name_highlight,
+			ts_headline(?, left(coalesce(d.content_text, ''), ?), to_tsquery(?, ?), ?) AS content_highlight`,
+			textSearchConfig, textSearchConfig, tsq, titleHeadline,
+			textSearchConfig, maxIndexedTextLength, textSearchConfig, tsq, headlineOptions).
+		Limit(opts.Limit).Offset(opts.Offset)
+
+	switch opts.Sort {
+	case SearchSortDateDesc:
+		query = query.Order("d.created_at DESC")
+	case SearchSortDateAsc:
+		query = query.Order("d.created_at ASC")
+	case SearchSortSizeDesc:
+		query = query.Order("d.file_size DESC")
+	case SearchSortSizeAsc:
+		query = query.Order("d.file_size ASC")
+	default:
+		query = query.Clauses(rankOrder("d.search_vector", "d.created_at", tsq))
+	}
+
+	var rows []struct {
+		ID               uuid.UUID
+		NameHighlight    string
+		ContentHighlight string
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return result, s.documentFacets(tsq, opts, result)
+	}
+
+	ids := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	var docs []models.Document
+	if err := s.db.Preload("Subject.Semester").Preload("Uploader").Preload("Category").Preload("Offering.Term").
+		Where("id IN ?", ids).Find(&docs).Error; err != nil {
+		return nil, err
+	}
+	docsByID := make(map[uuid.UUID]models.Document, len(docs))
+	for _, doc := range docs {
+		docsByID[doc.ID] = doc
+	}
+
+	for _, row := range rows {
+		doc, ok := docsByID[row.ID]
+		if !ok {
+			continue
+		}
+		record := NewSearchDocument(doc)
+		record.ContentText = "" // only the highlighted fragments are returned
+		hit, err := toHit(record, map[string]string{
+			"original_name": row.NameHighlight,
+			"content_text":  row.ContentHighlight,
+		})
+		if err != nil {
+			return nil, err
+		}
+		result.Hits = append(result.Hits, hit)
+	}
+
+	return result, s.documentFacets(tsq, opts, result)
+}
+
+// documentFacets counts the matches per subject, type and MIME family
+func (s *PostgresSearchService) documentFacets(tsq string, opts DocumentSearchOptions, result *SearchResult) error {
+	type facetRow struct {
+		Value string
+		Count int64
+	}
+	columns := map[string]string{
+		"subject_id":  "d.subject_id",
+		"type":        "d.type",
+		"mime_family": "d.mime_type",
+	}
+
+	result.Facets = make(map[string]map[string]int64, len(columns))
+	for facet, column := range columns {
+		var rows []facetRow
+		if err := s.documentQuery(tsq, opts).
+			Select(column + "::text AS value, count(*) AS count").
+			Group(column).Scan(&rows).Error; err != nil {
+			return err
+		}
+
+		counts := make(map[string]int64, len(rows))
+		for _, row := range rows {
+			value := row.Value
+			if facet == "mime_family" {
+				value = MimeFamily(value)
+			}
+			counts[value] += row.Count
+		}
+		result.Facets[facet] = counts
+	}
+	return nil
+}
+
+// Subjects
+
+func (s *PostgresSearchService) IndexSubject(subject models.Subject) error {
+	return s.indexSubjectsByID([]uuid.UUID{subject.ID})
+}
+
+func (s *PostgresSearchService) IndexSubjects(subjects []models.Subject) error {
+	ids := make([]uuid.UUID, len(subjects))
+	for i, subject := range subjects {
+		ids[i] = subject.ID
+	}
+	return s.indexSubjectsByID(ids)
+}
+
+func (s *PostgresSearchService) IndexSubjectsByID(ids []uuid.UUID) error {
+	return s.indexSubjectsByID(ids)
+}
+
+func (s *PostgresSearchService) indexSubjectsByID(ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.db.Exec(subjectVectorUpdate+" WHERE t.id IN @ids", vectorParams(ids)).Error
+}
+
+// DeleteSubject has nothing to do; the row and its vector are gone
+func (s *PostgresSearchService) DeleteSubject(subjectID string) error {
+	return nil
+}
+
+// SearchSubjects searches subjects. Only the query, semester, academic
+// year, term and paging options apply.
+func (s *PostgresSearchService) SearchSubjects(opts DocumentSearchOptions) (*SearchResult, error) {
+	result := emptySearchResult(opts.Limit, opts.Offset)
+	tsq := s.tsQuery(opts.Query, opts.ExactMatch)
+	if tsq == "" {
+		return result, nil
+	}
+
+	subjectQuery := func() *gorm.DB {
+		q := s.db.Table("subjects s").Where("s.search_vector @@ to_tsquery(?, ?)", textSearchConfig, tsq)
+		if opts.SemesterID != "" {
+			q = q.Where("s.semester_id = ?", opts.SemesterID)
+		}
+		if termFilter := opts.termFilter(); !termFilter.IsZero() {
+			q = q.Where("s.id IN (?)", termFilter.SubjectIDs(s.db))
+		}
+		return q
+	}
+	if err := subjectQuery().Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		ID                   uuid.UUID
+		NameHighlight        string
+		CodeHighlight        string
+		DescriptionHighlight string
+	}
+	if err := subjectQuery().
+		Select(`s.id,
+			ts_headline(?, s.name_cs, to_tsquery(?, ?), ?) AS name_highlight,
+			ts_headline(?, s.code, to_tsquery(?, ?), ?) AS code_highlight,
+			ts_headline(?, coalesce(s.description_cs, ''), to_tsquery(?, ?), ?) AS description_highlight`,
+			textSearchConfig, textSearchConfig, tsq, titleHeadline,
+			textSearchConfig, textSearchConfig, tsq, titleHeadline,
+			textSearchConfig, textSearchConfig, tsq, headlineOptions).
+		Clauses(rankOrder("s.search_vector", "s.created_at", tsq)).
+		Limit(opts.Limit).Offset(opts.Offset).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return result, nil
+	}
+
+	ids := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	var subjects []models.Subject
+	if err := s.db.Where("id IN ?", ids).Find(&subjects).Error; err != nil {
+		return nil, err
+	}
+	subjectsByID := make(map[uuid.UUID]models.Subject, len(subjects))
+	for _, subject := range subjects {
+		subjectsByID[subject.ID] = subject
+	}
+
+	for _, row := range rows {
+		subject, ok := subjectsByID[row.ID]
+		if !ok {
+			continue
+		}
+		hit, err := toHit(subject, map[string]string{
+			"name_cs":        row.NameHighlight,
+			"code":           row.CodeHighlight,
+			"description_cs": row.DescriptionHighlight,
+		})
+		if err != nil {
+			return nil, err
+		}
+		result.Hits = append(result.Hits, hit)
+	}
+	return result, nil
+}
+
+// Questions and comments
+
+func (s *PostgresSearchService) IndexQuestion(questionID uuid.UUID) error {
+	return s.IndexQuestionsByID([]uuid.UUID{questionID})
+}
+
+func (s *PostgresSearchService) IndexQuestionsByID(ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.db.Exec(questionVectorUpdate+" WHERE t.id IN @ids", vectorParams(ids)).Error
+}
+
+// DeleteQuestion has nothing to do; the row and its vector are gone
+func (s *PostgresSearchService) DeleteQuestion(questionID string) error {
+	return nil
+}
+
+func (s *PostgresSearchService) IndexComment(commentID uuid.UUID) error {
+	return s.IndexCommentsByID([]uuid.UUID{commentID})
+}
+
+func (s *PostgresSearchService) IndexCommentsByID(ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.db.Exec(commentVectorUpdate+" WHERE t.id IN @ids", vectorParams(ids)).Error
+}
+
+// DeleteComment has nothing to do; the row and its vector are gone
+func (s *PostgresSearchService) DeleteComment(commentID string) error {
+	return nil
+}
+
+// discussionQuery returns the filtered rows of table (questions or comments) matching tsq
+func (s *PostgresSearchService) discussionQuery(table, tsq string, opts DocumentSearchOptions) *gorm.DB {
+	query := s.db.Table(table+" t").
+		Joins("JOIN subjects s ON s.id = t.subject_id").
+		Where("t.search_vector @@ to_tsquery(?, ?)", textSearchConfig, tsq)
+
+	if opts.SubjectID != "" {
+		query = query.Where("t.subject_id = ?", opts.SubjectID)
+	}
+	if opts.SemesterID != "" {
+		query = query.Where("s.semester_id = ?", opts.SemesterID)
+	}
+	if opts.CreatedFrom != nil {
+		query = query.Where("t.created_at >= ?", *opts.CreatedFrom)
+	}
+	if opts.CreatedTo != nil {
+		query = query.Where("t.created_at <= ?", *opts.CreatedTo)
+	}
+	return query
+}
+
+type discussionRow struct {
+	ID               uuid.UUID
+	ContentHighlight string
+}
+
+// searchDiscussion returns the matching IDs of table in result order with highlighted content
+func (s *PostgresSearchService) searchDiscussion(table string, opts DocumentSearchOptions, result *SearchResult) ([]discussionRow, error) {
+	tsq := s.tsQuery(opts.Query, opts.ExactMatch)
+	if tsq == "" {
+		return nil, nil
+	}
+	if err := s.discussionQuery(table, tsq, opts).Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+
+	query := s.discussionQuery(table, tsq, opts).
+		Select("t.id, ts_headline(?, t.content, to_tsquery(?, ?), ?) AS content_highlight",
+			textSearchConfig, textSearchConfig, tsq, headlineOptions).
+		Limit(opts.Limit).Offset(opts.Offset)
+	switch opts.Sort {
+	case SearchSortDateDesc:
+		query = query.Order("t.created_at DESC")
+	case SearchSortDateAsc:
+		query = query.Order("t.created_at ASC")
+	default:
+		query = query.Clauses(rankOrder("t.search_vector", "t.created_at", tsq))
+	}
+
+	var rows []discussionRow
+	err := query.Scan(&rows).Error
+	return rows, err
+}
+
+func (s *PostgresSearchService) SearchQuestions(opts DocumentSearchOptions) (*SearchResult, error) {
+	result := emptySearchResult(opts.Limit, opts.Offset)
+	rows, err := s.searchDiscussion("questions", opts, result)
+	if err != nil || len(rows) == 0 {
+		return result, err
+	}
+
+	ids := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	var questions []models.Question
+	if err := s.db.Preload("Subject").Preload("User").
+		Preload("Answers", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at asc")
+		}).
+		Preload("Answers.User").
+		Where("id IN ?", ids).Find(&questions).Error; err != nil {
+		return nil, err
+	}
+	questionsByID := make(map[uuid.UUID]models.Question, len(questions))
+	for _, question := range questions {
+		questionsByID[question.ID] = question
+	}
+
+	for _, row := range rows {
+		question, ok := questionsByID[row.ID]
+		if !ok {
+			continue
+		}
+		hit, err := toHit(NewSearchQuestion(question), map[string]string{"content": row.ContentHighlight})
+		if err != nil {
+			return nil, err
+		}
+		result.Hits = append(result.Hits, hit)
+	}
+	return result, nil
+}
+
+func (s *PostgresSearchService) SearchComments(opts DocumentSearchOptions) (*SearchResult, error) {
+	result := emptySearchResult(opts.Limit, opts.Offset)
+	rows, err := s.searchDiscussion("comments", opts, result)
+	if err != nil || len(rows) == 0 {
+		return result, err
+	}
+
+	ids := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	var comments []models.Comment
+	if err := s.db.Preload("User").Where("id IN ?", ids).Find(&comments).Error; err != nil {
+		return nil, err
+	}
+	subjectIDs := make([]uuid.UUID, 0, len(comments))
+	commentsByID := make(map[uuid.UUID]models.Comment, len(comments))
+	for _, comment := range comments {
+		commentsByID[comment.ID] = comment
+		subjectIDs = append(subjectIDs, comment.SubjectID)
+	}
+	var subjects []models.Subject
+	if err := s.db.Where("id IN ?", subjectIDs).Find(&subjects).Error; err != nil {
+		return nil, err
+	}
+	subjectsByID := make(map[uuid.UUID]models.Subject, len(subjects))
+	for _, subject := range subjects {
+		subjectsByID[subject.ID] = subject
+	}
+
+	for _, row := range rows {
+		comment, ok := commentsByID[row.ID]
+		if !ok {
+			continue
+		}
+		record := NewSearchComment(comment, subjectsByID[comment.SubjectID])
+		hit, err := toHit(record, map[string]string{"content": row.ContentHighlight})
+		if err != nil {
+			return nil, err
+		}
+		result.Hits = append(result.Hits, hit)
+	}
+	return result, nil
+}
+
+func (s *PostgresSearchService) SearchAll(searchType string, opts DocumentSearchOptions) (map[string]interface{}, error) {
+	return searchAll(s, searchType, opts)
+}
+
+// Suggestions
+
+// Suggest matches the beginning of words in subject codes and names,
+// document titles and category names
+func (s *PostgresSearchService) Suggest(query string, limit int) (*Suggestions, error) {
+	result := &Suggestions{
+		Subjects:   []SubjectSuggestion{},
+		Documents:  []DocumentSuggestion{},
+		Categories: []CategorySuggestion{},
+	}
+	terms := tsQueryTerms(query)
+	tsq := s.tsQuery(query, false)
+	if tsq == "" {
+		return result, nil
+	}
+
+	var subjects []models.Subject
+	if err := s.db.Select("id", "code", "name_cs").
+		Where("to_tsvector(?, coalesce(code, '') || ' ' || name_cs) @@ to_tsquery(?, ?)", textSearchConfig, textSearchConfig, tsq).
+		Order("code").Limit(limit).Find(&subjects).Error; err != nil {
+		return nil, err
+	}
+	for _, subject := range subjects {
+		result.Subjects = append(result.Subjects, SubjectSuggestion{
+			ID:      subject.ID.String(),
+			Code:    subject.Code,
+			Name:    subject.NameCS,
+			Matches: prefixMatches(map[string]string{"code": subject.Code, "name_cs": subject.NameCS}, terms),
+		})
+	}
+
+	var documents []struct {
+		ID           uuid.UUID
+		OriginalName string
+		SubjectID    uuid.UUID
+		SubjectCode  string
+	}
+	if err := s.db.Table("documents d").
+		Select("d.id, d.original_name, d.subject_id, s.code AS subject_code").
+		Joins("JOIN subjects s ON s.id = d.subject_id").
+		Where("d.deleted_at IS NULL").
+		Where("to_tsvector(?, d.original_name) @@ to_tsquery(?, ?)", textSearchConfig, textSearchConfig, tsq).
+		Order("d.created_at DESC").Limit(limit).Scan(&documents).Error; err != nil {
+		return nil, err
+	}
+	for _, doc := range documents {
+		result.Documents = append(result.Documents, DocumentSuggestion{
+			ID:          doc.ID.String(),
+			Title:       doc.OriginalName,
+			SubjectID:   doc.SubjectID.String(),
+			SubjectCode: doc.SubjectCode,
+			Matches:     prefixMatches(map[string]string{"title": doc.OriginalName}, terms),
+		})
+	}
+
+	var categories []models.DocumentCategory
+	if err := s.db.Select("id", "name_cs", "name_en", "subject_id").
+		Where("to_tsvector(?, name_cs || ' ' || name_en) @@ to_tsquery(?, ?)", textSearchConfig, textSearchConfig, tsq).
+		Order("order_index").Limit(limit).Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	for _, category := range categories {
+		result.Categories = append(result.Categories, CategorySuggestion{
+			ID:        category.ID.String(),
+			NameCS:    category.NameCS,
+			NameEN:    category.NameEN,
+			SubjectID: category.SubjectID.String(),
+			Matches:   prefixMatches(map[string]string{"category_name_cs": category.NameCS, "category_name_en": category.NameEN}, terms),
+		})
+	}
+
+	return result, nil
+}
+
+// prefixMatches finds the words of each field that start with one of the
+// terms, ignoring case and diacritics, and returns their byte ranges
+func prefixMatches(fields map[string]string, terms []string) map[string][]MatchRange {
+	folded := make([]string, len(terms))
+	for i, term := range terms {
+		folded[i] = foldAccents(term)
+	}
+
+	matches := make(map[string][]MatchRange)
+	for attr, text := range fields {
+		start := -1
+		for i, r := range text + " " {
+			isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+			if isWord && start < 0 {
+				start = i
+				continue
+			}
+			if isWord || start < 0 {
+				continue
+			}
+			word := foldAccents(text[start:i])
+			for _, term := range folded {
+				if term != "" && strings.HasPrefix(word, term) {
+					// Highlight the matched prefix, mapped back to the original bytes
+					length := prefixByteLength(text[start:i], len([]rune(term)))
+					matches[attr] = append(matches[attr], MatchRange{Start: start, Length: length})
+					break
+				}
+			}
+			start = -1
+		}
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+	return matches
+}
+
+// prefixByteLength returns the byte length of the first n runes of s
+func prefixByteLength(s string, n int) int {
+	count := 0
+	for i := range s {
+		if count == n {
+			return i
+		}
+		count++
+	}
+	return len(s)
+}
+
+// foldAccents lowercases s and strips combining marks ("Zkouška" -> "zkouska")
+func foldAccents(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, strings.ToLower(s))
+	if err != nil {
+		return strings.ToLower(s)
+	}
+	return folded
+}
@@ -0,0 +1,460 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/google/uuid"
+	"github.com/meilisearch/meilisearch-go"
+)
+
+const (
+	// meiliTaskTimeout bounds the wait for a single Meilisearch task
+	meiliTaskTimeout = 10 * time.Minute
+
+	defaultReindexBatchSize = 500
+	// maxReportedIDs caps the IDs listed per category in a reindex report
+	maxReportedIDs = 50
+)
+
+// SearchIndexKind describes a Meilisearch index and the table it mirrors
+type SearchIndexKind struct {
+	UID        string
+	table      string
+	softDelete bool
+	// scopeColumn and scopeAttribute limit the table and the index to one subject
+	scopeColumn    string
+	scopeAttribute string
+	filterable     []string
+	// compared maps index attributes to SQL expressions of the same value;
+	// entries whose values differ from the row are stale
+	compared map[string]string
+	settings func() *meilisearch.Settings
+}
+
+// SearchIndexKinds lists every index kept by MeiliSearchService
+var SearchIndexKinds = []SearchIndexKind{
+	{
+		UID:            documentsIndex,
+		table:          "documents",
+		softDelete:     true,
+		scopeColumn:    "subject_id",
+		scopeAttribute: "subject_id",
+		filterable:     documentFilterableAttributes,
+		compared: map[string]string{
+			"subject_id":        "subject_id::text",
+			"category_id":       "coalesce(category_id::text, '')",
+			"type":              "type",
+			"original_name":     "original_name",
+			"extraction_status": "extraction_status",
+		},
+		settings: documentIndexSettings,
+	},
+	{
+		UID:            subjectsIndex,
+		table:          "subjects",
+		scopeColumn:    "id",
+		scopeAttribute: "id",
+		filterable:     subjectFilterableAttributes,
+		compared: map[string]string{
+			"semester_id":    "semester_id::text",
+			"code":           "coalesce(code, '')",
+			"name_cs":        "name_cs",
+			"description_cs": "coalesce(description_cs, '')",
+		},
+		settings: subjectIndexSettings,
+	},
+	{
+		UID:            questionsIndex,
+		table:          "questions",
+		scopeColumn:    "subject_id",
+		scopeAttribute: "subject_id",
+		filterable:     discussionFilterableAttributes,
+		compared: map[string]string{
+			"content":      "content",
+			"is_anonymous": "is_anonymous::text",
+			"answer_count": "(SELECT count(*) FROM answers a WHERE a.question_id = questions.id)::text",
+		},
+		settings: questionIndexSettings,
+	},
+	{
+		UID:            commentsIndex,
+		table:          "comments",
+		scopeColumn:    "subject_id",
+		scopeAttribute: "subject_id",
+		filterable:     discussionFilterableAttributes,
+		compared: map[string]string{
+			"content":      "content",
+			"is_anonymous": "is_anonymous::text",
+		},
+		settings: commentIndexSettings,
+	},
+}
+
+// FindSearchIndexKind returns the index kind with the given UID
+func FindSearchIndexKind(uid string) (SearchIndexKind, bool) {
+	for _, kind := range SearchIndexKinds {
+		if kind.UID == uid {
+			return kind, true
+		}
+	}
+	return SearchIndexKind{}, false
+}
+
+// ReindexOptions controls Rebuild and Repair. SubjectID limits a repair to
+// the records of one subject.
+type ReindexOptions struct {
+	SubjectID string
+	DryRun    bool
+	BatchSize int
+}
+
+// ReindexReport summarizes a rebuild or repair. Missing rows have no index
+// entry, stale entries differ from their row and orphaned entries have no row.
+type ReindexReport struct {
+	Index       string   `json:"index"`
+	Rows        int      `json:"rows"`
+	Indexed     int      `json:"indexed"`
+	Missing     int      `json:"missing"`
+	Stale       int      `json:"stale"`
+	Orphaned    int      `json:"orphaned"`
+	Deleted     int      `json:"deleted"`
+	Failed      int      `json:"failed"`
+	MissingIDs  []string `json:"missing_ids,omitempty"`
+	StaleIDs    []string `json:"stale_ids,omitempty"`
+	OrphanedIDs []string `json:"orphaned_ids,omitempty"`
+	Errors      []string `json:"errors,omitempty"`
+}
+
+func (r *ReindexReport) fail(count int, err error) {
+	r.Failed += count
+	r.Errors = append(r.Errors, err.Error())
+}
+
+// Rebuild indexes every row into a fresh index and swaps it with the live
+// one, so searches keep working on the old records until the new index is
+// complete. Changes made while rebuilding are caught up by a repair pass.
+func (s *MeiliSearchService) Rebuild(kind SearchIndexKind, opts ReindexOptions) (*ReindexReport, error) {
+	if opts.SubjectID != "" {
+		return nil, errors.New("a rebuild always covers the whole index; repair a single subject instead")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultReindexBatchSize
+	}
+
+	report := &ReindexReport{Index: kind.UID}
+	if opts.DryRun {
+		ids, err := s.rowIDs(kind, opts)
+		report.Rows = len(ids)
+		return report, err
+	}
+
+	tmp := kind.UID + "_rebuild"
+	// A leftover from an interrupted rebuild
+	if _, err := s.client.GetIndex(tmp); err == nil {
+		if err := s.waitForTask(s.client.DeleteIndex(tmp)); err != nil {
+			return report, fmt.Errorf("failed to delete stale %s index: %w", tmp, err)
+		}
+	}
+	if err := s.waitForTask(s.client.CreateIndex(&meilisearch.IndexConfig{Uid: tmp, PrimaryKey: "id"})); err != nil {
+		return report, fmt.Errorf("failed to create %s index: %w", tmp, err)
+	}
+	// Swapping needs both indexes to exist
+	if _, err := s.client.GetIndex(kind.UID); err != nil {
+		if err := s.waitForTask(s.client.CreateIndex(&meilisearch.IndexConfig{Uid: kind.UID, PrimaryKey: "id"})); err != nil {
+			return report, fmt.Errorf("failed to create %s index: %w", kind.UID, err)
+		}
+	}
+
+	// The vocabulary is managed at runtime, so it is carried over from the live index
+	settings := kind.settings()
+	if live, err := s.client.Index(kind.UID).GetSettings(); err == nil {
+		settings.Synonyms = live.Synonyms
+		settings.StopWords = live.StopWords
+	}
+	if err := s.waitForTask(s.client.Index(tmp).UpdateSettings(settings)); err != nil {
+		return report, fmt.Errorf("failed to configure %s index: %w", tmp, err)
+	}
+
+	ids, err := s.rowIDs(kind, opts)
+	if err != nil {
+		return report, err
+	}
+	report.Rows = len(ids)
+	s.indexInBatches(kind, tmp, ids, opts.BatchSize, report)
+
+	if report.Failed > 0 {
+		_ = s.waitForTask(s.client.DeleteIndex(tmp))
+		return report, fmt.Errorf("%d records failed to index; %s was left unchanged", report.Failed, kind.UID)
+	}
+
+	if err := s.waitForTask(s.client.SwapIndexes([]meilisearch.SwapIndexesParams{{Indexes: []string{kind.UID, tmp}}})); err != nil {
+		return report, fmt.Errorf("failed to swap %s with %s: %w", tmp, kind.UID, err)
+	}
+	// After the swap the temporary index holds the old records
+	if err := s.waitForTask(s.client.DeleteIndex(tmp)); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("failed to delete %s: %v", tmp, err))
+	}
+
+	catchUp, err := s.Repair(kind, opts)
+	if err != nil {
+		return report, fmt.Errorf("rebuild swapped in, catch-up repair failed: %w", err)
+	}
+	report.Indexed += catchUp.Indexed
+	report.Deleted += catchUp.Deleted
+	report.Failed += catchUp.Failed
+	report.Errors = append(report.Errors, catchUp.Errors...)
+	return report, nil
+}
+
+// Repair compares the IDs in the table with the IDs in the index, indexes
+// missing and stale records and deletes orphaned ones
+func (s *MeiliSearchService) Repair(kind SearchIndexKind, opts ReindexOptions) (*ReindexReport, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultReindexBatchSize
+	}
+	report := &ReindexReport{Index: kind.UID}
+
+	rows, err := s.rowValues(kind, opts)
+	if err != nil {
+		return report, err
+	}
+	entries, err := s.indexValues(kind, opts)
+	if err != nil {
+		return report, err
+	}
+	report.Rows = len(rows)
+
+	var reindex []uuid.UUID
+	for id, values := range rows {
+		entry, ok := entries[id]
+		switch {
+		case !ok:
+			report.Missing++
+			report.MissingIDs = appendReportedID(report.MissingIDs, id)
+		case !sameValues(values, entry):
+			report.Stale++
+			report.StaleIDs = appendReportedID(report.StaleIDs, id)
+		default:
+			continue
+		}
+		if parsed, err := uuid.Parse(id); err == nil {
+			reindex = append(reindex, parsed)
+		}
+	}
+
+	var orphaned []string
+	for id := range entries {
+		if _, ok := rows[id]; !ok {
+			orphaned = append(orphaned, id)
+			report.OrphanedIDs = appendReportedID(report.OrphanedIDs, id)
+		}
+	}
+	report.Orphaned = len(orphaned)
+
+	if opts.DryRun {
+		return report, nil
+	}
+
+	s.indexInBatches(kind, kind.UID, reindex, opts.BatchSize, report)
+	for start := 0; start < len(orphaned); start += opts.BatchSize {
+		batch := orphaned[start:min(start+opts.BatchSize, len(orphaned))]
+		if err := s.waitForTask(s.client.Index(kind.UID).DeleteDocuments(batch)); err != nil {
+			report.fail(len(batch), err)
+			continue
+		}
+		report.Deleted += len(batch)
+	}
+	return report, nil
+}
+
+func appendReportedID(ids []string, id string) []string {
+	if len(ids) >= maxReportedIDs {
+		return ids
+	}
+	return append(ids, id)
+}
+
+func sameValues(row, entry map[string]string) bool {
+	for attr, value := range row {
+		if entry[attr] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// indexInBatches adds the records of ids to the index uid and waits for each batch
+func (s *MeiliSearchService) indexInBatches(kind SearchIndexKind, uid string, ids []uuid.UUID, batchSize int, report *ReindexReport) {
+	for start := 0; start < len(ids); start += batchSize {
+		batch := ids[start:min(start+batchSize, len(ids))]
+
+		records, count, err := s.records(kind, batch)
+		if err != nil {
+			report.fail(len(batch), err)
+			continue
+		}
+		if count == 0 {
+			continue
+		}
+		if err := s.waitForTask(s.client.Index(uid).AddDocuments(records)); err != nil {
+			report.fail(count, err)
+			continue
+		}
+		report.Indexed += count
+	}
+}
+
+// records loads the search records of ids and returns how many were found
+func (s *MeiliSearchService) records(kind SearchIndexKind, ids []uuid.UUID) (interface{}, int, error) {
+	switch kind.UID {
+	case documentsIndex:
+		records, err := s.documentRecords(ids)
+		return records, len(records), err
+	case questionsIndex:
+		records, err := s.questionRecords(ids)
+		return records, len(records), err
+	case commentsIndex:
+		records, err := s.commentRecords(ids)
+		return records, len(records), err
+	case subjectsIndex:
+		var subjects []models.Subject
+		err := s.db.Where("id IN ?", ids).Find(&subjects).Error
+		return subjects, len(subjects), err
+	}
+	return nil, 0, fmt.Errorf("unknown index %q", kind.UID)
+}
+
+// rowIDs returns the IDs of all rows that belong in the index, paging by ID
+func (s *MeiliSearchService) rowIDs(kind SearchIndexKind, opts ReindexOptions) ([]uuid.UUID, error) {
+	var all []uuid.UUID
+	last := uuid.Nil
+	for {
+		query := s.db.Table(kind.table).Where("id > ?", last)
+		if kind.softDelete {
+			query = query.Where("deleted_at IS NULL")
+		}
+		if opts.SubjectID != "" {
+			query = query.Where(kind.scopeColumn+" = ?", opts.SubjectID)
+		}
+
+		var ids []uuid.UUID
+		if err := query.Order("id").Limit(opts.BatchSize).Pluck("id", &ids).Error; err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", kind.table, err)
+		}
+		all = append(all, ids...)
+		if len(ids) < opts.BatchSize {
+			return all, nil
+		}
+		last = ids[len(ids)-1]
+	}
+}
+
+// rowValues returns the compared values of every row that belongs in the index, by ID
+func (s *MeiliSearchService) rowValues(kind SearchIndexKind, opts ReindexOptions) (map[string]map[string]string, error) {
+	selects := "id::text AS id"
+	for attr, expr := range kind.compared {
+		selects += fmt.Sprintf(", %s AS %q", expr, attr)
+	}
+
+	values := make(map[string]map[string]string)
+	last := uuid.Nil.String()
+	for {
+		query := s.db.Table(kind.table).Select(selects).Where("id > ?", last)
+		if kind.softDelete {
+			query = query.Where("deleted_at IS NULL")
+		}
+		if opts.SubjectID != "" {
+			query = query.Where(kind.scopeColumn+" = ?", opts.SubjectID)
+		}
+
+		var rows []map[string]interface{}
+		if err := query.Order("id").Limit(opts.BatchSize).Find(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", kind.table, err)
+		}
+		for _, row := range rows {
+			id := stringValue(row["id"])
+			values[id] = make(map[string]string, len(kind.compared))
+			for attr := range kind.compared {
+				values[id][attr] = stringValue(row[attr])
+			}
+			last = id
+		}
+		if len(rows) < opts.BatchSize {
+			return values, nil
+		}
+	}
+}
+
+// indexValues returns the compared values of every entry in the index, by ID
+func (s *MeiliSearchService) indexValues(kind SearchIndexKind, opts ReindexOptions) (map[string]map[string]string, error) {
+	fields := []string{"id"}
+	for attr := range kind.compared {
+		fields = append(fields, attr)
+	}
+
+	query := &meilisearch.DocumentsQuery{Limit: int64(opts.BatchSize), Fields: fields}
+	if opts.SubjectID != "" {
+		filter, err := NewSearchFilter(kind.filterable).Eq(kind.scopeAttribute, opts.SubjectID).Build()
+		if err != nil {
+			return nil, err
+		}
+		query.Filter = filter
+	}
+
+	values := make(map[string]map[string]string)
+	for {
+		var page meilisearch.DocumentsResult
+		if err := s.client.Index(kind.UID).GetDocuments(query, &page); err != nil {
+			return nil, fmt.Errorf("failed to read %s index: %w", kind.UID, err)
+		}
+		for _, doc := range page.Results {
+			id := stringValue(doc["id"])
+			values[id] = make(map[string]string, len(kind.compared))
+			for attr := range kind.compared {
+				values[id][attr] = stringValue(doc[attr])
+			}
+		}
+		if len(page.Results) < opts.BatchSize {
+			return values, nil
+		}
+		query.Offset += int64(len(page.Results))
+	}
+}
+
+// stringValue formats database and JSON values alike for comparison
+func stringValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// waitForTask waits until Meilisearch has processed an enqueued task and
+// returns the task error when it did not succeed
+func (s *MeiliSearchService) waitForTask(info *meilisearch.TaskInfo, err error) error {
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), meiliTaskTimeout)
+	defer cancel()
+
+	task, err := s.client.WaitForTask(info.TaskUID, meilisearch.WaitParams{Context: ctx, Interval: 100 * time.Millisecond})
+	if err != nil {
+		return err
+	}
+	if task.Status != meilisearch.TaskStatusSucceeded {
+		return fmt.Errorf("meilisearch task %d %s: %s", info.TaskUID, task.Status, task.Error.Message)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/meilisearch/meilisearch-go"
+)
+
+// categorySuggestionPool is how many documents are scanned to collect distinct categories
+const categorySuggestionPool = 30
+
+// MatchRange is a highlighted part of a suggestion, in bytes
+type MatchRange struct {
+	Start  int `json:"start"`
+	Length int `json:"length"`
+}
+
+type SubjectSuggestion struct {
+	ID      string                  `json:"id"`
+	Code    string                  `json:"code"`
+	Name    string                  `json:"name"`
+	Matches map[string][]MatchRange `json:"matches,omitempty"`
+}
+
+type DocumentSuggestion struct {
+	ID          string                  `json:"id"`
+	Title       string                  `json:"title"`
+	SubjectID   string                  `json:"subject_id"`
+	SubjectCode string                  `json:"subject_code"`
+	Matches     map[string][]MatchRange `json:"matches,omitempty"`
+}
+
+type CategorySuggestion struct {
+	ID        string                  `json:"id"`
+	NameCS    string                  `json:"name_cs"`
+	NameEN    string                  `json:"name_en"`
+	SubjectID string                  `json:"subject_id"`
+	Matches   map[string][]MatchRange `json:"matches,omitempty"`
+}
+
+// Suggestions are the autocomplete results for a search box prefix
+type Suggestions struct {
+	Subjects   []SubjectSuggestion  `json:"subjects"`
+	Documents  []DocumentSuggestion `json:"documents"`
+	Categories []CategorySuggestion `json:"categories"`
+}
+
+// Suggest returns up to limit subjects, documents and categories matching the
+// beginning of query. All lookups run in a single multi-search request and only
+// short attributes are searched and returned, so it stays fast enough for
+// search-as-you-type.
+func (s *MeiliSearchService) Suggest(query string, limit int) (*Suggestions, error) {
+	resp, err := s.client.MultiSearch(&meilisearch.MultiSearchRequest{
+		Queries: []meilisearch.SearchRequest{
+			{
+				IndexUID:             subjectsIndex,
+				Query:                query,
+				Limit:                int64(limit),
+				AttributesToSearchOn: []string{"code", "name_cs"},
+				AttributesToRetrieve: []string{"id", "code", "name_cs"},
+				ShowMatchesPosition:  true,
+			},
+			{
+				IndexUID:             s.index,
+				Query:                query,
+				Limit:                int64(limit),
+				AttributesToSearchOn: []string{"original_name"},
+				AttributesToRetrieve: []string{"id", "original_name", "subject_id", "subject_code"},
+				ShowMatchesPosition:  true,
+			},
+			{
+				IndexUID:             s.index,
+				Query:                query,
+				Limit:                categorySuggestionPool,
+				AttributesToSearchOn: []string{"category_name_cs", "category_name_en"},
+				AttributesToRetrieve: []string{"category_id", "category_name_cs", "category_name_en", "subject_id"},
+				ShowMatchesPosition:  true,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Suggestions{
+		Subjects:   []SubjectSuggestion{},
+		Documents:  []DocumentSuggestion{},
+		Categories: []CategorySuggestion{},
+	}
+	if len(resp.Results) != 3 {
+		return result, nil
+	}
+
+	for _, raw := range resp.Results[0].Hits {
+		hit, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		result.Subjects = append(result.Subjects, SubjectSuggestion{
+			ID:      hitString(hit, "id"),
+			Code:    hitString(hit, "code"),
+			Name:    hitString(hit, "name_cs"),
+			Matches: hitMatches(hit),
+		})
+	}
+
+	for _, raw := range resp.Results[1].Hits {
+		hit, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		result.Documents = append(result.Documents, DocumentSuggestion{
+			ID:          hitString(hit, "id"),
+			Title:       hitString(hit, "original_name"),
+			SubjectID:   hitString(hit, "subject_id"),
+			SubjectCode: hitString(hit, "subject_code"),
+			Matches:     renameMatches(hitMatches(hit), "original_name", "title"),
+		})
+	}
+
+	// Categories are not indexed on their own; collect them from matching documents
+	seen := make(map[string]bool)
+	for _, raw := range resp.Results[2].Hits {
+		if len(result.Categories) >= limit {
+			break
+		}
+		hit, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id := hitString(hit, "category_id")
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		result.Categories = append(result.Categories, CategorySuggestion{
+			ID:        id,
+			NameCS:    hitString(hit, "category_name_cs"),
+			NameEN:    hitString(hit, "category_name_en"),
+			SubjectID: hitString(hit, "subject_id"),
+			Matches:   hitMatches(hit),
+		})
+	}
+
+	return result, nil
+}
+
+func hitString(hit map[string]interface{}, key string) string {
+	value, _ := hit[key].(string)
+	return value
+}
+
+// hitMatches converts the _matchesPosition of a hit into match ranges
+func hitMatches(hit map[string]interface{}) map[string][]MatchRange {
+	positions, ok := hit["_matchesPosition"].(map[string]interface{})
+	if !ok || len(positions) == 0 {
+		return nil
+	}
+
+	matches := make(map[string][]MatchRange, len(positions))
+	for attr, raw := range positions {
+		list, ok := raw.([]interface{})
+		if !ok {
+			continue
+		}
+		for _, item := range list {
+			position, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			start, _ := position["start"].(float64)
+			length, _ := position["length"].(float64)
+			matches[attr] = append(matches[attr], MatchRange{Start: int(start), Length: int(length)})
+		}
+	}
+	return matches
+}
+
+func renameMatches(matches map[string][]MatchRange, from, to string) map[string][]MatchRange {
+	if ranges, ok := matches[from]; ok {
+		delete(matches, from)
+		matches[to] = ranges
+	}
+	return matches
+}
+
+// NormalizeSuggestQuery trims and lowercases a query so equal prefixes share a cache entry
+func NormalizeSuggestQuery(query string) string {
+	return strings.ToLower(strings.Join(strings.Fields(query), " "))
+}
@@ -0,0 +1,313 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/P3chys/entoo2-api/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	maxVocabularyEntries = 2000
+	maxVocabularyWordLen = 100
+)
+
+// SearchVocabulary holds the synonyms and stop words applied to searches.
+// Synonyms map a term to the terms it should also match, as in Meilisearch.
+type SearchVocabulary struct {
+	Synonyms  map[string][]string `json:"synonyms"`
+	StopWords []string            `json:"stop_words"`
+}
+
+// Normalize lowercases and trims all words and drops empty and duplicate entries
+func (v SearchVocabulary) Normalize() SearchVocabulary {
+	normalized := SearchVocabulary{
+		Synonyms:  make(map[string][]string, len(v.Synonyms)),
+		StopWords: []string{},
+	}
+
+	for term, synonyms := range v.Synonyms {
+		term = normalizeVocabularyWord(term)
+		if term == "" {
+			continue
+		}
+		seen := map[string]bool{term: true}
+		for _, synonym := range normalized.Synonyms[term] {
+			seen[synonym] = true
+		}
+		for _, synonym := range synonyms {
+			synonym = normalizeVocabularyWord(synonym)
+			if synonym == "" || seen[synonym] {
+				continue
+			}
+			seen[synonym] = true
+			normalized.Synonyms[term] = append(normalized.Synonyms[term], synonym)
+		}
+	}
+
+	seen := make(map[string]bool, len(v.StopWords))
+	for _, word := range v.StopWords {
+		word = normalizeVocabularyWord(word)
+		if word == "" || seen[word] {
+			continue
+		}
+		seen[word] = true
+		normalized.StopWords = append(normalized.StopWords, word)
+	}
+	sort.Strings(normalized.StopWords)
+	return normalized
+}
+
+// Validate checks the size limits of a normalized vocabulary
+func (v SearchVocabulary) Validate() error {
+	entries := len(v.StopWords)
+	for term, synonyms := range v.Synonyms {
+		entries += len(synonyms)
+		if len(term) > maxVocabularyWordLen {
+			return fmt.Errorf("term %q is longer than %d characters", term, maxVocabularyWordLen)
+		}
+		for _, synonym := range synonyms {
+			if len(synonym) > maxVocabularyWordLen {
+				return fmt.Errorf("synonym %q is longer than %d characters", synonym, maxVocabularyWordLen)
+			}
+		}
+	}
+	for _, word := range v.StopWords {
+		if len(word) > maxVocabularyWordLen {
+			return fmt.Errorf("stop word %q is longer than %d characters", word, maxVocabularyWordLen)
+		}
+	}
+	if entries > maxVocabularyEntries {
+		return fmt.Errorf("vocabulary has %d entries, at most %d are allowed", entries, maxVocabularyEntries)
+	}
+	return nil
+}
+
+func normalizeVocabularyWord(word string) string {
+	return strings.ToLower(strings.Join(strings.Fields(word), " "))
+}
+
+// SearchVocabularyService stores the search vocabulary and pushes it to the search backend
+type SearchVocabularyService struct {
+	db     *gorm.DB
+	search SearchService
+}
+
+func NewSearchVocabularyService(db *gorm.DB, search SearchService) *SearchVocabularyService {
+	return &SearchVocabularyService{db: db, search: search}
+}
+
+// Get loads the stored vocabulary
+func (v *SearchVocabularyService) Get() (*SearchVocabulary, error) {
+	return loadVocabulary(v.db)
+}
+
+func loadVocabulary(db *gorm.DB) (*SearchVocabulary, error) {
+	var synonyms []models.SearchSynonym
+	if err := db.Order("term, synonym").Find(&synonyms).Error; err != nil {
+		return nil, err
+	}
+	var stopWords []models.SearchStopWord
+	if err := db.Order("word").Find(&stopWords).Error; err != nil {
+		return nil, err
+	}
+
+	vocabulary := &SearchVocabulary{
+		Synonyms:  make(map[string][]string),
+		StopWords: make([]string, 0, len(stopWords)),
+	}
+	for _, synonym := range synonyms {
+		vocabulary.Synonyms[synonym.Term] = append(vocabulary.Synonyms[synonym.Term], synonym.Synonym)
+	}
+	for _, word := range stopWords {
+		vocabulary.StopWords = append(vocabulary.StopWords, word.Word)
+	}
+	return vocabulary, nil
+}
+
+// Replace stores a new vocabulary and applies it to the search backend
+func (v *SearchVocabularyService) Replace(vocabulary SearchVocabulary) (*SearchVocabulary, error) {
+	vocabulary = vocabulary.Normalize()
+	if err := vocabulary.Validate(); err != nil {
+		return nil, err
+	}
+
+	err := v.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.SearchSynonym{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("1 = 1").Delete(&models.SearchStopWord{}).Error; err != nil {
+			return err
+		}
+
+		var synonyms []models.SearchSynonym
+		for term, words := range vocabulary.Synonyms {
+			for _, word := range words {
+				synonyms = append(synonyms, models.SearchSynonym{Term: term, Synonym: word})
+			}
+		}
+		if len(synonyms) > 0 {
+			if err := tx.CreateInBatches(&synonyms, 500).Error; err != nil {
+				return err
+			}
+		}
+
+		stopWords := make([]models.SearchStopWord, len(vocabulary.StopWords))
+		for i, word := range vocabulary.StopWords {
+			stopWords[i] = models.SearchStopWord{Word: word}
+		}
+		if len(stopWords) > 0 {
+			return tx.CreateInBatches(&stopWords, 500).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := v.search.ApplyVocabulary(vocabulary); err != nil {
+		return &vocabulary, fmt.Errorf("vocabulary saved but not applied: %w", err)
+	}
+	return &vocabulary, nil
+}
+
+// Apply pushes the stored vocabulary to the search backend, e.g. on startup
+func (v *SearchVocabularyService) Apply() error {
+	vocabulary, err := v.Get()
+	if err != nil {
+		return err
+	}
+	if err := v.search.ApplyVocabulary(*vocabulary); err != nil {
+		return err
+	}
+	log.Printf("Applied search vocabulary: %d synonym terms, %d stop words", len(vocabulary.Synonyms), len(vocabulary.StopWords))
+	return nil
+}
+
+// vocabularyRefreshInterval is how long a process uses its copy of the
+// vocabulary before checking whether the stored one changed
+const vocabularyRefreshInterval = 30 * time.Second
+
+// vocabularyVersion hashes the stored vocabulary, so a copy can be checked
+// without loading it
+const vocabularyVersion = `
+	SELECT md5(
+		coalesce((SELECT string_agg(term || '=' || synonym, ',' ORDER BY term, synonym) FROM search_synonyms), '') || '|' ||
+		coalesce((SELECT string_agg(word, ',' ORDER BY word) FROM search_stop_words), '')
+	)`
+
+// queryVocabulary applies the stored synonyms and stop words to PostgreSQL
+// searches by rewriting the tsquery. Each process keeps a copy, which is
+// reloaded when the stored vocabulary has changed, e.g. replaced through
+// another instance.
+type queryVocabulary struct {
+	db *gorm.DB
+
+	mu        sync.RWMutex
+	version   string
+	checkedAt time.Time
+	synonyms  map[string][]string
+	stopWords map[string]bool
+}
+
+func newQueryVocabulary(db *gorm.DB) *queryVocabulary {
+	return &queryVocabulary{db: db}
+}
+
+// set replaces the copy. version is the stored version it corresponds to;
+// an empty version is reloaded on the next check.
+func (v *queryVocabulary) set(vocabulary SearchVocabulary, version string) {
+	synonyms := make(map[string][]string, len(vocabulary.Synonyms))
+	for term, words := range vocabulary.Synonyms {
+		key := foldAccents(term)
+		synonyms[key] = append(synonyms[key], words...)
+	}
+	stopWords := make(map[string]bool, len(vocabulary.StopWords))
+	for _, word := range vocabulary.StopWords {
+		stopWords[foldAccents(word)] = true
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.synonyms = synonyms
+	v.stopWords = stopWords
+	v.version = version
+	v.checkedAt = time.Now()
+}
+
+// refresh reloads the copy when it is due for a check and the stored
+// vocabulary changed. Failures keep the current copy until the next check.
+func (v *queryVocabulary) refresh() {
+	v.mu.Lock()
+	if !v.checkedAt.IsZero() && time.Since(v.checkedAt) < vocabularyRefreshInterval {
+		v.mu.Unlock()
+		return
+	}
+	v.checkedAt = time.Now()
+	current := v.version
+	v.mu.Unlock()
+
+	var version string
+	if err := v.db.Raw(vocabularyVersion).Scan(&version).Error; err != nil {
+		log.Printf("Failed to check search vocabulary: %v", err)
+		return
+	}
+	if version == current {
+		return
+	}
+	vocabulary, err := loadVocabulary(v.db)
+	if err != nil {
+		log.Printf("Failed to load search vocabulary: %v", err)
+		return
+	}
+	v.set(*vocabulary, version)
+}
+
+// tsQuery builds a to_tsquery expression in which every term must match.
+// Unless exactMatch is set, terms also match as prefixes. Stop words are
+// dropped and each term may be replaced by one of its synonyms.
+func (v *queryVocabulary) tsQuery(query string, exactMatch bool) string {
+	terms := tsQueryTerms(query)
+	v.refresh()
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	kept := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if !v.stopWords[foldAccents(term)] {
+			kept = append(kept, term)
+		}
+	}
+	// A query of stop words only is searched as typed
+	if len(kept) == 0 {
+		kept = terms
+	}
+
+	parts := make([]string, 0, len(kept))
+	for _, term := range kept {
+		alternatives := []string{tsQueryTerm(term, exactMatch)}
+		for _, synonym := range v.synonyms[foldAccents(term)] {
+			// Multi-word synonyms must match all their words
+			words := tsQueryTerms(synonym)
+			for i, word := range words {
+				words[i] = tsQueryTerm(word, exactMatch)
+			}
+			if len(words) > 0 {
+				alternatives = append(alternatives, "("+strings.Join(words, " & ")+")")
+			}
+		}
+
+		if len(alternatives) == 1 {
+			parts = append(parts, alternatives[0])
+		} else {
+			parts = append(parts, "("+strings.Join(alternatives, " | ")+")")
+		}
+	}
+	return strings.Join(parts, " & ")
+}
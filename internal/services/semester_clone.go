@@ -0,0 +1,512 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Length limits of subject codes, for every way subjects are created or
+// renamed. The column holds at most MaxSubjectCodeLength characters.
+const (
+	MinSubjectCodeLength = 3
+	MaxSubjectCodeLength = 10
+)
+
+// ValidateSubjectCode checks the length of a subject code
+func ValidateSubjectCode(code string) error {
+	if n := len([]rune(code)); n < MinSubjectCodeLength || n > MaxSubjectCodeLength {
+		return fmt.Errorf("code must have %d to %d characters", MinSubjectCodeLength, MaxSubjectCodeLength)
+	}
+	return nil
+}
+
+var (
+	// ErrSemesterNotFound is returned for a semester ID that does not exist
+	ErrSemesterNotFound = errors.New("semester not found")
+	// ErrInvalidSemesterClone is returned for clone options that cannot be
+	// carried out; the plan lists the problems
+	ErrInvalidSemesterClone = errors.New("semester cannot be cloned")
+	// errClonePreview rolls back the transaction of a preview
+	errClonePreview = errors.New("semester clone preview")
+)
+
+type SemesterCloneMode string
+
+const (
+	// CloneWithNewCodes copies the subjects under new codes into a new semester
+	CloneWithNewCodes SemesterCloneMode = "codes"
+	// CloneAsOfferings keeps the subjects and offers them in a new term
+	CloneAsOfferings SemesterCloneMode = "offerings"
+)
+
+// SemesterCloneOptions describes what a clone copies. With CloneWithNewCodes
+// a semester named NameCS is created; each subject gets the code from Codes,
+// or its old code with CodeSuffix, and is offered in TermID if set. With
+// CloneAsOfferings TermID is required and no semester is created.
+type SemesterCloneOptions struct {
+	Mode       SemesterCloneMode
+	NameCS     string
+	OrderIndex int
+	TermID     *uuid.UUID
+	// SubjectIDs limits the clone to some subjects of the semester
+	SubjectIDs []uuid.UUID
+	Codes      map[string]string
+	CodeSuffix string
+	// DocumentIDs are carried over by reference: the copies share the
+	// stored file and extracted text of the originals
+	DocumentIDs []uuid.UUID
+	AdminID     uuid.UUID
+	Preview     bool
+}
+
+// ClonedDocument is a document carried over by reference
+type ClonedDocument struct {
+	SourceID     uuid.UUID `json:"source_id"`
+	DocumentID   uuid.UUID `json:"document_id"`
+	OriginalName string    `json:"original_name"`
+}
+
+// ClonedSubject is a subject created, or offered again, by a clone
+type ClonedSubject struct {
+	SourceID   uuid.UUID        `json:"source_id"`
+	SourceCode string           `json:"source_code"`
+	SubjectID  uuid.UUID        `json:"subject_id"`
+	Code       string           `json:"code"`
+	NameCS     string           `json:"name_cs"`
+	OfferingID *uuid.UUID       `json:"offering_id,omitempty"`
+	Teachers   []string         `json:"teachers"`
+	Categories []string         `json:"categories"`
+	Documents  []ClonedDocument `json:"documents"`
+}
+
+// SemesterClonePlan lists what a clone created, or would create in a
+// preview. IDs in a preview are not stored.
+type SemesterClonePlan struct {
+	Mode      SemesterCloneMode `json:"mode"`
+	Preview   bool              `json:"preview"`
+	Semester  *models.Semester  `json:"semester,omitempty"`
+	TermID    *uuid.UUID        `json:"term_id,omitempty"`
+	Subjects  []ClonedSubject   `json:"subjects"`
+	Documents int               `json:"documents"`
+	Problems  []string          `json:"problems,omitempty"`
+}
+
+type SemesterCloneService struct {
+	db         *gorm.DB
+	extraction *ExtractionQueue
+	outbox     *SearchOutbox
+}
+
+func NewSemesterCloneService(db *gorm.DB, extraction *ExtractionQueue, outbox *SearchOutbox) *SemesterCloneService {
+	return &SemesterCloneService{db: db, extraction: extraction, outbox: outbox}
+}
+
+// Clone copies the structure of a semester in one transaction. A preview
+// runs the same steps and rolls them back. When the options cannot be
+// carried out, the plan lists the problems and ErrInvalidSemesterClone is
+// returned, also for a preview.
+func (s *SemesterCloneService) Clone(sourceID uuid.UUID, opts SemesterCloneOptions) (*SemesterClonePlan, error) {
+	plan := &SemesterClonePlan{
+		Mode:     opts.Mode,
+		Preview:  opts.Preview,
+		TermID:   opts.TermID,
+		Subjects: []ClonedSubject{},
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.clone(tx, sourceID, opts, plan); err != nil {
+			return err
+		}
+		if len(plan.Problems) > 0 {
+			return ErrInvalidSemesterClone
+		}
+		if opts.Preview {
+			return errClonePreview
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errClonePreview):
+		return plan, nil
+	case errors.Is(err, ErrInvalidSemesterClone):
+		return plan, err
+	case err != nil:
+		return nil, err
+	}
+
+	if plan.Documents > 0 {
+		s.extraction.Notify()
+		s.outbox.Notify()
+	}
+	return plan, nil
+}
+
+func (s *SemesterCloneService) clone(tx *gorm.DB, sourceID uuid.UUID, opts SemesterCloneOptions, plan *SemesterClonePlan) error {
+	var source models.Semester
+	if err := tx.First(&source, "id = ?", sourceID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSemesterNotFound
+		}
+		return err
+	}
+
+	switch opts.Mode {
+	case CloneWithNewCodes:
+		if strings.TrimSpace(opts.NameCS) == "" {
+			plan.Problems = append(plan.Problems, "name_cs of the new semester is required")
+		}
+	case CloneAsOfferings:
+		if opts.TermID == nil {
+			plan.Problems = append(plan.Problems, "term_id is required to clone as offerings")
+		}
+	default:
+		return fmt.Errorf("%w: unknown mode %q", ErrInvalidSemesterClone, opts.Mode)
+	}
+	if opts.TermID != nil {
+		var count int64
+		if err := tx.Model(&models.Term{}).Where("id = ?", *opts.TermID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			plan.Problems = append(plan.Problems, "term not found")
+		}
+	}
+
+	subjects, err := s.sourceSubjects(tx, sourceID, opts.SubjectIDs, plan)
+	if err != nil {
+		return err
+	}
+	documents, err := s.sourceDocuments(tx, subjects, opts.DocumentIDs, plan)
+	if err != nil {
+		return err
+	}
+
+	codes := make(map[uuid.UUID]string, len(subjects))
+	if opts.Mode == CloneWithNewCodes {
+		if err := s.checkCodes(tx, subjects, opts, codes, plan); err != nil {
+			return err
+		}
+	} else if opts.TermID != nil {
+		var offered []string
+		if err := tx.Model(&models.SubjectOffering{}).
+			Joins("JOIN subjects ON subjects.id = subject_offerings.subject_id").
+			Where("subject_offerings.term_id = ? AND subject_offerings.subject_id IN ?", *opts.TermID, subjectIDs(subjects)).
+			Order("subjects.code").
+			Pluck("subjects.code", &offered).Error; err != nil {
+			return err
+		}
+		for _, code := range offered {
+			plan.Problems = append(plan.Problems, fmt.Sprintf("subject %s is already offered in this term", code))
+		}
+	}
+	if len(plan.Problems) > 0 {
+		return nil
+	}
+
+	if opts.Mode == CloneWithNewCodes {
+		plan.Semester = &models.Semester{NameCS: strings.TrimSpace(opts.NameCS), OrderIndex: opts.OrderIndex}
+		if err := tx.Create(plan.Semester).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, subject := range subjects {
+		cloned, err := s.cloneSubject(tx, subject, codes[subject.ID], opts, plan)
+		if err != nil {
+			return err
+		}
+		if err := s.carryDocuments(tx, subject, documents[subject.ID], cloned, opts); err != nil {
+			return err
+		}
+		plan.Documents += len(cloned.Documents)
+		plan.Subjects = append(plan.Subjects, *cloned)
+	}
+	return nil
+}
+
+// sourceSubjects loads the subjects of the semester with their teachers,
+// or only the selected ones
+func (s *SemesterCloneService) sourceSubjects(tx *gorm.DB, semesterID uuid.UUID, selected []uuid.UUID, plan *SemesterClonePlan) ([]models.Subject, error) {
+	query := tx.Preload("Teachers", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at")
+	}).Where("semester_id = ?", semesterID)
+	if len(selected) > 0 {
+		query = query.Where("id IN ?", selected)
+	}
+	var subjects []models.Subject
+	if err := query.Order("code").Find(&subjects).Error; err != nil {
+		return nil, err
+	}
+
+	if len(selected) > 0 {
+		found := make(map[uuid.UUID]bool, len(subjects))
+		for _, subject := range subjects {
+			found[subject.ID] = true
+		}
+		for _, id := range selected {
+			if !found[id] {
+				plan.Problems = append(plan.Problems, fmt.Sprintf("subject %s is not in this semester", id))
+			}
+		}
+	}
+	if len(subjects) == 0 && len(selected) == 0 {
+		plan.Problems = append(plan.Problems, "the semester has no subjects")
+	}
+	return subjects, nil
+}
+
+// sourceDocuments loads the documents to carry over, grouped by subject
+func (s *SemesterCloneService) sourceDocuments(tx *gorm.DB, subjects []models.Subject, ids []uuid.UUID, plan *SemesterClonePlan) (map[uuid.UUID][]models.Document, error) {
+	bySubject := make(map[uuid.UUID][]models.Document)
+	if len(ids) == 0 {
+		return bySubject, nil
+	}
+
+	var documents []models.Document
+	if err := tx.Where("id IN ? AND subject_id IN ?", ids, subjectIDs(subjects)).
+		Order("created_at").Find(&documents).Error; err != nil {
+		return nil, err
+	}
+	found := make(map[uuid.UUID]bool, len(documents))
+	for _, doc := range documents {
+		found[doc.ID] = true
+		bySubject[doc.SubjectID] = append(bySubject[doc.SubjectID], doc)
+	}
+	for _, id := range ids {
+		if !found[id] {
+			plan.Problems = append(plan.Problems, fmt.Sprintf("document %s does not belong to a cloned subject", id))
+		}
+	}
+	return bySubject, nil
+}
+
+// checkCodes picks the new code of every subject and reports codes that are
+// malformed or taken
+func (s *SemesterCloneService) checkCodes(tx *gorm.DB, subjects []models.Subject, opts SemesterCloneOptions, codes map[uuid.UUID]string, plan *SemesterClonePlan) error {
+	used := make(map[string]string, len(subjects))
+	var candidates []string
+	for _, subject := range subjects {
+		code, ok := opts.Codes[subject.Code]
+		if !ok {
+			if opts.CodeSuffix == "" {
+				plan.Problems = append(plan.Problems, fmt.Sprintf("no new code for subject %s", subject.Code))
+				continue
+			}
+			code = subject.Code + opts.CodeSuffix
+		}
+		code = strings.TrimSpace(code)
+		if err := ValidateSubjectCode(code); err != nil {
+			plan.Problems = append(plan.Problems, fmt.Sprintf("%s: %q for subject %s", err, code, subject.Code))
+			continue
+		}
+		if other, ok := used[code]; ok {
+			plan.Problems = append(plan.Problems, fmt.Sprintf("subjects %s and %s would both get code %s", other, subject.Code, code))
+			continue
+		}
+		used[code] = subject.Code
+		codes[subject.ID] = code
+		candidates = append(candidates, code)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	var taken []string
+	if err := tx.Model(&models.Subject{}).Where("code IN ?", candidates).Pluck("code", &taken).Error; err != nil {
+		return err
+	}
+	sort.Strings(taken)
+	for _, code := range taken {
+		plan.Problems = append(plan.Problems, fmt.Sprintf("code %s is already used", code))
+	}
+	return nil
+}
+
+// cloneSubject creates the new subject with its teachers and categories, or
+// with CloneAsOfferings offers the subject in the term
+func (s *SemesterCloneService) cloneSubject(tx *gorm.DB, subject models.Subject, code string, opts SemesterCloneOptions, plan *SemesterClonePlan) (*ClonedSubject, error) {
+	cloned := &ClonedSubject{
+		SourceID:   subject.ID,
+		SourceCode: subject.Code,
+		SubjectID:  subject.ID,
+		Code:       subject.Code,
+		NameCS:     subject.NameCS,
+		Teachers:   []string{},
+		Categories: []string{},
+		Documents:  []ClonedDocument{},
+	}
+
+	teachers := make([]SubjectTeacherInput, len(subject.Teachers))
+	for i, link := range subject.Teachers {
+		teacherID := link.TeacherID
+		teachers[i] = SubjectTeacherInput{TeacherID: &teacherID, Role: link.Role, TopicCS: link.TopicCS}
+		cloned.Teachers = append(cloned.Teachers, link.TeacherName)
+	}
+
+	if opts.Mode == CloneWithNewCodes {
+		target := models.Subject{
+			SemesterID:    plan.Semester.ID,
+			NameCS:        subject.NameCS,
+			Code:          code,
+			DescriptionCS: subject.DescriptionCS,
+			Credits:       subject.Credits,
+		}
+		if err := tx.Omit("Teachers").Create(&target).Error; err != nil {
+			return nil, err
+		}
+		cloned.SubjectID = target.ID
+		cloned.Code = target.Code
+		if _, err := SyncSubjectTeachers(tx, target.ID, teachers); err != nil {
+			return nil, err
+		}
+		if err := s.outbox.Enqueue(tx, models.SearchEntitySubject, target.ID, models.SearchOperationIndex); err != nil {
+			return nil, err
+		}
+
+		var categories []models.DocumentCategory
+		if err := tx.Where("subject_id = ?", subject.ID).Order("type, order_index").Find(&categories).Error; err != nil {
+			return nil, err
+		}
+		for _, category := range categories {
+			copied := models.DocumentCategory{
+				SubjectID:  target.ID,
+				Type:       category.Type,
+				NameCS:     category.NameCS,
+				NameEN:     category.NameEN,
+				OrderIndex: category.OrderIndex,
+				CreatedBy:  opts.AdminID,
+			}
+			if err := tx.Create(&copied).Error; err != nil {
+				return nil, err
+			}
+			cloned.Categories = append(cloned.Categories, category.Type+"/"+category.NameCS)
+		}
+	} else {
+		// Teachers of the latest offering are more current than the subject's
+		var latest models.SubjectOffering
+		err := tx.Preload("Teachers.Teacher").
+			Joins("JOIN terms ON terms.id = subject_offerings.term_id").
+			Joins("JOIN academic_years ON academic_years.id = terms.academic_year_id").
+			Where("subject_offerings.subject_id = ?", subject.ID).
+			Order("academic_years.start_year DESC, terms.kind ASC").
+			First(&latest).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if err == nil {
+			teachers = make([]SubjectTeacherInput, len(latest.Teachers))
+			cloned.Teachers = []string{}
+			for i, link := range latest.Teachers {
+				teacherID := link.TeacherID
+				teachers[i] = SubjectTeacherInput{TeacherID: &teacherID, Role: link.Role, TopicCS: link.TopicCS}
+				if link.Teacher != nil {
+					cloned.Teachers = append(cloned.Teachers, link.Teacher.Name)
+				}
+			}
+		}
+	}
+
+	if opts.TermID != nil {
+		offering := models.SubjectOffering{SubjectID: cloned.SubjectID, TermID: *opts.TermID}
+		if err := tx.Create(&offering).Error; err != nil {
+			return nil, err
+		}
+		if err := syncOfferingTeachers(tx, offering.ID, teachers); err != nil {
+			return nil, err
+		}
+		cloned.OfferingID = &offering.ID
+	}
+	return cloned, nil
+}
+
+// carryDocuments adds copies of documents that share the stored file of
+// the originals. They are put in the matching category of the target subject
+// and in its new offering.
+func (s *SemesterCloneService) carryDocuments(tx *gorm.DB, subject models.Subject, documents []models.Document, cloned *ClonedSubject, opts SemesterCloneOptions) error {
+	if len(documents) == 0 {
+		return nil
+	}
+
+	// Categories of the new subject by the type and name of the originals
+	categories := make(map[uuid.UUID]*uuid.UUID)
+	if cloned.SubjectID != subject.ID {
+		var pairs []struct {
+			SourceID uuid.UUID
+			TargetID uuid.UUID
+		}
+		if err := tx.Table("document_categories src").
+			Select("src.id as source_id, dst.id as target_id").
+			Joins("JOIN document_categories dst ON dst.type = src.type AND dst.name_cs = src.name_cs AND dst.subject_id = ?", cloned.SubjectID).
+			Where("src.subject_id = ?", subject.ID).
+			Scan(&pairs).Error; err != nil {
+			return err
+		}
+		for _, pair := range pairs {
+			targetID := pair.TargetID
+			categories[pair.SourceID] = &targetID
+		}
+	}
+
+	for _, source := range documents {
+		copied := models.Document{
+			SubjectID:        cloned.SubjectID,
+			UploadedBy:       source.UploadedBy,
+			Type:             source.Type,
+			CategoryID:       source.CategoryID,
+			OfferingID:       cloned.OfferingID,
+			SourceDocumentID: &source.ID,
+			Filename:         source.Filename,
+			OriginalName:     source.OriginalName,
+			FileSize:         source.FileSize,
+			MimeType:         source.MimeType,
+			MinIOPath:        source.MinIOPath,
+			ContentText:      source.ContentText,
+			ExtractionStatus: source.ExtractionStatus,
+		}
+		// A copy of a copy refers to the original upload
+		if source.SourceDocumentID != nil {
+			copied.SourceDocumentID = source.SourceDocumentID
+		}
+		if cloned.SubjectID != subject.ID && source.CategoryID != nil {
+			copied.CategoryID = categories[*source.CategoryID]
+		}
+		if err := tx.Create(&copied).Error; err != nil {
+			return err
+		}
+
+		switch copied.ExtractionStatus {
+		case models.ExtractionStatusDone:
+			if err := ReplaceDocumentPages(tx, copied.ID, copied.ContentText); err != nil {
+				return err
+			}
+		case models.ExtractionStatusPending, models.ExtractionStatusFailed:
+			if err := s.extraction.Enqueue(tx, &copied); err != nil {
+				return err
+			}
+		}
+		if err := s.outbox.Enqueue(tx, models.SearchEntityDocument, copied.ID, models.SearchOperationIndex); err != nil {
+			return err
+		}
+
+		cloned.Documents = append(cloned.Documents, ClonedDocument{
+			SourceID:     source.ID,
+			DocumentID:   copied.ID,
+			OriginalName: copied.OriginalName,
+		})
+	}
+	return nil
+}
+
+func subjectIDs(subjects []models.Subject) []uuid.UUID {
+	ids := make([]uuid.UUID, len(subjects))
+	for i, subject := range subjects {
+		ids[i] = subject.ID
+	}
+	return ids
+}
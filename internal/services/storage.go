@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"io"
 	"mime/multipart"
 
 	"github.com/P3chys/entoo2-api/internal/config"
@@ -50,6 +51,14 @@ func (s *StorageService) UploadFile(file multipart.File, filename string, size i
 	return err
 }
 
+func (s *StorageService) UploadStream(reader io.Reader, filename string, size int64, contentType string) error {
+	ctx := context.Background()
+	_, err := s.client.PutObject(ctx, s.bucket, filename, reader, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	return err
+}
+
 func (s *StorageService) UploadFileFromPath(ctx context.Context, file interface{}, filename string, size int64, contentType string) (minio.UploadInfo, error) {
 	return s.client.PutObject(ctx, s.bucket, filename, file.(interface{ Read([]byte) (int, error) }), size, minio.PutObjectOptions{
 		ContentType: contentType,
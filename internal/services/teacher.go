@@ -0,0 +1,350 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrTeacherNotFound is returned for a teacher ID that does not exist
+	ErrTeacherNotFound = errors.New("teacher not found")
+	// ErrTeacherNameTaken is returned when another teacher has the same name,
+	// ignoring case
+	ErrTeacherNameTaken = errors.New("a teacher with this name already exists")
+	// ErrInvalidSubjectTeachers is returned for a teacher list naming a
+	// teacher twice or without a name
+	ErrInvalidSubjectTeachers = errors.New("invalid subject teachers")
+)
+
+// teacherRatingColumns aggregates the ratings of a teacher over all subjects,
+// for a query over teachers
+const teacherRatingColumns = `
+	teachers.*,
+	COALESCE(AVG(teacher_ratings.rating), 0) as average_rating,
+	COUNT(teacher_ratings.id) as total_ratings,
+	COUNT(DISTINCT subject_teachers.subject_id) as subject_count`
+
+// SubjectTeacherInput names a teacher of a subject, either by ID or by name.
+// An unknown name creates a new teacher.
+type SubjectTeacherInput struct {
+	TeacherID *uuid.UUID
+	Name      string
+	Role      models.TeacherRole
+	TopicCS   string
+}
+
+// NormalizeTeacherName trims a name and collapses its inner whitespace
+func NormalizeTeacherName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// IsValidTeacherRole reports whether role is one of the known teacher roles
+func IsValidTeacherRole(role models.TeacherRole) bool {
+	switch role {
+	case models.TeacherRoleGuarantor, models.TeacherRoleLecturer, models.TeacherRoleSeminar:
+		return true
+	}
+	return false
+}
+
+// findOrCreateTeacher returns the teacher with the given name, ignoring case,
+// and creates one if there is none. Names are unique ignoring case, so a
+// teacher created concurrently under the same name is returned instead.
+func findOrCreateTeacher(tx *gorm.DB, name string) (*models.Teacher, error) {
+	name = NormalizeTeacherName(name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: teacher name is required", ErrInvalidSubjectTeachers)
+	}
+
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.Teacher{Name: name}).Error; err != nil {
+		return nil, err
+	}
+
+	var teacher models.Teacher
+	if err := tx.Where("lower(name) = lower(?)", name).First(&teacher).Error; err != nil {
+		return nil, err
+	}
+	return &teacher, nil
+}
+
+// SyncSubjectTeachers makes the teachers of a subject match inputs. Links to
+// teachers that stay are updated in place, so their ratings are kept; links
+// to teachers no longer listed are deleted with their ratings.
+func SyncSubjectTeachers(tx *gorm.DB, subjectID uuid.UUID, inputs []SubjectTeacherInput) ([]models.SubjectTeacher, error) {
+	var existing []models.SubjectTeacher
+	if err := tx.Where("subject_id = ?", subjectID).Order("created_at").Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	byTeacher := make(map[uuid.UUID]models.SubjectTeacher, len(existing))
+	for _, link := range existing {
+		if _, ok := byTeacher[link.TeacherID]; !ok {
+			byTeacher[link.TeacherID] = link
+		}
+	}
+
+	links := make([]models.SubjectTeacher, 0, len(inputs))
+	kept := make(map[uuid.UUID]bool, len(inputs))
+	for _, input := range inputs {
+		var teacher *models.Teacher
+		if input.TeacherID != nil {
+			teacher = &models.Teacher{}
+			if err := tx.First(teacher, "id = ?", *input.TeacherID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, fmt.Errorf("%w: %s", ErrTeacherNotFound, input.TeacherID)
+				}
+				return nil, err
+			}
+		} else {
+			var err error
+			if teacher, err = findOrCreateTeacher(tx, input.Name); err != nil {
+				return nil, err
+			}
+		}
+
+		link, ok := byTeacher[teacher.ID]
+		if ok && kept[link.ID] {
+			return nil, fmt.Errorf("%w: teacher %q is listed twice", ErrInvalidSubjectTeachers, teacher.Name)
+		}
+
+		role := input.Role
+		if role == "" {
+			role = models.TeacherRoleLecturer
+		}
+		if !IsValidTeacherRole(role) {
+			return nil, fmt.Errorf("%w: unknown role %q", ErrInvalidSubjectTeachers, role)
+		}
+		if ok {
+			link.TeacherName = teacher.Name
+			link.Role = role
+			link.TopicCS = input.TopicCS
+			if err := tx.Model(&models.SubjectTeacher{}).Where("id = ?", link.ID).Updates(map[string]interface{}{
+				"teacher_name": link.TeacherName,
+				"role":         link.Role,
+				"topic_cs":     link.TopicCS,
+			}).Error; err != nil {
+				return nil, err
+			}
+		} else {
+			link = models.SubjectTeacher{
+				SubjectID:   subjectID,
+				TeacherID:   teacher.ID,
+				TeacherName: teacher.Name,
+				Role:        role,
+				TopicCS:     input.TopicCS,
+			}
+			if err := tx.Create(&link).Error; err != nil {
+				return nil, err
+			}
+			byTeacher[teacher.ID] = link
+		}
+		kept[link.ID] = true
+		links = append(links, link)
+	}
+
+	var removed []uuid.UUID
+	for _, link := range existing {
+		if !kept[link.ID] {
+			removed = append(removed, link.ID)
+		}
+	}
+	if len(removed) > 0 {
+		if err := deleteRatings(tx, tx.Model(&models.TeacherRating{}).Select("id").Where("subject_teacher_id IN ?", removed)); err != nil {
+			return nil, err
+		}
+		if err := tx.Where("id IN ?", removed).Delete(&models.SubjectTeacher{}).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return links, nil
+}
+
+// TeacherSubject is a subject taught by a teacher with the ratings given there
+type TeacherSubject struct {
+	SubjectTeacherID uuid.UUID          `json:"subject_teacher_id"`
+	SubjectID        uuid.UUID          `json:"subject_id"`
+	Code             string             `json:"code"`
+	NameCS           string             `json:"name_cs"`
+	Role             models.TeacherRole `json:"role"`
+	TopicCS          string             `json:"topic_cs"`
+	AverageRating    float64            `json:"average_rating"`
+	TotalRatings     int64              `json:"total_ratings"`
+	UserRating       *int               `json:"user_rating"`
+}
+
+// TeacherDetail is a teacher with their subjects and the distribution of
+// all their ratings
+type TeacherDetail struct {
+	models.Teacher
+	Subjects           []TeacherSubject `json:"subjects"`
+	RatingDistribution map[string]int64 `json:"rating_distribution"`
+}
+
+type TeacherService struct {
+	db *gorm.DB
+}
+
+func NewTeacherService(db *gorm.DB) *TeacherService {
+	return &TeacherService{db: db}
+}
+
+// List returns teachers ordered by name with their aggregated ratings,
+// optionally filtered by a name fragment and department
+func (s *TeacherService) List(query, department string, limit, offset int) ([]models.Teacher, int64, error) {
+	filtered := s.db.Model(&models.Teacher{})
+	if query = NormalizeTeacherName(query); query != "" {
+		filtered = filtered.Where("teachers.name ILIKE ?", "%"+escapeLike(query)+"%")
+	}
+	if department != "" {
+		filtered = filtered.Where("teachers.department = ?", department)
+	}
+	filtered = filtered.Session(&gorm.Session{})
+
+	var total int64
+	if err := filtered.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	teachers := []models.Teacher{}
+	err := filtered.
+		Select(teacherRatingColumns).
+		Joins("LEFT JOIN subject_teachers ON subject_teachers.teacher_id = teachers.id").
+		Joins("LEFT JOIN teacher_ratings ON teacher_ratings.subject_teacher_id = subject_teachers.id").
+		Group("teachers.id").
+		Order("teachers.name").
+		Limit(limit).
+		Offset(offset).
+		Find(&teachers).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return teachers, total, nil
+}
+
+// Get returns a teacher with the ratings of all their subjects. userID
+// selects the ratings given by the current user.
+func (s *TeacherService) Get(teacherID uuid.UUID, userID string) (*TeacherDetail, error) {
+	detail := &TeacherDetail{
+		Subjects:           []TeacherSubject{},
+		RatingDistribution: make(map[string]int64),
+	}
+	err := s.db.Model(&models.Teacher{}).
+		Select(teacherRatingColumns).
+		Joins("LEFT JOIN subject_teachers ON subject_teachers.teacher_id = teachers.id").
+		Joins("LEFT JOIN teacher_ratings ON teacher_ratings.subject_teacher_id = subject_teachers.id").
+		Where("teachers.id = ?", teacherID).
+		Group("teachers.id").
+		Take(&detail.Teacher).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTeacherNotFound
+		}
+		return nil, err
+	}
+
+	if err := s.db.Table("subject_teachers").
+		Select(`subject_teachers.id as subject_teacher_id, subjects.id as subject_id, subjects.code, subjects.name_cs,
+			subject_teachers.role, subject_teachers.topic_cs,
+			COALESCE(AVG(teacher_ratings.rating), 0) as average_rating,
+			COUNT(teacher_ratings.id) as total_ratings,
+			(SELECT rating FROM teacher_ratings
+			 WHERE subject_teacher_id = subject_teachers.id
+			 AND user_id = ?) as user_rating`, userID).
+		Joins("JOIN subjects ON subjects.id = subject_teachers.subject_id").
+		Joins("LEFT JOIN teacher_ratings ON teacher_ratings.subject_teacher_id = subject_teachers.id").
+		Where("subject_teachers.teacher_id = ?", teacherID).
+		Group("subject_teachers.id, subjects.id").
+		Order("subjects.code").
+		Scan(&detail.Subjects).Error; err != nil {
+		return nil, err
+	}
+
+	var distribution []struct {
+		Rating int
+		Count  int64
+	}
+	if err := s.db.Table("teacher_ratings").
+		Select("teacher_ratings.rating, COUNT(*) as count").
+		Joins("JOIN subject_teachers ON subject_teachers.id = teacher_ratings.subject_teacher_id").
+		Where("subject_teachers.teacher_id = ?", teacherID).
+		Group("teacher_ratings.rating").
+		Scan(&distribution).Error; err != nil {
+		return nil, err
+	}
+	for i := 1; i <= 5; i++ {
+		detail.RatingDistribution[strconv.Itoa(i)] = 0
+	}
+	for _, d := range distribution {
+		detail.RatingDistribution[strconv.Itoa(d.Rating)] = d.Count
+	}
+
+	return detail, nil
+}
+
+// Create adds a teacher
+func (s *TeacherService) Create(teacher *models.Teacher) error {
+	teacher.Name = NormalizeTeacherName(teacher.Name)
+	if err := checkTeacherName(s.db, teacher.Name, uuid.Nil); err != nil {
+		return err
+	}
+	return s.db.Create(teacher).Error
+}
+
+// checkTeacherName returns ErrTeacherNameTaken when a teacher other than
+// exceptID has the given name, ignoring case
+func checkTeacherName(db *gorm.DB, name string, exceptID uuid.UUID) error {
+	var count int64
+	if err := db.Model(&models.Teacher{}).
+		Where("lower(name) = lower(?) AND id <> ?", name, exceptID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrTeacherNameTaken
+	}
+	return nil
+}
+
+// Update changes a teacher's details. A new name is copied to all their subjects.
+func (s *TeacherService) Update(teacherID uuid.UUID, updates map[string]interface{}) (*models.Teacher, error) {
+	var teacher models.Teacher
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&teacher, "id = ?", teacherID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrTeacherNotFound
+			}
+			return err
+		}
+		if name, ok := updates["name"].(string); ok {
+			updates["name"] = NormalizeTeacherName(name)
+			if err := checkTeacherName(tx, updates["name"].(string), teacherID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&teacher).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&teacher, "id = ?", teacherID).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.SubjectTeacher{}).
+			Where("teacher_id = ?", teacher.ID).
+			Update("teacher_name", teacher.Name).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &teacher, nil
+}
@@ -0,0 +1,522 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxReviewLength = 2000
+	maxReviewTags   = 5
+)
+
+var (
+	ErrRatingNotFound        = errors.New("rating not found")
+	ErrReviewReportNotFound  = errors.New("report not found")
+	ErrReviewAlreadyReported = errors.New("review already reported")
+	ErrInvalidReview         = errors.New("invalid review")
+)
+
+// Review sort orders
+const (
+	ReviewSortNewest  = "newest"
+	ReviewSortOldest  = "oldest"
+	ReviewSortHighest = "highest"
+	ReviewSortLowest  = "lowest"
+)
+
+var reviewSortOrders = map[string]string{
+	ReviewSortNewest:  "teacher_ratings.created_at DESC",
+	ReviewSortOldest:  "teacher_ratings.created_at ASC",
+	ReviewSortHighest: "teacher_ratings.rating DESC, teacher_ratings.created_at DESC",
+	ReviewSortLowest:  "teacher_ratings.rating ASC, teacher_ratings.created_at DESC",
+}
+
+// IsValidReviewSort reports whether sort is a known review sort order
+func IsValidReviewSort(sort string) bool {
+	_, ok := reviewSortOrders[sort]
+	return ok
+}
+
+// ReviewInput is a rating with its optional written review
+type ReviewInput struct {
+	Rating        int
+	Review        string
+	Anonymous     bool
+	SemesterTaken string
+	Tags          []models.ReviewTag
+}
+
+// Validate trims the review and checks its length and tags
+func (in *ReviewInput) Validate() error {
+	in.Review = strings.TrimSpace(in.Review)
+	in.SemesterTaken = strings.TrimSpace(in.SemesterTaken)
+	if in.Rating < 1 || in.Rating > 5 {
+		return fmt.Errorf("%w: rating must be between 1 and 5", ErrInvalidReview)
+	}
+	if len([]rune(in.Review)) > maxReviewLength {
+		return fmt.Errorf("%w: review is longer than %d characters", ErrInvalidReview, maxReviewLength)
+	}
+	if len(in.Tags) > maxReviewTags {
+		return fmt.Errorf("%w: at most %d tags are allowed", ErrInvalidReview, maxReviewTags)
+	}
+	seen := make(map[models.ReviewTag]bool, len(in.Tags))
+	tags := in.Tags[:0]
+	for _, tag := range in.Tags {
+		if !isReviewTag(tag) {
+			return fmt.Errorf("%w: unknown tag %q", ErrInvalidReview, tag)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	in.Tags = tags
+	return nil
+}
+
+func isReviewTag(tag models.ReviewTag) bool {
+	for _, known := range models.ReviewTags {
+		if tag == known {
+			return true
+		}
+	}
+	return false
+}
+
+// ReviewAuthor identifies the author of a review that is not anonymous
+type ReviewAuthor struct {
+	ID          uuid.UUID `json:"id"`
+	DisplayName string    `json:"display_name"`
+}
+
+// TeacherReview is a rating with its review as shown to users
+type TeacherReview struct {
+	ID            uuid.UUID          `json:"id"`
+	Rating        int                `json:"rating"`
+	Review        string             `json:"review"`
+	SemesterTaken string             `json:"semester_taken,omitempty"`
+	Tags          []models.ReviewTag `json:"tags"`
+	Anonymous     bool               `json:"anonymous"`
+	Author        *ReviewAuthor      `json:"author"`
+	Hidden        bool               `json:"hidden,omitempty"`
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+}
+
+// RatingSummary aggregates the ratings of a subject teacher and lists a page
+// of their visible reviews
+type RatingSummary struct {
+	AverageRating      float64                    `json:"average_rating"`
+	TotalRatings       int64                      `json:"total_ratings"`
+	UserRating         *int                       `json:"user_rating"`
+	UserReview         *TeacherReview             `json:"user_review"`
+	RatingDistribution map[string]int64           `json:"rating_distribution"`
+	ReviewCount        int64                      `json:"review_count"`
+	TagFrequencies     map[models.ReviewTag]int64 `json:"tag_frequencies"`
+	Reviews            []TeacherReview            `json:"reviews"`
+}
+
+// ReviewListOptions selects a page of reviews
+type ReviewListOptions struct {
+	Sort   string
+	Limit  int
+	Offset int
+}
+
+// ReviewReportEntry is a report in the moderation queue with the reported review
+type ReviewReportEntry struct {
+	models.TeacherReviewReport
+	ReporterEmail    string    `json:"reporter_email"`
+	Review           string    `json:"review"`
+	Rating           int       `json:"rating"`
+	ReviewHidden     bool      `json:"review_hidden"`
+	AuthorID         uuid.UUID `json:"author_id"`
+	AuthorEmail      string    `json:"author_email"`
+	SubjectTeacherID uuid.UUID `json:"subject_teacher_id"`
+	TeacherName      string    `json:"teacher_name"`
+	SubjectCode      string    `json:"subject_code"`
+	OpenReports      int64     `json:"open_reports"`
+}
+
+// reviewRow is a rating joined with its author
+type reviewRow struct {
+	models.TeacherRating
+	AuthorDisplayName string
+}
+
+type TeacherReviewService struct {
+	db *gorm.DB
+}
+
+func NewTeacherReviewService(db *gorm.DB) *TeacherReviewService {
+	return &TeacherReviewService{db: db}
+}
+
+// Rate creates or updates the rating and review of a user for a subject
+// teacher. It reports whether the rating is new.
+func (s *TeacherReviewService) Rate(subjectTeacherID, userID uuid.UUID, input ReviewInput) (*models.TeacherRating, bool, error) {
+	if err := input.Validate(); err != nil {
+		return nil, false, err
+	}
+
+	var rating models.TeacherRating
+	created := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var link models.SubjectTeacher
+		if err := tx.Select("id").First(&link, "id = ?", subjectTeacherID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrTeacherNotFound
+			}
+			return err
+		}
+
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("subject_teacher_id = ? AND user_id = ?", subjectTeacherID, userID).
+			First(&rating).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			rating = models.TeacherRating{
+				SubjectTeacherID: subjectTeacherID,
+				UserID:           userID,
+			}
+			created = true
+		case err != nil:
+			return err
+		}
+
+		rating.Rating = input.Rating
+		rating.Review = input.Review
+		rating.Anonymous = input.Anonymous
+		rating.SemesterTaken = input.SemesterTaken
+		if created {
+			if err := tx.Create(&rating).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Model(&rating).Select("rating", "review", "anonymous", "semester_taken", "updated_at").
+			Updates(&rating).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("rating_id = ?", rating.ID).Delete(&models.TeacherReviewTag{}).Error; err != nil {
+			return err
+		}
+		if len(input.Tags) == 0 {
+			return nil
+		}
+		tags := make([]models.TeacherReviewTag, len(input.Tags))
+		for i, tag := range input.Tags {
+			tags[i] = models.TeacherReviewTag{RatingID: rating.ID, Tag: tag}
+		}
+		return tx.Create(&tags).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return &rating, created, nil
+}
+
+// Delete removes a user's rating of a subject teacher with its review
+func (s *TeacherReviewService) Delete(subjectTeacherID, userID uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var rating models.TeacherRating
+		if err := tx.Select("id").
+			Where("subject_teacher_id = ? AND user_id = ?", subjectTeacherID, userID).
+			First(&rating).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRatingNotFound
+			}
+			return err
+		}
+		return deleteRatings(tx, tx.Model(&models.TeacherRating{}).Select("id").Where("id = ?", rating.ID))
+	})
+}
+
+// deleteRatings deletes the ratings selected by ids, a subquery of rating
+// IDs, with their tags and reports
+func deleteRatings(tx *gorm.DB, ids *gorm.DB) error {
+	if err := tx.Where("rating_id IN (?)", ids).Delete(&models.TeacherReviewTag{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("rating_id IN (?)", ids).Delete(&models.TeacherReviewReport{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("id IN (?)", ids).Delete(&models.TeacherRating{}).Error
+}
+
+// Summary aggregates the ratings of a subject teacher and returns a page of
+// their visible reviews. userID selects the user's own rating and review.
+func (s *TeacherReviewService) Summary(subjectTeacherID uuid.UUID, userID string, opts ReviewListOptions) (*RatingSummary, error) {
+	summary := &RatingSummary{
+		RatingDistribution: make(map[string]int64),
+		TagFrequencies:     make(map[models.ReviewTag]int64),
+		Reviews:            []TeacherReview{},
+	}
+
+	var totals struct {
+		AverageRating float64
+		TotalRatings  int64
+		ReviewCount   int64
+	}
+	if err := s.db.Model(&models.TeacherRating{}).
+		Select(`COALESCE(AVG(rating), 0) as average_rating, COUNT(*) as total_ratings,
+			COUNT(*) FILTER (WHERE review <> '' AND NOT hidden) as review_count`).
+		Where("subject_teacher_id = ?", subjectTeacherID).
+		Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+	summary.AverageRating = totals.AverageRating
+	summary.TotalRatings = totals.TotalRatings
+	summary.ReviewCount = totals.ReviewCount
+
+	var distribution []struct {
+		Rating int
+		Count  int64
+	}
+	if err := s.db.Model(&models.TeacherRating{}).
+		Select("rating, COUNT(*) as count").
+		Where("subject_teacher_id = ?", subjectTeacherID).
+		Group("rating").
+		Scan(&distribution).Error; err != nil {
+		return nil, err
+	}
+	for i := 1; i <= 5; i++ {
+		summary.RatingDistribution[strconv.Itoa(i)] = 0
+	}
+	for _, d := range distribution {
+		summary.RatingDistribution[strconv.Itoa(d.Rating)] = d.Count
+	}
+
+	var tags []struct {
+		Tag   models.ReviewTag
+		Count int64
+	}
+	if err := s.db.Table("teacher_review_tags").
+		Select("teacher_review_tags.tag, COUNT(*) as count").
+		Joins("JOIN teacher_ratings ON teacher_ratings.id = teacher_review_tags.rating_id").
+		Where("teacher_ratings.subject_teacher_id = ? AND NOT teacher_ratings.hidden", subjectTeacherID).
+		Group("teacher_review_tags.tag").
+		Scan(&tags).Error; err != nil {
+		return nil, err
+	}
+	for _, tag := range tags {
+		summary.TagFrequencies[tag.Tag] = tag.Count
+	}
+
+	var rows []reviewRow
+	if err := s.reviewQuery().
+		Where("teacher_ratings.subject_teacher_id = ? AND teacher_ratings.review <> '' AND NOT teacher_ratings.hidden", subjectTeacherID).
+		Order(reviewSortOrders[opts.Sort]).
+		Limit(opts.Limit).
+		Offset(opts.Offset).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	reviews, err := s.toReviews(rows)
+	if err != nil {
+		return nil, err
+	}
+	summary.Reviews = reviews
+
+	var own []reviewRow
+	if err := s.reviewQuery().
+		Where("teacher_ratings.subject_teacher_id = ? AND teacher_ratings.user_id = ?", subjectTeacherID, userID).
+		Limit(1).
+		Scan(&own).Error; err != nil {
+		return nil, err
+	}
+	if len(own) > 0 {
+		ownReviews, err := s.toReviews(own)
+		if err != nil {
+			return nil, err
+		}
+		// Users always see their own name and whether their review was hidden
+		ownReviews[0].Author = &ReviewAuthor{ID: own[0].UserID, DisplayName: own[0].AuthorDisplayName}
+		ownReviews[0].Hidden = own[0].Hidden
+		summary.UserReview = &ownReviews[0]
+		summary.UserRating = &own[0].Rating
+	}
+
+	return summary, nil
+}
+
+func (s *TeacherReviewService) reviewQuery() *gorm.DB {
+	return s.db.Table("teacher_ratings").
+		Select("teacher_ratings.*, users.display_name as author_display_name").
+		Joins("JOIN users ON users.id = teacher_ratings.user_id")
+}
+
+// toReviews converts rating rows to reviews with their tags, leaving out the
+// authors of anonymous reviews
+func (s *TeacherReviewService) toReviews(rows []reviewRow) ([]TeacherReview, error) {
+	reviews := make([]TeacherReview, len(rows))
+	if len(rows) == 0 {
+		return reviews, nil
+	}
+
+	ids := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	var tags []models.TeacherReviewTag
+	if err := s.db.Where("rating_id IN ?", ids).Order("tag").Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	tagsByRating := make(map[uuid.UUID][]models.ReviewTag)
+	for _, tag := range tags {
+		tagsByRating[tag.RatingID] = append(tagsByRating[tag.RatingID], tag.Tag)
+	}
+
+	for i, row := range rows {
+		reviews[i] = TeacherReview{
+			ID:            row.ID,
+			Rating:        row.Rating,
+			Review:        row.Review,
+			SemesterTaken: row.SemesterTaken,
+			Tags:          tagsByRating[row.ID],
+			Anonymous:     row.Anonymous,
+			CreatedAt:     row.CreatedAt,
+			UpdatedAt:     row.UpdatedAt,
+		}
+		if reviews[i].Tags == nil {
+			reviews[i].Tags = []models.ReviewTag{}
+		}
+		if !row.Anonymous {
+			reviews[i].Author = &ReviewAuthor{ID: row.UserID, DisplayName: row.AuthorDisplayName}
+		}
+	}
+	return reviews, nil
+}
+
+// Report puts a review into the moderation queue. Each user can report a
+// review once.
+func (s *TeacherReviewService) Report(ratingID, reporterID uuid.UUID, reason string) (*models.TeacherReviewReport, error) {
+	var rating models.TeacherRating
+	if err := s.db.Select("id", "review").First(&rating, "id = ?", ratingID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRatingNotFound
+		}
+		return nil, err
+	}
+	if rating.Review == "" {
+		return nil, ErrRatingNotFound
+	}
+
+	report := models.TeacherReviewReport{
+		RatingID:   ratingID,
+		ReporterID: reporterID,
+		Reason:     strings.TrimSpace(reason),
+		Status:     models.ReviewReportOpen,
+	}
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&report)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrReviewAlreadyReported
+	}
+	return &report, nil
+}
+
+// ListReports returns the reports with the given status, oldest first
+func (s *TeacherReviewService) ListReports(status models.ReviewReportStatus, limit, offset int) ([]ReviewReportEntry, int64, error) {
+	query := s.db.Model(&models.TeacherReviewReport{}).Where("teacher_review_reports.status = ?", status)
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	entries := []ReviewReportEntry{}
+	err := query.
+		Select(`teacher_review_reports.*, reporters.email as reporter_email,
+			teacher_ratings.review, teacher_ratings.rating, teacher_ratings.hidden as review_hidden,
+			teacher_ratings.user_id as author_id, authors.email as author_email,
+			subject_teachers.id as subject_teacher_id, subject_teachers.teacher_name, subjects.code as subject_code,
+			(SELECT COUNT(*) FROM teacher_review_reports r
+			 WHERE r.rating_id = teacher_review_reports.rating_id AND r.status = ?) as open_reports`, models.ReviewReportOpen).
+		Joins("JOIN teacher_ratings ON teacher_ratings.id = teacher_review_reports.rating_id").
+		Joins("JOIN users reporters ON reporters.id = teacher_review_reports.reporter_id").
+		Joins("JOIN users authors ON authors.id = teacher_ratings.user_id").
+		Joins("JOIN subject_teachers ON subject_teachers.id = teacher_ratings.subject_teacher_id").
+		Joins("JOIN subjects ON subjects.id = subject_teachers.subject_id").
+		Order("teacher_review_reports.created_at").
+		Limit(limit).
+		Offset(offset).
+		Scan(&entries).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// SetHidden hides or shows a review. Hiding resolves its open reports.
+func (s *TeacherReviewService) SetHidden(ratingID, adminID uuid.UUID, hidden bool, reason string) (*models.TeacherRating, error) {
+	var rating models.TeacherRating
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&rating, "id = ?", ratingID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRatingNotFound
+			}
+			return err
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{
+			"hidden":        hidden,
+			"hidden_reason": "",
+			"hidden_by":     nil,
+			"hidden_at":     nil,
+		}
+		if hidden {
+			updates["hidden_reason"] = strings.TrimSpace(reason)
+			updates["hidden_by"] = adminID
+			updates["hidden_at"] = now
+		}
+		if err := tx.Model(&models.TeacherRating{}).Where("id = ?", ratingID).Updates(updates).Error; err != nil {
+			return err
+		}
+		if hidden {
+			if err := tx.Model(&models.TeacherReviewReport{}).
+				Where("rating_id = ? AND status = ?", ratingID, models.ReviewReportOpen).
+				Updates(map[string]interface{}{
+					"status":      models.ReviewReportResolved,
+					"resolved_by": adminID,
+					"resolved_at": now,
+				}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.First(&rating, "id = ?", ratingID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rating, nil
+}
+
+// DismissReport closes a report without hiding the review
+func (s *TeacherReviewService) DismissReport(reportID, adminID uuid.UUID) error {
+	result := s.db.Model(&models.TeacherReviewReport{}).
+		Where("id = ? AND status = ?", reportID, models.ReviewReportOpen).
+		Updates(map[string]interface{}{
+			"status":      models.ReviewReportDismissed,
+			"resolved_by": adminID,
+			"resolved_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrReviewReportNotFound
+	}
+	return nil
+}
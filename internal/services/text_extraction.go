@@ -2,20 +2,45 @@ package services
 
 import (
 	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
 	"io"
+	"log"
 	"mime/multipart"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/P3chys/entoo2-api/internal/config"
 )
 
+// ErrUnsupportedFormat is returned when the extractor cannot handle a file format
+var ErrUnsupportedFormat = errors.New("unsupported format for text extraction")
+
+// TextExtractor extracts plain text from a file of the given MIME type
+type TextExtractor interface {
+	ExtractTextContext(ctx context.Context, reader io.Reader, mimeType string) (string, error)
+}
+
 type TextExtractionService struct {
 	tikaURL string
+	client  *http.Client
 }
 
 func NewTextExtractionService(cfg *config.Config) *TextExtractionService {
+	timeout, err := time.ParseDuration(cfg.TikaTimeout)
+	if err != nil || timeout <= 0 {
+		log.Printf("Invalid TIKA_TIMEOUT %q, using 60s", cfg.TikaTimeout)
+		timeout = 60 * time.Second
+	}
+
 	return &TextExtractionService{
 		tikaURL: cfg.TikaURL,
+		client:  &http.Client{Timeout: timeout},
 	}
 }
 
@@ -25,23 +50,203 @@ func (s *TextExtractionService) ExtractText(file multipart.File) (string, error)
 		_, _ = seeker.Seek(0, 0)
 	}
 
-	req, err := http.NewRequest("PUT", s.tikaURL+"/tika", file)
+	return s.ExtractTextFromReader(file, "")
+}
+
+// ExtractTextFromReader sends the content of reader to Tika and returns the plain text
+func (s *TextExtractionService) ExtractTextFromReader(reader io.Reader, mimeType string) (string, error) {
+	return s.ExtractTextContext(context.Background(), reader, mimeType)
+}
+
+// ExtractTextContext is like ExtractTextFromReader but aborts when ctx is done
+func (s *TextExtractionService) ExtractTextContext(ctx context.Context, reader io.Reader, mimeType string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "PUT", s.tikaURL+"/tika", reader)
 	if err != nil {
 		return "", err
 	}
-	req.Header.Set("Accept", "text/plain")
+	// Only the XHTML output marks page and slide boundaries
+	paged := hasPages(mimeType)
+	if paged {
+		req.Header.Set("Accept", "text/html")
+	} else {
+		req.Header.Set("Accept", "text/plain")
+	}
+	if mimeType != "" {
+		req.Header.Set("Content-Type", mimeType)
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := s.client.Do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusUnsupportedMediaType || resp.StatusCode == http.StatusUnprocessableEntity {
+		return "", ErrUnsupportedFormat
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("tika returned status %d", resp.StatusCode)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return "", err
 	}
 
+	if paged {
+		return xhtmlPagedText(body)
+	}
 	return string(bytes.TrimSpace(body)), nil
 }
+
+// hasPages reports whether files of this MIME type are split into pages or slides
+func hasPages(mimeType string) bool {
+	return mimeType == "application/pdf" ||
+		mimeType == "application/vnd.openxmlformats-officedocument.presentationml.presentation" ||
+		mimeType == "application/vnd.ms-powerpoint"
+}
+
+// xhtmlPagedText converts Tika's XHTML output to plain text with a page break
+// after every page and slide, which Tika wraps in their own div elements
+func xhtmlPagedText(data []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	var b strings.Builder
+	var pageDivs []bool // for each open div, whether it is a page
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("invalid tika output: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "head", "script", "style":
+				if err := decoder.Skip(); err != nil {
+					return "", fmt.Errorf("invalid tika output: %w", err)
+				}
+			case "div":
+				pageDivs = append(pageDivs, isPageDiv(t.Attr))
+			case "br":
+				b.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "div":
+				if n := len(pageDivs); n > 0 {
+					if pageDivs[n-1] {
+						b.WriteString("\n" + pageBreak + "\n")
+					} else {
+						b.WriteString("\n")
+					}
+					pageDivs = pageDivs[:n-1]
+				}
+			case "p", "h1", "h2", "h3", "h4", "h5", "h6", "li", "tr":
+				b.WriteString("\n")
+			case "td", "th":
+				b.WriteString("\t")
+			}
+		case xml.CharData:
+			b.Write(t)
+		}
+	}
+
+	return strings.TrimSpace(normalizePDFText(b.String())), nil
+}
+
+func isPageDiv(attrs []xml.Attr) bool {
+	for _, attr := range attrs {
+		if attr.Name.Local != "class" {
+			continue
+		}
+		for _, class := range strings.Fields(attr.Value) {
+			if class == "page" || class == "slide-content" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsTextExtractable reports whether text can be extracted from files of this MIME type
+func IsTextExtractable(mimeType string) bool {
+	return mimeType == "application/pdf" ||
+		mimeType == "application/vnd.openxmlformats-officedocument.wordprocessingml.document" ||
+		mimeType == "application/vnd.openxmlformats-officedocument.presentationml.presentation" ||
+		mimeType == "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet" ||
+		mimeType == "application/vnd.ms-excel" || // legacy XLS, but browsers also send CSV files with it
+		mimeType == "application/csv" ||
+		strings.HasPrefix(mimeType, "text/")
+}
+
+// FallbackExtractor uses Tika when it is reachable and the built-in local
+// extractor otherwise. After a connection failure Tika is skipped for a while
+// so that uploads do not keep waiting for timeouts.
+type FallbackExtractor struct {
+	tika  *TextExtractionService
+	local *LocalTextExtractor
+
+	mu            sync.Mutex
+	tikaDownUntil time.Time
+}
+
+const tikaRetryAfter = time.Minute
+
+func NewFallbackExtractor(tika *TextExtractionService, local *LocalTextExtractor) *FallbackExtractor {
+	return &FallbackExtractor{
+		tika:  tika,
+		local: local,
+	}
+}
+
+func (e *FallbackExtractor) ExtractTextContext(ctx context.Context, reader io.Reader, mimeType string) (string, error) {
+	if e.tika == nil || !e.tikaAvailable() {
+		return e.local.ExtractTextContext(ctx, reader, mimeType)
+	}
+
+	// Buffer the file so it can be handed to the local extractor if Tika fails
+	data, err := io.ReadAll(io.LimitReader(reader, maxLocalExtractionSize+1))
+	if err != nil {
+		return "", err
+	}
+
+	text, tikaErr := e.tika.ExtractTextContext(ctx, bytes.NewReader(data), mimeType)
+	if tikaErr == nil {
+		return text, nil
+	}
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+
+	var netErr net.Error
+	if errors.As(tikaErr, &netErr) {
+		log.Printf("Tika unreachable, using local text extraction for %s: %v", tikaRetryAfter, tikaErr)
+		e.markTikaDown()
+	}
+
+	text, err = e.local.ExtractTextContext(ctx, bytes.NewReader(data), mimeType)
+	if errors.Is(err, ErrUnsupportedFormat) {
+		// The local extractor knows fewer formats; Tika's answer is more useful
+		return "", tikaErr
+	}
+	return text, err
+}
+
+func (e *FallbackExtractor) tikaAvailable() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return time.Now().After(e.tikaDownUntil)
+}
+
+func (e *FallbackExtractor) markTikaDown() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.tikaDownUntil = time.Now().Add(tikaRetryAfter)
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/P3chys/entoo2-api/internal/config"
+	"github.com/P3chys/entoo2-api/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type TrashService struct {
+	db            *gorm.DB
+	storage       *StorageService
+	retention     time.Duration
+	purgeInterval time.Duration
+}
+
+func NewTrashService(db *gorm.DB, cfg *config.Config, storage *StorageService) *TrashService {
+	retention, err := time.ParseDuration(cfg.TrashRetention)
+	if err != nil {
+		log.Printf("Invalid TRASH_RETENTION %q, using 720h: %v", cfg.TrashRetention, err)
+		retention = 720 * time.Hour
+	}
+
+	purgeInterval, err := time.ParseDuration(cfg.TrashPurgeInterval)
+	if err != nil || purgeInterval <= 0 {
+		log.Printf("Invalid TRASH_PURGE_INTERVAL %q, using 1h", cfg.TrashPurgeInterval)
+		purgeInterval = time.Hour
+	}
+
+	return &TrashService{
+		db:            db,
+		storage:       storage,
+		retention:     retention,
+		purgeInterval: purgeInterval,
+	}
+}
+
+// Retention returns how long deleted documents are kept before being purged
+func (s *TrashService) Retention() time.Duration {
+	return s.retention
+}
+
+// StartPurgeScheduler periodically purges expired documents until ctx is cancelled
+func (s *TrashService) StartPurgeScheduler(ctx context.Context) {
+	ticker := time.NewTicker(s.purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		if purged, err := s.PurgeExpired(); err != nil {
+			log.Printf("Trash purge failed: %v", err)
+		} else if purged > 0 {
+			log.Printf("Trash purge removed %d documents", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeExpired permanently deletes documents that have been in the trash longer than the retention
+func (s *TrashService) PurgeExpired() (int, error) {
+	var documents []models.Document
+	cutoff := time.Now().Add(-s.retention)
+	if err := s.db.Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Find(&documents).Error; err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, document := range documents {
+		if err := s.PurgeDocument(document); err != nil {
+			log.Printf("Failed to purge document %s: %v", document.ID, err)
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// PurgeDocument removes a document's row from the database and its object
+// from storage unless other documents still share it
+func (s *TrashService) PurgeDocument(document models.Document) error {
+	if s.storage == nil {
+		return errors.New("storage service unavailable")
+	}
+	// Documents carried over by reference share the stored file
+	var sharing int64
+	if err := s.db.Unscoped().Model(&models.Document{}).
+		Where("minio_path = ? AND id <> ?", document.MinIOPath, document.ID).
+		Count(&sharing).Error; err != nil {
+		return err
+	}
+	if sharing == 0 {
+		if err := s.storage.DeleteFile(document.MinIOPath); err != nil {
+			return err
+		}
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return deleteDocumentRows(tx, document.ID)
+	})
+}
+
+// deleteDocumentRows permanently deletes a document's row together with its
+// favorites, notifications and pages, and detaches it from answers and from
+// copies carried over by reference
+func deleteDocumentRows(tx *gorm.DB, documentID uuid.UUID) error {
+	// Copies keep the stored file and from now on count towards quotas as its owner
+	if err := tx.Unscoped().Model(&models.Document{}).Where("source_document_id = ?", documentID).
+		Update("source_document_id", nil).Error; err != nil {
+		return err
+	}
+	if err := tx.Exec("DELETE FROM user_favorite_documents WHERE document_id = ?", documentID).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Answer{}).Where("document_id = ?", documentID).Update("document_id", nil).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("document_id = ?", documentID).Delete(&models.Notification{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("document_id = ?", documentID).Delete(&models.DocumentPage{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Delete(&models.Document{}, "id = ?", documentID).Error
+}